@@ -0,0 +1,174 @@
+// cmd/admin/main.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go_microsvc/config"
+	"go_microsvc/services"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = `Использование: admin <команда> [флаги]
+
+Команды:
+  pause   приостановить consumer экземпляра API (-api)
+  resume  возобновить consumer экземпляра API (-api)
+  reset   сбросить смещения группы (группа должна быть приостановлена)
+
+Примеры:
+  admin pause -api http://localhost:8080
+  admin reset -to earliest -dry-run
+  admin reset -to timestamp -timestamp 2024-05-01T10:00:00Z
+  admin reset -to offset -offsets 0=100,1=250
+  admin resume -api http://localhost:8080
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "pause", "resume":
+		toggle(os.Args[1], os.Args[2:])
+	case "reset":
+		reset(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// toggle приостанавливает или возобновляет consumer экземпляра API через admin endpoint
+func toggle(command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	api := flags.String("api", "http://localhost:8080", "адрес экземпляра API")
+	_ = flags.Parse(args)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(strings.TrimRight(*api, "/")+"/api/admin/consumers/"+command, "application/json", nil)
+	if err != nil {
+		log.Fatalf("Ошибка запроса к API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		log.Fatalf("Ошибка чтения ответа API: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("API вернул %d: %v", resp.StatusCode, body["error"])
+	}
+	log.Printf("Consumer %s: paused=%v", *api, body["paused"])
+}
+
+// reset сбрасывает смещения группы напрямую через Kafka с конфигурацией consumer
+func reset(args []string) {
+	flags := flag.NewFlagSet("reset", flag.ExitOnError)
+	group := flags.String("group", "", "группа потребителей (по умолчанию из конфигурации)")
+	topic := flags.String("topic", "", "топик (по умолчанию из конфигурации)")
+	mode := flags.String("to", services.ResetToEarliest, "earliest, latest, timestamp или offset")
+	timestamp := flags.String("timestamp", "", "время в формате RFC 3339 для -to timestamp")
+	offsets := flags.String("offsets", "", "смещения партиций для -to offset: 0=100,1=250")
+	partitions := flags.String("partitions", "", "партиции через запятую (по умолчанию все)")
+	dryRun := flags.Bool("dry-run", false, "только показать, какие записи будут перечитаны")
+	_ = flags.Parse(args)
+
+	request := services.OffsetResetRequest{GroupID: *group, Topic: *topic, Mode: *mode, DryRun: *dryRun}
+	var err error
+	if *timestamp != "" {
+		if request.Timestamp, err = time.Parse(time.RFC3339, *timestamp); err != nil {
+			log.Fatalf("Некорректный timestamp: %v", err)
+		}
+	}
+	if request.Offsets, err = parseOffsets(*offsets); err != nil {
+		log.Fatalf("Некорректные смещения: %v", err)
+	}
+	if request.Partitions, err = parsePartitions(*partitions); err != nil {
+		log.Fatalf("Некорректные партиции: %v", err)
+	}
+
+	cfg := config.LoadConfig()
+	consumerCfg := services.NewConsumerConfig(cfg)
+	if err := consumerCfg.Validate(); err != nil {
+		log.Fatalf("Ошибка конфигурации Kafka consumer: %v", err)
+	}
+
+	// CLI не управляет consumer, поэтому группа должна быть приостановлена командой pause
+	admin := services.NewOffsetAdmin(consumerCfg, nil)
+	result, err := admin.Reset(context.Background(), request)
+	printResult(result)
+	if err != nil {
+		log.Fatalf("Ошибка сброса смещений: %v", err)
+	}
+}
+
+// printResult выводит результат сброса по партициям
+func printResult(result services.OffsetResetResult) {
+	fmt.Printf("Группа %s, топик %s, режим %s", result.GroupID, result.Topic, result.Mode)
+	if result.DryRun {
+		fmt.Print(" (dry-run)")
+	}
+	fmt.Println()
+	for _, p := range result.Partitions {
+		fmt.Printf("  партиция %d: %d -> %d, перечитывается %d\n", p.Partition, p.CurrentOffset, p.TargetOffset, p.Replay)
+		if p.Error != "" {
+			fmt.Printf("    ошибка: %s\n", p.Error)
+		}
+		for _, r := range p.Sample {
+			fmt.Printf("    %d %s key=%s %s\n", r.Offset, r.Time.Format(time.RFC3339), r.Key, r.Value)
+		}
+	}
+	if result.Applied {
+		fmt.Println("Смещения применены")
+	}
+}
+
+// parseOffsets разбирает смещения партиций в формате "0=100,1=250"
+func parseOffsets(value string) (map[int]int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	offsets := make(map[int]int64)
+	for _, item := range strings.Split(value, ",") {
+		partition, offset, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return nil, fmt.Errorf("ожидается партиция=смещение: %q", item)
+		}
+		p, err := strconv.Atoi(partition)
+		if err != nil {
+			return nil, err
+		}
+		o, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		offsets[p] = o
+	}
+	return offsets, nil
+}
+
+// parsePartitions разбирает список партиций через запятую
+func parsePartitions(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var partitions []int
+	for _, item := range strings.Split(value, ",") {
+		p, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, p)
+	}
+	return partitions, nil
+}
@@ -3,10 +3,10 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/swagger" // Импортируем пакет для Swagger
-	"github.com/segmentio/kafka-go"
+	"github.com/nats-io/nats-server/v2/server"
 	"github.com/swaggo/fiber-swagger"
 	"go_microsvc/config"   // Импортируем пакет для загрузки конфигурации
 	"go_microsvc/database" // Импортируем пакет для подключения к базе данных
@@ -14,10 +14,8 @@ import (
 	"go_microsvc/routes"
 	"go_microsvc/services"
 	"log" // Импортируем пакет для логирования
-	"net"
 	"os"
 	"os/signal"
-	"strconv"
 	"syscall"
 	"time"
 )
@@ -30,31 +28,100 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel() // Гарантируем, что контекст будет отменен при выходе из main
 
-	// Вызов функции создания топика
-	err := createKafkaTopic("kafka:9092", "new-topic-2", 1, 1)
-	if err != nil {
-		log.Fatalf("Ошибка: %v\n", err)
-	}
-
 	// 3. Подключение к базе данных
 	db, err := database.ConnectDB(cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresDB, cfg.PostgresHost, cfg.PostgresPort)
 	if err != nil {
 		log.Fatalf("Ошибка подключения к базе данных: %v", err)
 	}
 
-	// 4. Запуск Kafka consumer в отдельной горутине
-	go services.StartKafkaConsumer(ctx, db, cfg.KafkaBootstrapServers, cfg.KafkaTopic)
+	// Стратегия ключа и балансировщик определяют распределение сообщений по партициям
+	keys, err := services.NewKeyStrategy(cfg.KafkaKeyStrategy)
+	if err != nil {
+		log.Fatalf("Ошибка конфигурации Kafka: %v", err)
+	}
+	balancer, err := services.NewBalancer(cfg.KafkaBalancer)
+	if err != nil {
+		log.Fatalf("Ошибка конфигурации Kafka: %v", err)
+	}
+
+	consumerCfg := services.NewConsumerConfig(cfg)
+	if err := consumerCfg.Validate(); err != nil {
+		log.Fatalf("Ошибка конфигурации Kafka consumer: %v", err)
+	}
 
-	// 5. Запуск Kafka producer в отдельной горутине
-	go func() {
-		if err := services.NewKafkaProducer(ctx, cfg.KafkaBootstrapServers, cfg.KafkaTopic); err != nil {
-			log.Printf("Ошибка в работе Kafka Producer: %v", err)
+	// Встроенный сервер NATS позволяет работать без внешнего брокера; producer и consumer подключаются к нему
+	var natsServer *server.Server
+	if cfg.Broker == services.BrokerNATS && cfg.NatsEmbedded {
+		natsServer, err = services.StartEmbeddedNATS(services.EmbeddedNATSConfig{
+			Host:     cfg.NatsEmbeddedHost,
+			Port:     cfg.NatsEmbeddedPort,
+			StoreDir: cfg.NatsStoreDir,
+		})
+		if err != nil {
+			log.Fatalf("Ошибка запуска встроенного сервера NATS: %v", err)
 		}
-	}()
+		cfg.NatsURL = natsServer.ClientURL()
+	}
+
+	// 5. Создание общего producer, который используется всеми обработчиками, и подписчика consumer.
+	// MESSAGE_BROKER=memory заменяет Kafka брокером в памяти процесса для локального запуска
+	producer, subscriber, err := services.NewBroker(cfg, db, services.ProducerConfig{
+		Brokers:      cfg.KafkaBootstrapServers,
+		Topic:        cfg.KafkaTopic,
+		Balancer:     balancer,
+		Async:        cfg.ProducerAsync,
+		MaxAttempts:  cfg.ProducerMaxAttempts,
+		BatchSize:    cfg.ProducerBatchSize,
+		BatchTimeout: cfg.ProducerBatchTimeout,
+		RequiredAcks: cfg.ProducerRequiredAcks,
+	}, consumerCfg)
+	if err != nil {
+		log.Fatalf("Ошибка конфигурации брокера сообщений: %v", err)
+	}
+
+	// 4. Запуск Kafka consumer в отдельной горутине. Записи с ошибкой обработки
+	// повторяются через retry топики, необрабатываемые записи отправляются в DLQ
+	dlq := services.NewDeadLetterQueue(db, producer, cfg.KafkaDLQTopic)
+	retries := services.NewRetryRouterFromConfig(producer, dlq, cfg)
+
+	// Топики приводятся к объявленной конфигурации до запуска consumer. Ошибки не прерывают запуск:
+	// брокер может быть временно недоступен, а расхождения конфигурации только логируются
+	topics := services.NewTopicManager(cfg.KafkaBootstrapServers)
+	if cfg.KafkaProvisionTopics && services.IsKafkaBroker(cfg.Broker) {
+		if _, err := topics.Reconcile(ctx, services.TopicSpecsFromConfig(cfg, retries)); err != nil {
+			log.Printf("Ошибка сверки топиков Kafka: %v", err)
+		}
+	}
+	// Обработчики выбираются по типу сообщения; собственные обработчики регистрируются через handlers.Register
+	handlers := services.NewHandlerRegistry(db, consumerCfg)
+	consumer := services.NewConsumer(db, subscriber, handlers, consumerCfg, retries)
+	if err := consumer.Start(ctx); err != nil {
+		log.Fatalf("Ошибка запуска Kafka consumer: %v", err)
+	}
+
+	// Запуск outbox relay, публикующего сохраненные события в Kafka
+	relay := services.NewOutboxRelay(db, producer, services.OutboxRelayConfig{
+		PollInterval: cfg.OutboxPollInterval,
+		BatchSize:    cfg.OutboxBatchSize,
+		BaseBackoff:  cfg.OutboxBaseBackoff,
+		MaxBackoff:   cfg.OutboxMaxBackoff,
+		ClaimTimeout: cfg.OutboxClaimTimeout,
+	})
+	go relay.Run(ctx)
+
+	// Пометка сообщений, срок действия которых истек до обработки
+	expiry := services.NewExpiryJob(db, services.ExpiryJobConfig{
+		Interval:  cfg.ExpiryInterval,
+		BatchSize: cfg.ExpiryBatchSize,
+	})
+	go expiry.Run(ctx)
 
 	// 6. Создание нового Fiber приложения
 	app := fiber.New()
 
+	// ID запроса передается в заголовке X-Request-ID и используется как ID корреляции сообщений
+	app.Use(requestid.New())
+
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.Redirect("/swagger/index.html")
 	})
@@ -66,7 +133,37 @@ func main() {
 	app.Get("/docs/*", fiberSwagger.WrapHandler)
 
 	// 7. Настройка маршрутов приложения из отдельного пакета
-	routes.SetupRoutes(app, db)
+	messages := services.NewMessageService(db, services.MessageServiceConfig{
+		Topic:         cfg.KafkaTopic,
+		Keys:          keys,
+		SourceService: cfg.ServiceName,
+		Relay:         relay,
+		MaxBatchItems: cfg.BatchMaxItems,
+	})
+
+	// Планировщик создает сообщения расписаний через MessageService; экземпляры API с общей базой
+	// не выполняют один запуск дважды. SCHEDULER_ENABLED=false оставляет только API расписаний
+	schedules := services.NewScheduleService(db, messages, services.SchedulerConfig{
+		PollInterval: cfg.SchedulerPollInterval,
+		MissedGrace:  cfg.SchedulerMissedGrace,
+		MaxCatchUp:   cfg.SchedulerMaxCatchUp,
+	})
+	if cfg.SchedulerEnabled {
+		go schedules.Run(ctx)
+	}
+	routes.SetupRoutes(app, routes.Dependencies{
+		Broker:    cfg.Broker,
+		DB:        db,
+		Messages:  messages,
+		DLQ:       dlq,
+		Consumer:  consumer,
+		Offsets:   services.NewOffsetAdmin(consumerCfg, consumer.PauseController()),
+		Audit:     services.NewAuditLog(db),
+		Attempts:  services.NewAttemptLog(db),
+		Schedules: schedules,
+		Monitor:   services.NewConsumerMonitor(consumerCfg, retries),
+		Topics:    topics,
+	})
 
 	// 8. Обработка сигнала завершения для корректного завершения работы
 	c := make(chan os.Signal, 1)
@@ -91,66 +188,31 @@ func main() {
 
 	log.Println("Контекст отменен. Завершение работы...")
 
+	// Основной контекст уже отменен, поэтому для завершения используем отдельный контекст с таймаутом
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
 	// 12. Закрытие приложения Fiber с передачей контекста
-	if err := app.ShutdownWithContext(ctx); err != nil {
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
 		log.Printf("Ошибка при завершении работы Fiber: %v", err)
 	}
 
-	// Ждем немного времени, чтобы дать завершиться всем горутинам
-	time.Sleep(5 * time.Second)
-
-	log.Println("Все процессы завершены. Завершение программы.")
-	os.Exit(0)
-}
-
-// Функция для создания топика в Kafka
-func createKafkaTopic(brokerAddress, topic string, numPartitions, replicationFactor int) error {
-	log.Printf("Подключение к брокеру Kafka: %s\n", brokerAddress)
-
-	// Устанавливаем соединение с брокером Kafka
-	conn, err := kafka.Dial("tcp", brokerAddress)
-	if err != nil {
-		return fmt.Errorf("ошибка подключения к Kafka: %v", err)
+	// Дожидаемся обработки прочитанных записей: producer нужен для отправки в retry топики и DLQ
+	if err := consumer.Stop(shutdownCtx); err != nil {
+		log.Printf("Kafka consumer остановлен до завершения обработки: %v", err)
 	}
-	defer func() {
-		if err := conn.Close(); err != nil {
-			log.Printf("Ошибка закрытия соединения с брокером: %v\n", err)
-		}
-	}()
 
-	// Получаем информацию о контроллере кластера
-	controller, err := conn.Controller()
-	if err != nil {
-		return fmt.Errorf("ошибка получения контроллера Kafka: %v", err)
-	}
-	log.Printf("Контроллер Kafka: %s:%d\n", controller.Host, controller.Port)
-
-	// Устанавливаем соединение с контроллером
-	controllerConn, err := kafka.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
-	if err != nil {
-		return fmt.Errorf("ошибка подключения к контроллеру Kafka: %v", err)
+	// 13. Доставка оставшихся сообщений и закрытие Kafka producer
+	if err := producer.Close(shutdownCtx); err != nil {
+		log.Printf("Ошибка при закрытии Kafka producer: %v", err)
 	}
-	defer func() {
-		if err := controllerConn.Close(); err != nil {
-			log.Printf("Ошибка закрытия соединения с контроллером: %v\n", err)
-		}
-	}()
-
-	// Конфигурация нового топика
-	topicConfigs := []kafka.TopicConfig{
-		{
-			Topic:             topic,
-			NumPartitions:     numPartitions,
-			ReplicationFactor: replicationFactor,
-		},
+	if natsServer != nil {
+		natsServer.Shutdown()
 	}
 
-	// Создаем топик
-	err = controllerConn.CreateTopics(topicConfigs...)
-	if err != nil {
-		return fmt.Errorf("ошибка создания топика: %v", err)
-	}
+	// Ждем немного времени, чтобы дать завершиться всем горутинам
+	time.Sleep(5 * time.Second)
 
-	log.Printf("Топик успешно создан: %s\n", topic)
-	return nil
+	log.Println("Все процессы завершены. Завершение программы.")
+	os.Exit(0)
 }
@@ -6,6 +6,10 @@ import (
 	"go_microsvc/database"
 	"go_microsvc/services"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -18,12 +22,51 @@ func main() {
 		log.Fatalf("Ошибка подключения к базе данных: %v", err)
 	}
 
-	ctx := context.Background()
-	// Настраиваем Kafka Reader и передаем его в сервис для обработки сообщений
-	err = services.ReadMessages2(ctx, db, cfg.KafkaBootstrapServers, cfg.KafkaTopic)
-	// "my_group" группа убрана пока
+	// Параметры чтения, включая группу потребителей, задаются конфигурацией
+	consumerCfg := services.NewConsumerConfig(cfg)
+	if err := consumerCfg.Validate(); err != nil {
+		log.Fatalf("Ошибка конфигурации Kafka consumer: %v", err)
+	}
+
+	// Producer нужен для отправки записей в retry топики и DLQ
+	producer, subscriber, err := services.NewBroker(cfg, db, services.ProducerConfig{
+		Brokers:      cfg.KafkaBootstrapServers,
+		Topic:        cfg.KafkaTopic,
+		MaxAttempts:  cfg.ProducerMaxAttempts,
+		RequiredAcks: cfg.ProducerRequiredAcks,
+	}, consumerCfg)
 	if err != nil {
-		log.Fatalf("Ошибка чтения сообщений из Kafka: %v", err)
+		log.Fatalf("Ошибка конфигурации брокера сообщений: %v", err)
 	}
+	dlq := services.NewDeadLetterQueue(db, producer, cfg.KafkaDLQTopic)
+	retries := services.NewRetryRouterFromConfig(producer, dlq, cfg)
 
+	// Топики приводятся к объявленной конфигурации; расхождения только логируются
+	if cfg.KafkaProvisionTopics && services.IsKafkaBroker(cfg.Broker) {
+		if _, err := services.NewTopicManager(cfg.KafkaBootstrapServers).Reconcile(context.Background(), services.TopicSpecsFromConfig(cfg, retries)); err != nil {
+			log.Printf("Ошибка сверки топиков Kafka: %v", err)
+		}
+	}
+
+	consumer := services.NewConsumer(db, subscriber, services.NewHandlerRegistry(db, consumerCfg), consumerCfg, retries)
+	if err := consumer.Start(context.Background()); err != nil {
+		log.Fatalf("Ошибка запуска Kafka consumer: %v", err)
+	}
+
+	// Ожидание сигнала завершения
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	log.Println("Завершение работы...")
+
+	// Прочитанные записи дорабатываются и фиксируются, но не дольше таймаута
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := consumer.Stop(shutdownCtx); err != nil {
+		log.Printf("Kafka consumer остановлен до завершения обработки: %v", err)
+	}
+	if err := producer.Close(shutdownCtx); err != nil {
+		log.Printf("Ошибка при закрытии Kafka producer: %v", err)
+	}
+	log.Println("Kafka consumer завершил работу")
 }
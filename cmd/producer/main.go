@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"go_microsvc/config"   // Импортируем модуль для работы с конфигурацией
 	"go_microsvc/services" // Импортируем сервис для отправки сообщений
 	"log"                  // Для логирования
@@ -18,11 +19,25 @@ func main() {
 	// Создаем сообщение для отправки в Kafka
 	message := map[string]string{"content": "Hello Kafka"}
 
-	// Отправляем сообщение в Kafka, используя конфигурацию
-	err := services.SendMessage(cfg.KafkaBootstrapServers, "message_topic", message)
+	// Создаем producer в синхронном режиме, чтобы дождаться подтверждения доставки
+	producer := services.NewKafkaProducer(services.ProducerConfig{
+		Brokers:     cfg.KafkaBootstrapServers,
+		Topic:       "message_topic",
+		MaxAttempts: cfg.ProducerMaxAttempts,
+	})
+	defer func() {
+		if err := producer.Close(context.Background()); err != nil {
+			log.Printf("Ошибка при закрытии producer: %v", err)
+		}
+	}()
 
-	// Проверяем на наличие ошибки при отправке
+	kafkaMsg, err := services.NewJSONMessage("key", message)
 	if err != nil {
+		log.Fatalf("Ошибка сериализации сообщения: %v", err)
+	}
+
+	// Отправляем сообщение в Kafka, используя конфигурацию
+	if err := producer.PublishSync(context.Background(), kafkaMsg); err != nil {
 		log.Fatalf("Ошибка отправки сообщения: %v", err)
 	}
 
@@ -5,6 +5,9 @@ import (
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -16,6 +19,106 @@ type Config struct {
 	PostgresPort          string
 	KafkaBrokers          string
 	KafkaTopic            string
+	ServiceName           string // Имя сервиса для заголовка source-service
+	Broker                string // Брокер сообщений: kafka, postgres, nats или memory
+
+	// Настройки Kafka producer
+	ProducerAsync        bool          // Асинхронная доставка по умолчанию
+	ProducerMaxAttempts  int           // Максимальное количество попыток отправки
+	ProducerBatchSize    int           // Максимальный размер пачки сообщений
+	ProducerBatchTimeout time.Duration // Максимальное время накопления пачки
+	ProducerRequiredAcks int           // Количество подтверждений от брокеров (-1, 0, 1)
+	KafkaKeyStrategy     string        // Стратегия ключа: message_id, partition_key, tenant, content_hash
+	KafkaBalancer        string        // Балансировщик: least_bytes, round_robin, hash, crc32, murmur2
+
+	// Настройки outbox relay
+	OutboxPollInterval time.Duration // Интервал опроса таблицы outbox
+	OutboxBatchSize    int           // Количество записей, публикуемых за одну итерацию
+	OutboxBaseBackoff  time.Duration // Начальная задержка повторной публикации
+	OutboxMaxBackoff   time.Duration // Максимальная задержка повторной публикации
+	OutboxClaimTimeout time.Duration // Время, на которое выбранные записи скрываются от других relay до подтверждения публикации
+
+	BatchMaxItems int // Максимальное количество сообщений в пакетном запросе
+
+	// Настройки пометки просроченных сообщений
+	ExpiryInterval  time.Duration // Интервал поиска сообщений с истекшим сроком действия
+	ExpiryBatchSize int           // Количество сообщений, помечаемых за одну транзакцию
+
+	// Настройки очереди в Postgres (MESSAGE_BROKER=postgres)
+	QueuePollInterval      time.Duration // Интервал опроса таблицы заданий при пустой очереди
+	QueueBatchSize         int           // Количество заданий, захватываемых за один запрос
+	QueueVisibilityTimeout time.Duration // Время, на которое захваченное задание скрывается от других потребителей
+	QueueMaxDeliveries     int           // Количество выдач задания без подтверждения, после которого оно помечается dead
+
+	// Настройки NATS JetStream (MESSAGE_BROKER=nats)
+	NatsURL           string        // Адрес сервера NATS; при встроенном сервере не используется
+	NatsStream        string        // Поток JetStream для записей всех топиков
+	NatsSubjectPrefix string        // Префикс subject топиков
+	NatsAckWait       time.Duration // Время на подтверждение записи до повторной доставки
+	NatsMaxDeliver    int           // Максимальное количество доставок записи; -1 — без ограничения
+	NatsEmbedded      bool          // Запускать встроенный сервер NATS в процессе API
+	NatsEmbeddedHost  string        // Адрес прослушивания встроенного сервера
+	NatsEmbeddedPort  int           // Порт встроенного сервера
+	NatsStoreDir      string        // Каталог хранения JetStream встроенного сервера
+
+	// Настройки планировщика расписаний
+	SchedulerEnabled      bool          // Выполнять расписания в этом экземпляре API
+	SchedulerPollInterval time.Duration // Интервал проверки наступивших запусков
+	SchedulerMissedGrace  time.Duration // Задержка, после которой запуск считается пропущенным
+	SchedulerMaxCatchUp   int           // Максимальное количество пропущенных запусков, обрабатываемых за раз
+
+	// Настройки Kafka consumer
+	KafkaDLQTopic        string        // Dead-letter топик, по умолчанию <KAFKA_TOPIC>.dlq
+	ConsumerRetryBackoff time.Duration // Задержка между попытками публикации в retry и DLQ топики
+	ConsumerWorkers      int           // Количество параллельных обработчиков с сохранением порядка по ключу
+	ConsumerQueueSize    int           // Размер очереди каждого обработчика
+	ConsumerBatchSize    int           // Размер пачки; больше 1 включает пакетный режим
+	ConsumerBatchLinger  time.Duration // Максимальное время ожидания пачки
+
+	// Веса приоритетов при чтении топиков приоритетов: high=6,normal=3,low=1 означает, что при очереди
+	// во всех топиках из каждых 10 записей 6 читаются из топика high, 3 — normal и 1 — low
+	ConsumerPriorityWeights map[string]int
+
+	// Обработчики сообщений
+	ConsumerUnknownType         string        // Обработка сообщений неизвестного типа: dlq, skip или тип обработчика
+	ConsumerHandlerRetries      int           // Повторные попытки обработчика до отправки в retry топик
+	ConsumerHandlerRetryBackoff time.Duration // Задержка между повторными попытками обработчика
+
+	// Параметры чтения Kafka
+	ConsumerGroupID           string        // Группа потребителей
+	ConsumerStartOffset       string        // first или last для группы без зафиксированных смещений
+	ConsumerMinBytes          int           // Минимальный объем ответа fetch
+	ConsumerMaxBytes          int           // Максимальный объем ответа fetch
+	ConsumerMaxWait           time.Duration // Максимальное ожидание MinBytes
+	ConsumerCommitInterval    time.Duration // 0 — синхронная фиксация смещений
+	ConsumerSessionTimeout    time.Duration // Таймаут сессии участника группы
+	ConsumerHeartbeatInterval time.Duration // Интервал heartbeat
+	ConsumerRebalanceTimeout  time.Duration // Время на завершение перебалансировки
+	ConsumerIsolationLevel    string        // read_uncommitted или read_committed
+
+	// Политики повторной обработки через retry топики
+	RetryDefault  RetryPolicy            // Политика по умолчанию
+	RetryPolicies map[string]RetryPolicy // Политики для отдельных топиков
+
+	// Топики, создаваемые и проверяемые при запуске
+	KafkaProvisionTopics bool        // Сверять топики с конфигурацией при запуске
+	KafkaTopics          []TopicSpec // Объявленные топики; основной топик объявляется всегда
+}
+
+// TopicSpec описывает желаемую конфигурацию топика
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	Retention         time.Duration // retention.ms; 0 — значение брокера
+	CleanupPolicy     string        // cleanup.policy: delete, compact или "compact,delete"; пусто — значение брокера
+	Compression       string        // compression.type: producer, gzip, snappy, lz4, zstd, uncompressed; пусто — значение брокера
+}
+
+// RetryPolicy задает уровни задержки retry топиков и общее количество попыток обработки
+type RetryPolicy struct {
+	Tiers       []time.Duration // Задержки уровней: <topic>.retry.1m, <topic>.retry.10m, ...
+	MaxAttempts int             // Количество попыток обработки до отправки в DLQ
 }
 
 func LoadConfig() Config {
@@ -24,6 +127,23 @@ func LoadConfig() Config {
 		log.Fatalf("Error loading .env file")
 	}
 
+	topic := os.Getenv("KAFKA_TOPIC")
+	retryTiers := parseDurations("KAFKA_RETRY_TIERS", getEnv("KAFKA_RETRY_TIERS", "1m,10m"))
+	retryDefault := RetryPolicy{
+		Tiers:       retryTiers,
+		MaxAttempts: getEnvInt("KAFKA_RETRY_MAX_ATTEMPTS", len(retryTiers)+1),
+	}
+
+	// Параметры основного топика; они же используются по умолчанию для остальных объявленных топиков
+	topicDefaults := TopicSpec{
+		Name:              topic,
+		Partitions:        getEnvInt("KAFKA_TOPIC_PARTITIONS", 1),
+		ReplicationFactor: getEnvInt("KAFKA_TOPIC_REPLICATION_FACTOR", 1),
+		Retention:         getEnvDuration("KAFKA_TOPIC_RETENTION", 0),
+		CleanupPolicy:     os.Getenv("KAFKA_TOPIC_CLEANUP_POLICY"),
+		Compression:       os.Getenv("KAFKA_TOPIC_COMPRESSION"),
+	}
+
 	return Config{
 		KafkaBootstrapServers: os.Getenv("KAFKA_BOOTSTRAP_SERVERS"),
 		PostgresUser:          os.Getenv("POSTGRES_USER"),
@@ -32,6 +152,270 @@ func LoadConfig() Config {
 		PostgresHost:          os.Getenv("POSTGRES_HOST"),
 		PostgresPort:          os.Getenv("POSTGRES_PORT"),
 		KafkaBrokers:          os.Getenv("KAFKA_BROKERS"),
-		KafkaTopic:            os.Getenv("KAFKA_TOPIC"),
+		KafkaTopic:            topic,
+		ServiceName:           getEnv("SERVICE_NAME", "go_microsvc"),
+		Broker:                getEnv("MESSAGE_BROKER", "kafka"),
+
+		ProducerAsync:        getEnvBool("KAFKA_PRODUCER_ASYNC", false),
+		ProducerMaxAttempts:  getEnvInt("KAFKA_PRODUCER_MAX_ATTEMPTS", 3),
+		ProducerBatchSize:    getEnvInt("KAFKA_PRODUCER_BATCH_SIZE", 100),
+		ProducerBatchTimeout: getEnvDuration("KAFKA_PRODUCER_BATCH_TIMEOUT", 10*time.Millisecond),
+		ProducerRequiredAcks: getEnvInt("KAFKA_PRODUCER_REQUIRED_ACKS", -1),
+		KafkaKeyStrategy:     getEnv("KAFKA_KEY_STRATEGY", "partition_key"),
+		KafkaBalancer:        getEnv("KAFKA_BALANCER", "hash"),
+
+		OutboxPollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", 500*time.Millisecond),
+		OutboxBatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxBaseBackoff:  getEnvDuration("OUTBOX_BASE_BACKOFF", time.Second),
+		OutboxMaxBackoff:   getEnvDuration("OUTBOX_MAX_BACKOFF", time.Minute),
+		OutboxClaimTimeout: getEnvDuration("OUTBOX_CLAIM_TIMEOUT", 30*time.Second),
+
+		BatchMaxItems: getEnvInt("BATCH_MAX_ITEMS", 1000),
+
+		ExpiryInterval:  getEnvDuration("MESSAGE_EXPIRY_INTERVAL", 30*time.Second),
+		ExpiryBatchSize: getEnvInt("MESSAGE_EXPIRY_BATCH_SIZE", 500),
+
+		QueuePollInterval:      getEnvDuration("QUEUE_POLL_INTERVAL", 500*time.Millisecond),
+		QueueBatchSize:         getEnvInt("QUEUE_BATCH_SIZE", 10),
+		QueueVisibilityTimeout: getEnvDuration("QUEUE_VISIBILITY_TIMEOUT", 30*time.Second),
+		QueueMaxDeliveries:     getEnvInt("QUEUE_MAX_DELIVERIES", 5),
+
+		NatsURL:           getEnv("NATS_URL", "nats://127.0.0.1:4222"),
+		NatsStream:        getEnv("NATS_STREAM", "MESSAGES"),
+		NatsSubjectPrefix: getEnv("NATS_SUBJECT_PREFIX", "topics"),
+		NatsAckWait:       getEnvDuration("NATS_ACK_WAIT", 30*time.Second),
+		NatsMaxDeliver:    getEnvInt("NATS_MAX_DELIVER", 5),
+		NatsEmbedded:      getEnvBool("NATS_EMBEDDED", false),
+		NatsEmbeddedHost:  getEnv("NATS_EMBEDDED_HOST", "127.0.0.1"),
+		NatsEmbeddedPort:  getEnvInt("NATS_EMBEDDED_PORT", 4222),
+		NatsStoreDir:      getEnv("NATS_STORE_DIR", "/tmp/nats"),
+
+		SchedulerEnabled:      getEnvBool("SCHEDULER_ENABLED", true),
+		SchedulerPollInterval: getEnvDuration("SCHEDULER_POLL_INTERVAL", time.Second),
+		SchedulerMissedGrace:  getEnvDuration("SCHEDULER_MISSED_GRACE", time.Minute),
+		SchedulerMaxCatchUp:   getEnvInt("SCHEDULER_MAX_CATCH_UP", 100),
+
+		KafkaDLQTopic:        getEnv("KAFKA_DLQ_TOPIC", topic+".dlq"),
+		ConsumerRetryBackoff: getEnvDuration("CONSUMER_RETRY_BACKOFF", time.Second),
+		ConsumerWorkers:      getEnvInt("CONSUMER_WORKERS", 1),
+		ConsumerQueueSize:    getEnvInt("CONSUMER_QUEUE_SIZE", 100),
+		ConsumerBatchSize:    getEnvInt("CONSUMER_BATCH_SIZE", 1),
+		ConsumerBatchLinger:  getEnvDuration("CONSUMER_BATCH_LINGER", 200*time.Millisecond),
+
+		ConsumerPriorityWeights: parseWeights("CONSUMER_PRIORITY_WEIGHTS", getEnv("CONSUMER_PRIORITY_WEIGHTS", "high=6,normal=3,low=1")),
+
+		ConsumerUnknownType:         getEnv("CONSUMER_UNKNOWN_TYPE", "dlq"),
+		ConsumerHandlerRetries:      getEnvInt("CONSUMER_HANDLER_RETRIES", 0),
+		ConsumerHandlerRetryBackoff: getEnvDuration("CONSUMER_HANDLER_RETRY_BACKOFF", 100*time.Millisecond),
+
+		ConsumerGroupID:           getEnv("CONSUMER_GROUP_ID", "my_consumer_group"),
+		ConsumerStartOffset:       getEnv("CONSUMER_START_OFFSET", "first"),
+		ConsumerMinBytes:          getEnvInt("CONSUMER_MIN_BYTES", 10e3),
+		ConsumerMaxBytes:          getEnvInt("CONSUMER_MAX_BYTES", 10e6),
+		ConsumerMaxWait:           getEnvDuration("CONSUMER_MAX_WAIT", 10*time.Second),
+		ConsumerCommitInterval:    getEnvDuration("CONSUMER_COMMIT_INTERVAL", 0),
+		ConsumerSessionTimeout:    getEnvDuration("CONSUMER_SESSION_TIMEOUT", 30*time.Second),
+		ConsumerHeartbeatInterval: getEnvDuration("CONSUMER_HEARTBEAT_INTERVAL", 3*time.Second),
+		ConsumerRebalanceTimeout:  getEnvDuration("CONSUMER_REBALANCE_TIMEOUT", 30*time.Second),
+		ConsumerIsolationLevel:    getEnv("CONSUMER_ISOLATION_LEVEL", "read_uncommitted"),
+
+		RetryDefault:  retryDefault,
+		RetryPolicies: parseRetryPolicies(os.Getenv("KAFKA_RETRY_POLICIES"), retryDefault),
+
+		KafkaProvisionTopics: getEnvBool("KAFKA_PROVISION_TOPICS", true),
+		KafkaTopics:          parseTopicSpecs(os.Getenv("KAFKA_TOPICS"), topicDefaults),
+	}
+}
+
+// getEnv возвращает значение переменной окружения или значение по умолчанию
+func getEnv(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// getEnvInt возвращает целочисленное значение переменной окружения или значение по умолчанию
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, используется %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// getEnvBool возвращает логическое значение переменной окружения или значение по умолчанию
+func getEnvBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, используется %t", key, value, def)
+		return def
+	}
+	return b
+}
+
+// getEnvDuration возвращает длительность из переменной окружения (например, "500ms", "5s") или значение по умолчанию
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Некорректное значение %s=%q, используется %s", key, value, def)
+		return def
+	}
+	return d
+}
+
+// parseDurations разбирает список длительностей через запятую, пропуская некорректные значения
+func parseDurations(key, value string) []time.Duration {
+	var result []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil || d <= 0 {
+			log.Printf("Некорректная длительность в %s: %q", key, part)
+			continue
+		}
+		result = append(result, d)
+	}
+	return result
+}
+
+// parseWeights разбирает веса в формате "high=6,normal=3,low=1", пропуская некорректные значения
+func parseWeights(key, value string) map[string]int {
+	weights := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, weight, ok := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(weight))
+		if !ok || err != nil || n <= 0 {
+			log.Printf("Некорректный вес в %s: %q", key, entry)
+			continue
+		}
+		weights[strings.TrimSpace(name)] = n
+	}
+	return weights
+}
+
+// parseRetryPolicies разбирает политики повторной обработки для отдельных топиков в формате
+// "topic=1m,10m:5;other=30s:2", где после двоеточия указывается количество попыток (необязательно)
+func parseRetryPolicies(value string, def RetryPolicy) map[string]RetryPolicy {
+	policies := make(map[string]RetryPolicy)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		topic, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			log.Printf("Некорректная политика в KAFKA_RETRY_POLICIES: %q", entry)
+			continue
+		}
+
+		tiersSpec, attemptsSpec, hasAttempts := strings.Cut(spec, ":")
+		policy := RetryPolicy{Tiers: parseDurations("KAFKA_RETRY_POLICIES", tiersSpec)}
+		policy.MaxAttempts = len(policy.Tiers) + 1
+		if hasAttempts {
+			n, err := strconv.Atoi(strings.TrimSpace(attemptsSpec))
+			if err != nil || n <= 0 {
+				log.Printf("Некорректное количество попыток в KAFKA_RETRY_POLICIES: %q", entry)
+				n = def.MaxAttempts
+			}
+			policy.MaxAttempts = n
+		}
+		policies[strings.TrimSpace(topic)] = policy
+	}
+	return policies
+}
+
+// parseTopicSpecs разбирает объявления топиков в формате
+// "name:partitions:replication:retention=168h,cleanup=compact+delete,compression=lz4;other:3",
+// где все поля после имени необязательны и по умолчанию берутся из def.
+// Основной топик (def.Name) добавляется, если он не объявлен явно.
+func parseTopicSpecs(value string, def TopicSpec) []TopicSpec {
+	var specs []TopicSpec
+	declared := make(map[string]bool)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fields := strings.SplitN(entry, ":", 4)
+		spec := def
+		spec.Name = strings.TrimSpace(fields[0])
+		if spec.Name == "" {
+			log.Printf("Некорректный топик в KAFKA_TOPICS: %q", entry)
+			continue
+		}
+		if len(fields) > 1 && strings.TrimSpace(fields[1]) != "" {
+			n, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+			if err != nil || n <= 0 {
+				log.Printf("Некорректное количество партиций в KAFKA_TOPICS: %q", entry)
+			} else {
+				spec.Partitions = n
+			}
+		}
+		if len(fields) > 2 && strings.TrimSpace(fields[2]) != "" {
+			n, err := strconv.Atoi(strings.TrimSpace(fields[2]))
+			if err != nil || n <= 0 {
+				log.Printf("Некорректный фактор репликации в KAFKA_TOPICS: %q", entry)
+			} else {
+				spec.ReplicationFactor = n
+			}
+		}
+		if len(fields) > 3 {
+			for _, option := range strings.Split(fields[3], ",") {
+				key, val, _ := strings.Cut(strings.TrimSpace(option), "=")
+				switch strings.TrimSpace(key) {
+				case "":
+				case "retention":
+					d, err := time.ParseDuration(strings.TrimSpace(val))
+					if err != nil || d <= 0 {
+						log.Printf("Некорректный retention в KAFKA_TOPICS: %q", entry)
+						continue
+					}
+					spec.Retention = d
+				case "cleanup":
+					// Несколько политик перечисляются через "+": cleanup=compact+delete
+					spec.CleanupPolicy = strings.ReplaceAll(strings.TrimSpace(val), "+", ",")
+				case "compression":
+					spec.Compression = strings.TrimSpace(val)
+				default:
+					log.Printf("Неизвестный параметр топика в KAFKA_TOPICS: %q", option)
+				}
+			}
+		}
+		if declared[spec.Name] {
+			log.Printf("Топик %s объявлен в KAFKA_TOPICS повторно, используется последнее объявление", spec.Name)
+			for i := range specs {
+				if specs[i].Name == spec.Name {
+					specs[i] = spec
+				}
+			}
+			continue
+		}
+		declared[spec.Name] = true
+		specs = append(specs, spec)
+	}
+	if def.Name != "" && !declared[def.Name] {
+		specs = append([]TopicSpec{def}, specs...)
 	}
+	return specs
 }
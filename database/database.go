@@ -33,10 +33,35 @@ func ConnectDB(user, password, dbname, host, port string) (*Database, error) {
 	log.Println("Успешное подключение к базе данных")
 
 	// Это должен быть код, который выполняется при инициализации приложения
-	err = db.AutoMigrate(&models.Message{})
+	err = Migrate(db)
 	if err != nil {
 		log.Fatalf("Ошибка миграции базы данных: %v", err)
 	}
 
+	// Флаг processed заменен статусом сообщения: обработанные сообщения переносятся в статус processed
+	if db.Migrator().HasColumn(&models.Message{}, "processed") {
+		if err := db.Exec("UPDATE messages SET status = ? WHERE processed", models.MessageStatusProcessed).Error; err != nil {
+			log.Fatalf("Ошибка миграции статусов сообщений: %v", err)
+		}
+		if err := db.Migrator().DropColumn(&models.Message{}, "processed"); err != nil {
+			log.Fatalf("Ошибка миграции статусов сообщений: %v", err)
+		}
+	}
+
 	return &Database{db}, nil
 }
+
+// Migrate создает и обновляет таблицы моделей
+func Migrate(db *gorm.DB) error {
+	// В существующей истории попыток могут быть попытки удаленных сообщений, поэтому внешний ключ на messages
+	// создается NOT VALID: он проверяет только новые попытки, а существующая история сохраняется без изменений
+	if db.Migrator().HasTable(&models.MessageAttempt{}) && !db.Migrator().HasConstraint(&models.MessageAttempt{}, "Message") {
+		err := db.Exec(`ALTER TABLE message_attempts ADD CONSTRAINT fk_message_attempts_message
+			FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE NOT VALID`).Error
+		if err != nil {
+			return err
+		}
+		log.Println("Внешний ключ message_attempts.message_id создан без проверки существующих попыток")
+	}
+	return db.AutoMigrate(&models.Message{}, &models.OutboxRecord{}, &models.DeadLetter{}, &models.AuditEntry{}, &models.QueueJob{}, &models.MessageAttempt{}, &models.Schedule{}, &models.ScheduleRun{})
+}
@@ -15,9 +15,148 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/message": {
+        "/api/admin/audit": {
+            "get": {
+                "description": "Возвращает административные действия начиная с последних",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Журнал аудита",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Фильтр по действию, например topic.create",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Фильтр по объекту, например имя топика",
+                        "name": "resource",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Лимит",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AuditEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/consumers": {
+            "get": {
+                "description": "Для каждой группы и партиции возвращает зафиксированное смещение, смещение конца лога, отставание, время последней обработки и назначенного участника. Если состояние группы не удалось получить, ошибка возвращается в поле error группы, а остальные группы возвращаются полностью.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Отставание consumer",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.GroupStatus"
+                            }
+                        }
+                    },
+                    "501": {
+                        "description": "Брокер сообщений не Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "502": {
+                        "description": "Состояние не удалось получить ни для одной группы",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/consumers/pause": {
+            "post": {
+                "description": "Останавливает чтение всех топиков; readers покидают группы, после чего смещения можно сбросить. Группа считается остановленной, когда пауза выполнена на всех экземплярах.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Пауза consumer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Пользователь, выполняющий действие",
+                        "name": "X-Actor",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ConsumerPauseResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Не указан X-Actor",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "409": {
+                        "description": "Consumer не управляется этим процессом",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/consumers/reset-offsets": {
             "post": {
-                "description": "Создает новое сообщение и сохраняет его в базе данных",
+                "description": "Сбрасывает смещения группы на начало или конец лога, на время или на заданные смещения партиций. С dry_run только показывает, сколько и какие записи будут перечитаны. Сброс без dry_run записывается в журнал аудита.",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,41 +164,54 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Api"
+                    "Admin"
                 ],
-                "summary": "Создание сообщения",
+                "summary": "Сброс смещений",
                 "parameters": [
                     {
-                        "description": "Сообщение",
-                        "name": "message",
+                        "type": "string",
+                        "description": "Пользователь, выполняющий действие",
+                        "name": "X-Actor",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Параметры сброса",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.CreateMessageRequest"
+                            "$ref": "#/definitions/services.OffsetResetRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.CreateMessageResponse"
+                            "$ref": "#/definitions/services.OffsetResetResult"
                         }
                     },
                     "400": {
-                        "description": "Неверный формат данных",
+                        "description": "Некорректный запрос или не указан X-Actor",
                         "schema": {
                             "$ref": "#/definitions/fiber.Map"
                         }
                     },
-                    "422": {
-                        "description": "Ошибка валидации данных",
+                    "409": {
+                        "description": "Группа активна",
                         "schema": {
                             "$ref": "#/definitions/fiber.Map"
                         }
                     },
-                    "500": {
-                        "description": "Ошибка сервера или Kafka",
+                    "501": {
+                        "description": "Брокер сообщений не Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "502": {
+                        "description": "Ошибка Kafka",
                         "schema": {
                             "$ref": "#/definitions/fiber.Map"
                         }
@@ -67,16 +219,57 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/messages": {
+        "/api/admin/consumers/resume": {
+            "post": {
+                "description": "Возобновляет чтение с зафиксированных смещений групп",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Возобновление consumer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Пользователь, выполняющий действие",
+                        "name": "X-Actor",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ConsumerPauseResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Не указан X-Actor",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "409": {
+                        "description": "Consumer не управляется этим процессом",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/dlq": {
             "get": {
-                "description": "Возвращает список сообщений с учетом offset и limit",
+                "description": "Возвращает записи, отправленные в dead-letter топик, начиная с последних",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Api"
+                    "Admin"
                 ],
-                "summary": "Получение списка сообщений из базы данных",
+                "summary": "Список записей DLQ",
                 "parameters": [
                     {
                         "type": "integer",
@@ -95,11 +288,11 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Успешное получение сообщений",
+                        "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Message"
+                                "$ref": "#/definitions/models.DeadLetter"
                             }
                         }
                     },
@@ -118,97 +311,1998 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/stats": {
+        "/api/admin/dlq/{id}": {
             "get": {
-                "description": "Получает количество обработанных сообщений",
+                "description": "Возвращает исходную запись, заголовки и причину отправки в DLQ",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Api"
+                    "Admin"
+                ],
+                "summary": "Просмотр записи DLQ",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID записи DLQ",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Получение статистики обработанных сообщений consumer-ом",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "integer"
-                            }
+                            "$ref": "#/definitions/models.DeadLetter"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     },
                     "500": {
                         "description": "Ошибка сервера",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/fiber.Map"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "fiber.Map": {
-            "type": "object",
-            "additionalProperties": true
-        },
-        "gorm.DeletedAt": {
-            "type": "object",
-            "properties": {
-                "time": {
-                    "type": "string"
-                },
-                "valid": {
-                    "description": "Valid is true if Time is not NULL",
-                    "type": "boolean"
-                }
-            }
-        },
-        "models.CreateMessageRequest": {
-            "type": "object",
-            "properties": {
-                "content": {
-                    "description": "validate:\"required\"` + "`" + `",
-                    "type": "string"
-                }
-            }
         },
-        "models.CreateMessageResponse": {
-            "type": "object",
-            "properties": {
-                "content": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "processed": {
-                    "type": "boolean"
+        "/api/admin/dlq/{id}/redrive": {
+            "post": {
+                "description": "Публикует исходную запись с исходными заголовками обратно в исходный топик. Действие записывается в журнал аудита.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Повторная отправка записи DLQ",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Пользователь, выполняющий действие",
+                        "name": "X-Actor",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID записи DLQ",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.DeadLetter"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID или не указан X-Actor",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Запись не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера или Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
                 }
             }
         },
-        "models.Message": {
-            "type": "object",
-            "properties": {
-                "content": {
+        "/api/admin/topics": {
+            "get": {
+                "description": "Возвращает топики кластера с количеством партиций и фактором репликации. Служебные топики возвращаются с internal=true.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Topics"
+                ],
+                "summary": "Список топиков",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.TopicSummary"
+                            }
+                        }
+                    },
+                    "501": {
+                        "description": "Брокер сообщений не Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "502": {
+                        "description": "Ошибка Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Создает топик через контроллер кластера. Действие записывается в журнал аудита с пользователем из заголовка X-Actor.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Topics"
+                ],
+                "summary": "Создание топика",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Пользователь, выполняющий действие",
+                        "name": "X-Actor",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Параметры топика",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateTopicRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/services.TopicDescription"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректные параметры или не указан X-Actor",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "409": {
+                        "description": "Топик уже существует",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "501": {
+                        "description": "Брокер сообщений не Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "502": {
+                        "description": "Ошибка Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/topics/{name}": {
+            "get": {
+                "description": "Возвращает партиции с лидерами, репликами, ISR и смещениями, а также параметры топика",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Topics"
+                ],
+                "summary": "Описание топика",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Имя топика",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.TopicDescription"
+                        }
+                    },
+                    "404": {
+                        "description": "Топик не найден",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "501": {
+                        "description": "Брокер сообщений не Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "502": {
+                        "description": "Ошибка Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/admin/topics/{name}/partitions": {
+            "post": {
+                "description": "Увеличивает количество партиций топика до count. Уменьшение не поддерживается. Действие записывается в журнал аудита.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Topics"
+                ],
+                "summary": "Увеличение количества партиций",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Пользователь, выполняющий действие",
+                        "name": "X-Actor",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Имя топика",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новое количество партиций",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AddPartitionsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.TopicDescription"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное количество партиций или не указан X-Actor",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Топик не найден",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "501": {
+                        "description": "Брокер сообщений не Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "502": {
+                        "description": "Ошибка Kafka",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/health": {
+            "get": {
+                "description": "Возвращает состояние consumer, время последнего чтения и последнюю ошибку чтения. Код 503, если consumer остановлен или чтение завершается ошибкой.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Проверка работоспособности",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.ConsumerHealth"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/services.ConsumerHealth"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/message": {
+            "post": {
+                "description": "Сохраняет сообщение и событие для Kafka в одной транзакции. Событие публикуется в Kafka фоновым outbox relay.\nСообщение с deliver_at или delay сохраняется в статусе scheduled и публикуется в указанное время.\nСообщение с expires_at или ttl, не обработанное до истечения срока, не передается обработчику и переходит в статус expired.\nСообщения с priority high и low публикуются в топики \u003ctopic\u003e.high и \u003ctopic\u003e.low, которые consumer читает с учетом весов приоритетов.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Api"
+                ],
+                "summary": "Создание сообщения",
+                "parameters": [
+                    {
+                        "description": "Сообщение",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат данных",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "422": {
+                        "description": "Ошибка валидации данных",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages": {
+            "get": {
+                "description": "Возвращает список сообщений с учетом offset и limit, при необходимости только в указанных статусах",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Api"
+                ],
+                "summary": "Получение списка сообщений из базы данных",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Статусы через запятую: scheduled, cancelled, pending, published, processing, processed, failed, dead_lettered, expired",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Лимит",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Успешное получение сообщений",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Message"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/batch": {
+            "post": {
+                "description": "Принимает JSON массив или NDJSON (application/x-ndjson) с элементами CreateMessageRequest.\nКорректные элементы сохраняются одной транзакцией и публикуются в Kafka одним вызовом, ошибки возвращаются по каждому элементу.",
+                "consumes": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Api"
+                ],
+                "summary": "Пакетное создание сообщений",
+                "parameters": [
+                    {
+                        "description": "Сообщения",
+                        "name": "messages",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.CreateMessageRequest"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Все сообщения созданы",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchCreateResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Часть сообщений не прошла валидацию",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchCreateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат данных",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "413": {
+                        "description": "Превышен размер пакета",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "422": {
+                        "description": "Ни одно сообщение не прошло валидацию",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchCreateResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/{id}/attempts": {
+            "get": {
+                "description": "Возвращает попытки обработки сообщения consumer: время, экземпляр consumer, партицию и смещение, длительность, результат и ошибку",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Api"
+                ],
+                "summary": "История обработки сообщения",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID сообщения",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Лимит",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.MessageAttempt"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Сообщение не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/{id}/cancel": {
+            "post": {
+                "description": "Отменяет доставку сообщения в статусе scheduled. Опубликованное сообщение отменить нельзя.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Api"
+                ],
+                "summary": "Отмена отложенного сообщения",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID сообщения",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Сообщение не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "409": {
+                        "description": "Сообщение не в статусе scheduled",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/messages/{id}/reschedule": {
+            "post": {
+                "description": "Задает новое время доставки сообщения в статусе scheduled: deliver_at или delay от текущего момента",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Api"
+                ],
+                "summary": "Перенос отложенного сообщения",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID сообщения",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Новое время доставки",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ScheduleMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат данных",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Сообщение не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "409": {
+                        "description": "Сообщение не в статусе scheduled",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "422": {
+                        "description": "Некорректное время доставки или доставка после истечения срока действия",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/metrics": {
+            "get": {
+                "description": "Возвращает счетчики обработки записей, настройки и статистику пакетного режима",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Api"
+                ],
+                "summary": "Метрики consumer",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.Metrics"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules": {
+            "get": {
+                "description": "Возвращает расписания периодической отправки сообщений с учетом offset и limit",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Список расписаний",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Лимит",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Schedule"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Создает расписание: cron выражение, часовой пояс, шаблон содержимого сообщения и политику пропущенных запусков.\nШаблон text/template получает поля ScheduleID, Name, ScheduledAt и Missed.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Создание расписания",
+                "parameters": [
+                    {
+                        "description": "Параметры расписания",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ScheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат данных",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "422": {
+                        "description": "Некорректные параметры расписания",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Просмотр расписания",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID расписания",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Расписание не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Заменяет параметры расписания. Следующий запуск вычисляется от текущего времени; enabled без значения сохраняет текущее состояние.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Изменение расписания",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID расписания",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Параметры расписания",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ScheduleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Schedule"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный формат данных",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Расписание не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "422": {
+                        "description": "Некорректные параметры расписания",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Удаляет расписание и историю его запусков. Созданные сообщения сохраняются.",
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "Удаление расписания",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID расписания",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Неверный ID",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Расписание не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schedules/{id}/runs": {
+            "get": {
+                "description": "Возвращает запуски расписания начиная с последних: время по расписанию, результат, созданное сообщение и ошибку",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Schedules"
+                ],
+                "summary": "История запусков расписания",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID расписания",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Смещение",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Лимит",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.ScheduleRun"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Неверные параметры запроса",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "404": {
+                        "description": "Расписание не найдено",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/fiber.Map"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/stats": {
+            "get": {
+                "description": "Получает количество обработанных и просроченных сообщений, количество сообщений в каждом статусе\nи очередь по приоритетам: сообщения в статусах pending, published, processing и failed",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Api"
+                ],
+                "summary": "Получение статистики сообщений",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.MessageStats"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка сервера",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "fiber.Map": {
+            "type": "object",
+            "additionalProperties": true
+        },
+        "gorm.DeletedAt": {
+            "type": "object",
+            "properties": {
+                "time": {
+                    "type": "string"
+                },
+                "valid": {
+                    "description": "Valid is true if Time is not NULL",
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.AddPartitionsRequest": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Новое общее количество партиций",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ConsumerPauseResponse": {
+            "type": "object",
+            "properties": {
+                "paused": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.CreateTopicRequest": {
+            "type": "object",
+            "properties": {
+                "cleanup_policy": {
+                    "description": "delete, compact или \"compact,delete\"",
+                    "type": "string"
+                },
+                "compression": {
+                    "description": "producer, gzip, snappy, lz4, zstd, uncompressed",
+                    "type": "string"
+                },
+                "configs": {
+                    "description": "Дополнительные параметры топика",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "partitions": {
+                    "type": "integer"
+                },
+                "replication_factor": {
+                    "type": "integer"
+                },
+                "retention": {
+                    "description": "Например \"168h\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.AuditDetails": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "string"
+            }
+        },
+        "models.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Действие, например topic.create",
+                    "type": "string"
+                },
+                "actor": {
+                    "description": "Кто выполнил действие (заголовок X-Actor)",
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "$ref": "#/definitions/models.AuditDetails"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "request_id": {
+                    "description": "ID HTTP запроса",
+                    "type": "string"
+                },
+                "resource": {
+                    "description": "Объект действия, например имя топика",
+                    "type": "string"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.BatchCreateResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BatchItemResult"
+                    }
+                },
+                "published": {
+                    "description": "Сколько сообщений сразу опубликовано в Kafka, остальные опубликует outbox relay",
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Причина ошибки",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "ID созданного сообщения",
+                    "type": "integer"
+                },
+                "index": {
+                    "description": "Позиция элемента в запросе",
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "created или failed",
+                    "type": "string"
+                }
+            }
+        },
+        "models.CreateMessageRequest": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "description": "validate:\"required\"` + "`" + `",
+                    "type": "string"
+                },
+                "delay": {
+                    "description": "Задержка доставки от момента создания, например \"15m\"",
+                    "type": "string"
+                },
+                "deliver_at": {
+                    "description": "Отложенная доставка: указывается не более одного поля. Время в прошлом означает немедленную доставку",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "Срок действия: указывается не более одного поля. Сообщение, не обработанное до истечения срока,\nне передается обработчику и переходит в статус expired",
+                    "type": "string"
+                },
+                "partition_key": {
+                    "description": "Сообщения с одинаковым ключом обрабатываются по порядку",
+                    "type": "string"
+                },
+                "priority": {
+                    "description": "high, normal или low; по умолчанию normal",
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "description": "Идентификатор арендатора",
+                    "type": "string"
+                },
+                "ttl": {
+                    "description": "Срок действия от момента создания, например \"5m\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.CreateMessageResponse": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DeadLetter": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "Количество попыток обработки",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Текст последней ошибки",
+                    "type": "string"
+                },
+                "headers": {
+                    "description": "Заголовки исходной записи",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Headers"
+                        }
+                    ]
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "key": {
+                    "description": "Ключ исходной записи",
+                    "type": "string"
+                },
+                "original_offset": {
+                    "description": "Смещение исходной записи",
+                    "type": "integer"
+                },
+                "original_partition": {
+                    "description": "Партиция исходной записи",
+                    "type": "integer"
+                },
+                "original_topic": {
+                    "description": "Топик, из которого прочитана запись",
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "Причина: decode_error, processing_failed",
+                    "type": "string"
+                },
+                "redrive_count": {
+                    "description": "Сколько раз запись возвращалась в основной топик",
+                    "type": "integer"
+                },
+                "redriven_at": {
+                    "description": "Время последней повторной отправки",
+                    "type": "string"
+                },
+                "topic": {
+                    "description": "Dead-letter топик",
+                    "type": "string"
+                },
+                "value": {
+                    "description": "Тело исходной записи",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.Headers": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "string"
+            }
+        },
+        "models.Message": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "description": "Количество попыток обработки",
+                    "type": "integer"
+                },
+                "cancelled_at": {
+                    "type": "string"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "correlation_id": {
+                    "description": "Метаданные, которые также передаются в заголовках записи Kafka",
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deletedAt": {
+                    "$ref": "#/definitions/gorm.DeletedAt"
+                },
+                "deliver_at": {
+                    "description": "Время отложенной доставки",
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "Сообщение не обрабатывается после этого времени",
+                    "type": "string"
+                },
+                "failed_at": {
+                    "description": "Последняя неудачная попытка или отправка в DLQ",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "description": "Последняя ошибка обработки",
+                    "type": "string"
+                },
+                "partition_key": {
+                    "description": "Ключ упорядочивания, переданный клиентом",
+                    "type": "string"
+                },
+                "priority": {
+                    "description": "high, normal или low",
+                    "type": "string"
+                },
+                "processed_at": {
+                    "type": "string"
+                },
+                "published_at": {
+                    "type": "string"
+                },
+                "schema_version": {
+                    "description": "Версия схемы сообщения",
+                    "type": "string"
+                },
+                "source_service": {
+                    "description": "Сервис, создавший сообщение",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Статус жизненного цикла, см. MessageStatus*",
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.MessageAttempt": {
+            "type": "object",
+            "properties": {
+                "consumer": {
+                    "description": "Экземпляр consumer: хост и PID",
+                    "type": "string"
+                },
+                "duration_ms": {
+                    "description": "Длительность обработки",
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "message_id": {
+                    "description": "ID сообщения в таблице messages",
+                    "type": "integer"
+                },
+                "offset": {
+                    "description": "Смещение записи",
+                    "type": "integer"
+                },
+                "outcome": {
+                    "type": "string"
+                },
+                "partition": {
+                    "description": "Партиция записи",
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "topic": {
+                    "description": "Топик записи: основной или retry",
+                    "type": "string"
+                }
+            }
+        },
+        "models.MessageStats": {
+            "type": "object",
+            "properties": {
+                "backlog": {
+                    "description": "Количество сообщений с незавершенной доставкой по приоритетам",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "expired_messages": {
+                    "description": "Сообщения, срок действия которых истек до обработки",
+                    "type": "integer"
+                },
+                "processed_messages": {
+                    "type": "integer"
+                },
+                "statuses": {
+                    "description": "Количество сообщений в каждом статусе",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.Schedule": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "cron": {
+                    "description": "Cron выражение из пяти полей или дескриптор (@hourly, @every 15m)",
+                    "type": "string"
+                },
+                "enabled": {
+                    "description": "Выключенное расписание не запускается",
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_run_at": {
+                    "description": "Время последнего выполненного запуска",
+                    "type": "string"
+                },
+                "missed_run_policy": {
+                    "description": "skip, run_once или run_all",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "next_run_at": {
+                    "description": "Время следующего запуска",
+                    "type": "string"
+                },
+                "partition_key": {
+                    "description": "Ключ упорядочивания создаваемых сообщений",
                     "type": "string"
                 },
-                "createdAt": {
+                "template": {
+                    "description": "Шаблон text/template содержимого сообщения",
                     "type": "string"
                 },
-                "deletedAt": {
-                    "$ref": "#/definitions/gorm.DeletedAt"
+                "tenant_id": {
+                    "description": "Арендатор создаваемых сообщений",
+                    "type": "string"
+                },
+                "timezone": {
+                    "description": "Часовой пояс IANA, в котором вычисляется cron выражение",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ScheduleMessageRequest": {
+            "type": "object",
+            "properties": {
+                "delay": {
+                    "description": "Новая задержка от текущего момента, например \"1h\"",
+                    "type": "string"
+                },
+                "deliver_at": {
+                    "description": "Новое время доставки (RFC 3339)",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ScheduleRequest": {
+            "type": "object",
+            "properties": {
+                "cron": {
+                    "description": "Например \"0 9 * * 1-5\" или \"@every 15m\"",
+                    "type": "string"
+                },
+                "enabled": {
+                    "description": "По умолчанию true",
+                    "type": "boolean"
+                },
+                "missed_run_policy": {
+                    "description": "По умолчанию skip",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "partition_key": {
+                    "type": "string"
+                },
+                "template": {
+                    "description": "Например: Отчет за {{.ScheduledAt.Format \"2006-01-02\"}}",
+                    "type": "string"
+                },
+                "tenant_id": {
+                    "type": "string"
+                },
+                "timezone": {
+                    "description": "По умолчанию UTC",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ScheduleRun": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "processed": {
-                    "description": "Устанавливаем значение по умолчанию для processed",
+                "message_id": {
+                    "description": "Созданное сообщение",
+                    "type": "integer"
+                },
+                "missed": {
+                    "description": "Запуск выполнен или пропущен позже допустимой задержки",
                     "type": "boolean"
                 },
-                "updatedAt": {
+                "schedule_id": {
+                    "type": "integer"
+                },
+                "scheduled_at": {
+                    "description": "Время запуска по расписанию",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "succeeded, failed или skipped",
+                    "type": "string"
+                }
+            }
+        },
+        "services.ConsumerHealth": {
+            "type": "object",
+            "properties": {
+                "healthy": {
+                    "type": "boolean"
+                },
+                "last_error": {
+                    "description": "Последняя ошибка чтения",
+                    "type": "string"
+                },
+                "last_error_at": {
+                    "type": "string"
+                },
+                "last_record_at": {
+                    "description": "Последнее успешное чтение записи",
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "topics": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "services.GroupStatus": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Ошибка получения состояния; остальные поля могут быть неполными",
+                    "type": "string"
+                },
+                "group_id": {
+                    "type": "string"
+                },
+                "members": {
+                    "description": "Количество участников группы",
+                    "type": "integer"
+                },
+                "partitions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PartitionStatus"
+                    }
+                },
+                "state": {
+                    "description": "Состояние группы: Stable, PreparingRebalance, Empty, Dead",
+                    "type": "string"
+                },
+                "topic": {
+                    "type": "string"
+                },
+                "total_lag": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.HandlerMetrics": {
+            "type": "object",
+            "properties": {
+                "avg_duration_ms": {
+                    "description": "Средняя длительность обработки",
+                    "type": "number"
+                },
+                "failed": {
+                    "description": "Завершилось ошибкой",
+                    "type": "integer"
+                },
+                "handled": {
+                    "description": "Успешно обработано",
+                    "type": "integer"
+                }
+            }
+        },
+        "services.Metrics": {
+            "type": "object",
+            "properties": {
+                "avg_batch_size": {
+                    "description": "Средний размер пачки",
+                    "type": "number"
+                },
+                "batch_fallbacks": {
+                    "description": "Пачек, обработанных по одной записи после ошибки bulk upsert",
+                    "type": "integer"
+                },
+                "batch_linger_ms": {
+                    "description": "Настроенное время ожидания пачки",
+                    "type": "integer"
+                },
+                "batch_size": {
+                    "description": "Настроенный размер пачки",
+                    "type": "integer"
+                },
+                "batches_processed": {
+                    "description": "Обработано пачек",
+                    "type": "integer"
+                },
+                "handlers": {
+                    "description": "Метрики обработчиков по типам сообщений",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/services.HandlerMetrics"
+                    }
+                },
+                "last_batch_duration_ms": {
+                    "description": "Длительность обработки последней пачки",
+                    "type": "number"
+                },
+                "last_batch_size": {
+                    "description": "Размер последней пачки",
+                    "type": "integer"
+                },
+                "records_consumed": {
+                    "description": "Прочитано записей",
+                    "type": "integer"
+                },
+                "records_dead_lettered": {
+                    "description": "Отправлено в DLQ",
+                    "type": "integer"
+                },
+                "records_expired": {
+                    "description": "Пропущено после истечения срока действия",
+                    "type": "integer"
+                },
+                "records_processed": {
+                    "description": "Успешно обработано записей",
+                    "type": "integer"
+                },
+                "records_retried": {
+                    "description": "Отправлено в retry топики",
+                    "type": "integer"
+                }
+            }
+        },
+        "services.OffsetResetRequest": {
+            "type": "object",
+            "properties": {
+                "dry_run": {
+                    "description": "Только показать, что будет перечитано",
+                    "type": "boolean"
+                },
+                "group_id": {
+                    "description": "По умолчанию группа основного топика",
+                    "type": "string"
+                },
+                "mode": {
+                    "description": "earliest, latest, timestamp, offset",
+                    "type": "string"
+                },
+                "offsets": {
+                    "description": "Для режима offset: партиция -\u003e смещение",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "partitions": {
+                    "description": "Ограничить сброс партициями; по умолчанию все",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "timestamp": {
+                    "description": "Для режима timestamp",
+                    "type": "string"
+                },
+                "topic": {
+                    "description": "По умолчанию основной топик",
+                    "type": "string"
+                }
+            }
+        },
+        "services.OffsetResetResult": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "type": "boolean"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "group_id": {
+                    "type": "string"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "partitions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PartitionReset"
+                    }
+                },
+                "topic": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.PartitionDescription": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "first_offset": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "isr": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "last_offset": {
+                    "type": "integer"
+                },
+                "leader": {
+                    "type": "integer"
+                },
+                "leader_host": {
+                    "type": "string"
+                },
+                "offline_replicas": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "replicas": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "services.PartitionReset": {
+            "type": "object",
+            "properties": {
+                "current_offset": {
+                    "description": "-1, если группа еще не фиксировала смещение",
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "partition": {
+                    "type": "integer"
+                },
+                "replay": {
+                    "description": "Сколько записей будет перечитано (отрицательное — пропущено)",
+                    "type": "integer"
+                },
+                "sample": {
+                    "description": "Первые перечитываемые записи (только dry-run)",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ReplayRecord"
+                    }
+                },
+                "target_offset": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.PartitionStatus": {
+            "type": "object",
+            "properties": {
+                "client_host": {
+                    "type": "string"
+                },
+                "client_id": {
+                    "type": "string"
+                },
+                "committed_offset": {
+                    "description": "-1, если группа еще не фиксировала смещение",
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "lag": {
+                    "description": "Количество необработанных записей",
+                    "type": "integer"
+                },
+                "last_processed_at": {
+                    "description": "Последняя фиксация смещения этим экземпляром",
+                    "type": "string"
+                },
+                "log_end_offset": {
+                    "description": "Смещение следующей записи партиции",
+                    "type": "integer"
+                },
+                "member_id": {
+                    "description": "Участник группы, которому назначена партиция",
+                    "type": "string"
+                },
+                "partition": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ReplayRecord": {
+            "type": "object",
+            "properties": {
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "key": {
+                    "type": "string"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.TopicDescription": {
+            "type": "object",
+            "properties": {
+                "configs": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "partitions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PartitionDescription"
+                    }
+                }
+            }
+        },
+        "services.TopicSummary": {
+            "type": "object",
+            "properties": {
+                "internal": {
+                    "description": "Служебный топик Kafka (__consumer_offsets и т.п.)",
+                    "type": "boolean"
+                },
+                "name": {
                     "type": "string"
+                },
+                "partitions": {
+                    "type": "integer"
+                },
+                "replication_factor": {
+                    "type": "integer"
                 }
             }
         }
@@ -0,0 +1,149 @@
+// Package handlers admin.go
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go_microsvc/models"
+	"go_microsvc/services"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// BrokerNotSupported отвечает на запросы администрирования Kafka, если используется другой брокер сообщений
+func BrokerNotSupported(c *fiber.Ctx) error {
+	return c.Status(http.StatusNotImplemented).JSON(fiber.Map{"error": "Operation is only supported with MESSAGE_BROKER=kafka"})
+}
+
+// GetConsumers возвращает отставание групп потребителей по партициям
+// @Summary Отставание consumer
+// @Description Для каждой группы и партиции возвращает зафиксированное смещение, смещение конца лога, отставание, время последней обработки и назначенного участника. Если состояние группы не удалось получить, ошибка возвращается в поле error группы, а остальные группы возвращаются полностью.
+// @Tags Admin
+// @Produce json
+// @Success 200 {array} services.GroupStatus
+// @Failure 501 {object} fiber.Map "Брокер сообщений не Kafka"
+// @Failure 502 {object} fiber.Map "Состояние не удалось получить ни для одной группы"
+// @Router /api/admin/consumers [get]
+func GetConsumers(c *fiber.Ctx, monitor *services.ConsumerMonitor) error {
+	status, err := monitor.Status(c.UserContext())
+	if err != nil {
+		log.Printf("Error retrieving consumer status: %v", err)
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "Kafka error: " + err.Error()})
+	}
+	return c.Status(http.StatusOK).JSON(status)
+}
+
+// ConsumerPauseResponse состояние паузы consumer
+// swagger:model ConsumerPauseResponse
+type ConsumerPauseResponse struct {
+	Paused bool `json:"paused"`
+}
+
+// PauseConsumers приостанавливает consumer этого экземпляра
+// @Summary Пауза consumer
+// @Description Останавливает чтение всех топиков; readers покидают группы, после чего смещения можно сбросить. Группа считается остановленной, когда пауза выполнена на всех экземплярах.
+// @Tags Admin
+// @Produce json
+// @Param X-Actor header string true "Пользователь, выполняющий действие"
+// @Success 200 {object} ConsumerPauseResponse
+// @Failure 400 {object} fiber.Map "Не указан X-Actor"
+// @Failure 409 {object} fiber.Map "Consumer не управляется этим процессом"
+// @Router /api/admin/consumers/pause [post]
+func PauseConsumers(c *fiber.Ctx, offsets *services.OffsetAdmin, audit *services.AuditLog) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return actorRequired(c)
+	}
+	err := offsets.Pause(c.UserContext())
+	audit.Record(c.UserContext(), auditEntry(c, actor, services.AuditConsumerPause, services.InstanceID(), nil), err)
+	if err != nil {
+		log.Printf("Error pausing consumer: %v", err)
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(http.StatusOK).JSON(ConsumerPauseResponse{Paused: offsets.Paused()})
+}
+
+// ResumeConsumers возобновляет consumer этого экземпляра
+// @Summary Возобновление consumer
+// @Description Возобновляет чтение с зафиксированных смещений групп
+// @Tags Admin
+// @Produce json
+// @Param X-Actor header string true "Пользователь, выполняющий действие"
+// @Success 200 {object} ConsumerPauseResponse
+// @Failure 400 {object} fiber.Map "Не указан X-Actor"
+// @Failure 409 {object} fiber.Map "Consumer не управляется этим процессом"
+// @Router /api/admin/consumers/resume [post]
+func ResumeConsumers(c *fiber.Ctx, offsets *services.OffsetAdmin, audit *services.AuditLog) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return actorRequired(c)
+	}
+	err := offsets.Resume()
+	audit.Record(c.UserContext(), auditEntry(c, actor, services.AuditConsumerResume, services.InstanceID(), nil), err)
+	if err != nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(http.StatusOK).JSON(ConsumerPauseResponse{Paused: offsets.Paused()})
+}
+
+// ResetConsumerOffsets сбрасывает смещения группы потребителей
+// @Summary Сброс смещений
+// @Description Сбрасывает смещения группы на начало или конец лога, на время или на заданные смещения партиций. С dry_run только показывает, сколько и какие записи будут перечитаны. Сброс без dry_run записывается в журнал аудита.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param X-Actor header string true "Пользователь, выполняющий действие"
+// @Param request body services.OffsetResetRequest true "Параметры сброса"
+// @Success 200 {object} services.OffsetResetResult
+// @Failure 400 {object} fiber.Map "Некорректный запрос или не указан X-Actor"
+// @Failure 409 {object} fiber.Map "Группа активна"
+// @Failure 501 {object} fiber.Map "Брокер сообщений не Kafka"
+// @Failure 502 {object} fiber.Map "Ошибка Kafka"
+// @Router /api/admin/consumers/reset-offsets [post]
+func ResetConsumerOffsets(c *fiber.Ctx, offsets *services.OffsetAdmin, audit *services.AuditLog) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return actorRequired(c)
+	}
+	var request services.OffsetResetRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	result, err := offsets.Reset(c.UserContext(), request)
+	if !request.DryRun {
+		group, topic := result.GroupID, result.Topic
+		if group == "" {
+			group, topic = request.GroupID, request.Topic
+		}
+		details := models.AuditDetails{"topic": topic, "mode": request.Mode, "applied": strconv.FormatBool(result.Applied)}
+		audit.Record(c.UserContext(), auditEntry(c, actor, services.AuditOffsetReset, group, details), err)
+	}
+	switch {
+	case errors.Is(err, services.ErrGroupActive):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error(), "result": result})
+	case errors.Is(err, services.ErrInvalidOffsetReset):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		log.Printf("Error resetting consumer offsets: %v", err)
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "Kafka error: " + err.Error()})
+	}
+	return c.Status(http.StatusOK).JSON(result)
+}
+
+// GetHealth возвращает состояние Kafka consumer
+// @Summary Проверка работоспособности
+// @Description Возвращает состояние consumer, время последнего чтения и последнюю ошибку чтения. Код 503, если consumer остановлен или чтение завершается ошибкой.
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} services.ConsumerHealth
+// @Failure 503 {object} services.ConsumerHealth
+// @Router /api/health [get]
+func GetHealth(c *fiber.Ctx, consumer *services.Consumer) error {
+	health := consumer.Health()
+	if !health.Healthy {
+		return c.Status(http.StatusServiceUnavailable).JSON(health)
+	}
+	return c.Status(http.StatusOK).JSON(health)
+}
@@ -0,0 +1,64 @@
+// Package handlers audit.go
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go_microsvc/models"
+	"go_microsvc/services"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// HeaderActor заголовок с именем пользователя, выполняющего административное действие
+const HeaderActor = "X-Actor"
+
+// ListAuditLog возвращает журнал административных действий
+// @Summary Журнал аудита
+// @Description Возвращает административные действия начиная с последних
+// @Tags Admin
+// @Produce json
+// @Param action query string false "Фильтр по действию, например topic.create"
+// @Param resource query string false "Фильтр по объекту, например имя топика"
+// @Param offset query int false "Смещение" default(0)
+// @Param limit query int false "Лимит" default(10)
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} fiber.Map "Неверные параметры запроса"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/admin/audit [get]
+func ListAuditLog(c *fiber.Ctx, audit *services.AuditLog) error {
+	offset, limit, err := pagination(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	entries, err := audit.List(c.UserContext(), c.Query("action"), c.Query("resource"), offset, limit)
+	if err != nil {
+		log.Printf("Error retrieving audit log: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Database error: " + err.Error()})
+	}
+	return c.Status(http.StatusOK).JSON(entries)
+}
+
+// auditActor возвращает пользователя из заголовка X-Actor; false, если заголовок не указан
+func auditActor(c *fiber.Ctx) (string, bool) {
+	actor := strings.TrimSpace(c.Get(HeaderActor))
+	return actor, actor != ""
+}
+
+// actorRequired отвечает на административный запрос без заголовка X-Actor
+func actorRequired(c *fiber.Ctx) error {
+	return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": HeaderActor + " header is required"})
+}
+
+// auditEntry формирует запись аудита с пользователем actor и ID запроса
+func auditEntry(c *fiber.Ctx, actor, action, resource string, details models.AuditDetails) models.AuditEntry {
+	requestID, _ := c.Locals("requestid").(string)
+	return models.AuditEntry{
+		Actor:     actor,
+		RequestID: requestID,
+		Action:    action,
+		Resource:  resource,
+		Details:   details,
+	}
+}
@@ -0,0 +1,108 @@
+// Package handlers dlq.go
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go_microsvc/models"
+	"go_microsvc/services"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// ListDeadLetters возвращает записи dead-letter очереди
+// @Summary Список записей DLQ
+// @Description Возвращает записи, отправленные в dead-letter топик, начиная с последних
+// @Tags Admin
+// @Produce json
+// @Param offset query int false "Смещение" default(0)
+// @Param limit query int false "Лимит" default(10)
+// @Success 200 {array} models.DeadLetter
+// @Failure 400 {object} fiber.Map "Неверные параметры запроса"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/admin/dlq [get]
+func ListDeadLetters(c *fiber.Ctx, dlq *services.DeadLetterQueue) error {
+	offset, limit, err := pagination(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	letters, err := dlq.List(c.UserContext(), offset, limit)
+	if err != nil {
+		log.Printf("Error retrieving dead letters: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Database error: " + err.Error()})
+	}
+	return c.Status(http.StatusOK).JSON(letters)
+}
+
+// GetDeadLetter возвращает запись dead-letter очереди по ID
+// @Summary Просмотр записи DLQ
+// @Description Возвращает исходную запись, заголовки и причину отправки в DLQ
+// @Tags Admin
+// @Produce json
+// @Param id path int true "ID записи DLQ"
+// @Success 200 {object} models.DeadLetter
+// @Failure 400 {object} fiber.Map "Неверный ID"
+// @Failure 404 {object} fiber.Map "Запись не найдена"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/admin/dlq/{id} [get]
+func GetDeadLetter(c *fiber.Ctx, dlq *services.DeadLetterQueue) error {
+	id, err := pathID(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid id parameter"})
+	}
+
+	letter, err := dlq.Get(c.UserContext(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrDeadLetterNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Database error: " + err.Error()})
+	}
+	return c.Status(http.StatusOK).JSON(letter)
+}
+
+// RedriveDeadLetter возвращает запись dead-letter очереди в исходный топик
+// @Summary Повторная отправка записи DLQ
+// @Description Публикует исходную запись с исходными заголовками обратно в исходный топик. Действие записывается в журнал аудита.
+// @Tags Admin
+// @Produce json
+// @Param X-Actor header string true "Пользователь, выполняющий действие"
+// @Param id path int true "ID записи DLQ"
+// @Success 200 {object} models.DeadLetter
+// @Failure 400 {object} fiber.Map "Неверный ID или не указан X-Actor"
+// @Failure 404 {object} fiber.Map "Запись не найдена"
+// @Failure 500 {object} fiber.Map "Ошибка сервера или Kafka"
+// @Router /api/admin/dlq/{id}/redrive [post]
+func RedriveDeadLetter(c *fiber.Ctx, dlq *services.DeadLetterQueue, audit *services.AuditLog) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return actorRequired(c)
+	}
+	id, err := pathID(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid id parameter"})
+	}
+
+	letter, err := dlq.Redrive(c.UserContext(), id)
+	details := models.AuditDetails{"original_topic": letter.OriginalTopic, "redrive_count": strconv.Itoa(letter.RedriveCount)}
+	audit.Record(c.UserContext(), auditEntry(c, actor, services.AuditDLQRedrive, strconv.FormatUint(uint64(id), 10), details), err)
+	if err != nil {
+		if errors.Is(err, services.ErrDeadLetterNotFound) {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+		}
+		log.Printf("Error redriving dead letter %d: %v", id, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Redrive error: " + err.Error()})
+	}
+	return c.Status(http.StatusOK).JSON(letter)
+}
+
+// pathID разбирает параметр пути :id
+func pathID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return 0, errors.New("invalid id")
+	}
+	return uint(id), nil
+}
@@ -2,8 +2,13 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
-	"go_microsvc/config"
 	"go_microsvc/database"
 	"go_microsvc/models"
 	"go_microsvc/services" // Импортируем сервис для работы с Kafka
@@ -14,7 +19,10 @@ import (
 
 // CreateMessage создает новое сообщение и сохраняет его в базе данных
 // @Summary Создание сообщения
-// @Description Создает новое сообщение и сохраняет его в базе данных
+// @Description Сохраняет сообщение и событие для Kafka в одной транзакции. Событие публикуется в Kafka фоновым outbox relay.
+// @Description Сообщение с deliver_at или delay сохраняется в статусе scheduled и публикуется в указанное время.
+// @Description Сообщение с expires_at или ttl, не обработанное до истечения срока, не передается обработчику и переходит в статус expired.
+// @Description Сообщения с priority high и low публикуются в топики <topic>.high и <topic>.low, которые consumer читает с учетом весов приоритетов.
 // @Tags Api
 // @Accept json
 // @Produce json
@@ -22,12 +30,9 @@ import (
 // @Success 201 {object} models.CreateMessageResponse
 // @Failure 400 {object} fiber.Map "Неверный формат данных"
 // @Failure 422 {object} fiber.Map "Ошибка валидации данных"
-// @Failure 500 {object} fiber.Map "Ошибка сервера или Kafka"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
 // @Router /api/message [post]
-func CreateMessage(c *fiber.Ctx, db *database.Database) error {
-
-	// Загрузка конфигурации из файла или переменных окружения
-	cfg := config.LoadConfig()
+func CreateMessage(c *fiber.Ctx, messages *services.MessageService) error {
 
 	var request models.CreateMessageRequest
 
@@ -37,64 +42,172 @@ func CreateMessage(c *fiber.Ctx, db *database.Database) error {
 		// Возвращаем статус 400 и сообщение об ошибке
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input: " + err.Error()})
 	}
-	// Валидация поля Content
-	if len(request.Content) == 0 {
+	// Валидация поля Content, приоритета, времени доставки и срока действия
+	if err := services.ValidateRequest(request); err != nil {
 		// Возвращаем статус 422 и сообщение об ошибке
+		if errors.Is(err, services.ErrInvalidSchedule) || errors.Is(err, services.ErrInvalidPriority) || errors.Is(err, services.ErrInvalidExpiration) {
+			return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": "Content is required"})
 	}
 
 	log.Printf("Parsed content: %s", request.Content)
 
-	// Создание экземпляра модели сообщения
-	msg := models.Message{
-		Content:   request.Content,
-		Processed: false, // Статус по умолчанию
+	// Сохраняем сообщение и запись outbox в одной транзакции
+	msg, err := messages.Create(requestContext(c), request)
+	if err != nil {
+		// Возвращаем статус 500 и сообщение об ошибке
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Database error: " + err.Error()})
 	}
 
-	// Сохраняем сообщение в базу данных
-	if err := db.Create(&msg).Error; err != nil {
-		// Возвращаем статус 500 и сообщение об ошибке
+	// Возвращаем сохраненное сообщение в ответе
+	return c.Status(http.StatusCreated).JSON(msg)
+}
+
+// CreateMessagesBatch создает пакет сообщений одной транзакцией
+// @Summary Пакетное создание сообщений
+// @Description Принимает JSON массив или NDJSON (application/x-ndjson) с элементами CreateMessageRequest.
+// @Description Корректные элементы сохраняются одной транзакцией и публикуются в Kafka одним вызовом, ошибки возвращаются по каждому элементу.
+// @Tags Api
+// @Accept json
+// @Accept application/x-ndjson
+// @Produce json
+// @Param messages body []models.CreateMessageRequest true "Сообщения"
+// @Success 201 {object} models.BatchCreateResponse "Все сообщения созданы"
+// @Success 207 {object} models.BatchCreateResponse "Часть сообщений не прошла валидацию"
+// @Failure 400 {object} fiber.Map "Неверный формат данных"
+// @Failure 413 {object} fiber.Map "Превышен размер пакета"
+// @Failure 422 {object} models.BatchCreateResponse "Ни одно сообщение не прошло валидацию"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/messages/batch [post]
+func CreateMessagesBatch(c *fiber.Ctx, messages *services.MessageService) error {
+
+	requests, err := parseBatchBody(c)
+	if err != nil {
+		log.Printf("Error parsing batch request: %v", err)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input: " + err.Error()})
+	}
+	if len(requests) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Batch is empty"})
+	}
+
+	response, err := messages.CreateBatch(requestContext(c), requests)
+	if err != nil {
+		if errors.Is(err, services.ErrBatchTooLarge) {
+			return c.Status(http.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Database error: " + err.Error()})
 	}
 
-	// Отправка сообщения в Kafka
-	if err := services.SendMessage(cfg.KafkaBrokers, cfg.KafkaTopic, msg); err != nil {
-		log.Printf("Ошибка отправки сообщения в Kafka: %v", err)
-		// Возвращаем статус 500 и сообщение об ошибке
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Kafka error: " + err.Error()})
+	status := http.StatusCreated
+	switch {
+	case response.Created == 0:
+		status = http.StatusUnprocessableEntity
+	case response.Failed > 0:
+		status = http.StatusMultiStatus
 	}
+	return c.Status(status).JSON(response)
+}
 
-	// Возвращаем сохраненное сообщение в ответе
-	return c.Status(http.StatusCreated).JSON(msg)
+// parseBatchBody разбирает тело пакетного запроса: JSON массив или NDJSON (по одному объекту в строке)
+func parseBatchBody(c *fiber.Ctx) ([]models.CreateMessageRequest, error) {
+	body := bytes.TrimSpace(c.Body())
+
+	var requests []models.CreateMessageRequest
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &requests); err != nil {
+			return nil, err
+		}
+		return requests, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var request models.CreateMessageRequest
+		if err := json.Unmarshal(text, &request); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		requests = append(requests, request)
+	}
+	return requests, scanner.Err()
 }
 
-// GetMessageStats возвращает количество обработанных сообщений
+// GetMessageStats возвращает количество сообщений по статусам и очередь по приоритетам
 // Маршрут для получения статистики обработанных сообщений
-// @Summary Получение статистики обработанных сообщений consumer-ом
-// @Description Получает количество обработанных сообщений
+// @Summary Получение статистики сообщений
+// @Description Получает количество обработанных и просроченных сообщений, количество сообщений в каждом статусе
+// @Description и очередь по приоритетам: сообщения в статусах pending, published, processing и failed
 // @Tags Api
 // @Produce json
-// @Success 200 {object} map[string]int64
+// @Success 200 {object} models.MessageStats
 // @Failure 500 {string} string "Ошибка сервера"
 // @Router /api/stats [get]
 func GetMessageStats(c *fiber.Ctx, db *database.Database) error {
 
-	var count int64
+	var rows []struct {
+		Status string
+		Count  int64
+	}
 
-	// Считаем количество обработанных сообщений
-	if err := db.Model(&models.Message{}).Where("processed = ?", true).Count(&count).Error; err != nil {
+	// Считаем количество сообщений в каждом статусе
+	if err := db.Model(&models.Message{}).Select("status, count(*) AS count").Group("status").Scan(&rows).Error; err != nil {
 		return c.Status(http.StatusInternalServerError).SendString("Ошибка получения статистики: " + err.Error())
 	}
 
+	stats := models.MessageStats{Statuses: make(map[string]int64, len(models.MessageStatuses))}
+	for _, status := range models.MessageStatuses {
+		stats.Statuses[status] = 0
+	}
+	for _, row := range rows {
+		stats.Statuses[row.Status] = row.Count
+	}
+	stats.ProcessedMessages = stats.Statuses[models.MessageStatusProcessed]
+	stats.ExpiredMessages = stats.Statuses[models.MessageStatusExpired]
+
+	// Очередь по приоритетам: сообщения, доставка которых еще не завершена
+	var backlog []struct {
+		Priority string
+		Count    int64
+	}
+	if err := db.Model(&models.Message{}).Select("priority, count(*) AS count").
+		Where("status IN ?", models.MessageBacklogStatuses).
+		Group("priority").Scan(&backlog).Error; err != nil {
+		return c.Status(http.StatusInternalServerError).SendString("Ошибка получения статистики: " + err.Error())
+	}
+	stats.Backlog = make(map[string]int64, len(models.MessagePriorities))
+	for _, priority := range models.MessagePriorities {
+		stats.Backlog[priority] = 0
+	}
+	for _, row := range backlog {
+		stats.Backlog[row.Priority] = row.Count
+	}
+
 	// Возвращаем статистику
-	return c.Status(http.StatusOK).JSON(map[string]int64{"processed_messages": count})
+	return c.Status(http.StatusOK).JSON(stats)
+}
+
+// GetMetrics возвращает метрики consumer
+// @Summary Метрики consumer
+// @Description Возвращает счетчики обработки записей, настройки и статистику пакетного режима
+// @Tags Api
+// @Produce json
+// @Success 200 {object} services.Metrics
+// @Router /api/metrics [get]
+func GetMetrics(c *fiber.Ctx) error {
+	return c.Status(http.StatusOK).JSON(services.GetMetrics())
 }
 
 // GetMessages получает сообщения из базы данных с offset и limit
 // @Summary Получение списка сообщений из базы данных
-// @Description Возвращает список сообщений с учетом offset и limit
+// @Description Возвращает список сообщений с учетом offset и limit, при необходимости только в указанных статусах
 // @Tags Api
 // @Produce json
+// @Param status query string false "Статусы через запятую: scheduled, cancelled, pending, published, processing, processed, failed, dead_lettered, expired"
 // @Param offset query int false "Смещение" default(0)
 // @Param limit query int false "Лимит" default(10)
 // @Success 200 {array} models.Message "Успешное получение сообщений"
@@ -104,25 +217,24 @@ func GetMessageStats(c *fiber.Ctx, db *database.Database) error {
 func GetMessages(c *fiber.Ctx, db *database.Database) error {
 
 	// Получение значений offset и limit из query parameters
-	offsetParam := c.Query("offset", "0")
-	limitParam := c.Query("limit", "10")
-
-	// Преобразование параметров в целые числа
-	offset, err := strconv.Atoi(offsetParam)
-	if err != nil || offset < 0 {
-		log.Printf("Invalid offset: %v", err)
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid offset parameter"})
+	offset, limit, err := pagination(c)
+	if err != nil {
+		log.Printf("Invalid pagination: %v", err)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	limit, err := strconv.Atoi(limitParam)
-	if err != nil || limit <= 0 {
-		log.Printf("Invalid limit: %v", err)
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid limit parameter"})
+	statuses, err := services.ParseMessageStatuses(c.Query("status"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	// Извлечение сообщений из базы данных с использованием offset и limit
+	query := db.Offset(offset).Limit(limit)
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
 	var messages []models.Message
-	if err := db.Offset(offset).Limit(limit).Find(&messages).Error; err != nil {
+	if err := query.Find(&messages).Error; err != nil {
 		log.Printf("Error retrieving messages: %v", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Database error: " + err.Error()})
 	}
@@ -130,3 +242,132 @@ func GetMessages(c *fiber.Ctx, db *database.Database) error {
 	// Возвращаем список сообщений в ответе
 	return c.Status(http.StatusOK).JSON(messages)
 }
+
+// CancelMessage отменяет доставку отложенного сообщения
+// @Summary Отмена отложенного сообщения
+// @Description Отменяет доставку сообщения в статусе scheduled. Опубликованное сообщение отменить нельзя.
+// @Tags Api
+// @Produce json
+// @Param id path int true "ID сообщения"
+// @Success 200 {object} models.Message
+// @Failure 400 {object} fiber.Map "Неверный ID"
+// @Failure 404 {object} fiber.Map "Сообщение не найдено"
+// @Failure 409 {object} fiber.Map "Сообщение не в статусе scheduled"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/messages/{id}/cancel [post]
+func CancelMessage(c *fiber.Ctx, messages *services.MessageService) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid id parameter"})
+	}
+
+	msg, err := messages.Cancel(c.UserContext(), uint(id))
+	if err != nil {
+		return messageError(c, err)
+	}
+	return c.Status(http.StatusOK).JSON(msg)
+}
+
+// RescheduleMessage переносит доставку отложенного сообщения
+// @Summary Перенос отложенного сообщения
+// @Description Задает новое время доставки сообщения в статусе scheduled: deliver_at или delay от текущего момента
+// @Tags Api
+// @Accept json
+// @Produce json
+// @Param id path int true "ID сообщения"
+// @Param request body models.ScheduleMessageRequest true "Новое время доставки"
+// @Success 200 {object} models.Message
+// @Failure 400 {object} fiber.Map "Неверный формат данных"
+// @Failure 404 {object} fiber.Map "Сообщение не найдено"
+// @Failure 409 {object} fiber.Map "Сообщение не в статусе scheduled"
+// @Failure 422 {object} fiber.Map "Некорректное время доставки или доставка после истечения срока действия"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/messages/{id}/reschedule [post]
+func RescheduleMessage(c *fiber.Ctx, messages *services.MessageService) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid id parameter"})
+	}
+	var request models.ScheduleMessageRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input: " + err.Error()})
+	}
+
+	msg, err := messages.Reschedule(c.UserContext(), uint(id), request)
+	if err != nil {
+		return messageError(c, err)
+	}
+	return c.Status(http.StatusOK).JSON(msg)
+}
+
+// messageError преобразует ошибку изменения сообщения в HTTP ответ
+func messageError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrMessageNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTransition):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSchedule), errors.Is(err, services.ErrInvalidExpiration):
+		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	default:
+		log.Printf("Error updating message: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Database error: " + err.Error()})
+	}
+}
+
+// GetMessageAttempts возвращает историю попыток обработки сообщения
+// @Summary История обработки сообщения
+// @Description Возвращает попытки обработки сообщения consumer: время, экземпляр consumer, партицию и смещение, длительность, результат и ошибку
+// @Tags Api
+// @Produce json
+// @Param id path int true "ID сообщения"
+// @Param offset query int false "Смещение" default(0)
+// @Param limit query int false "Лимит" default(10)
+// @Success 200 {array} models.MessageAttempt
+// @Failure 400 {object} fiber.Map "Неверные параметры запроса"
+// @Failure 404 {object} fiber.Map "Сообщение не найдено"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/messages/{id}/attempts [get]
+func GetMessageAttempts(c *fiber.Ctx, attempts *services.AttemptLog) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid id parameter"})
+	}
+	offset, limit, err := pagination(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	history, err := attempts.List(c.UserContext(), uint(id), offset, limit)
+	if errors.Is(err, services.ErrMessageNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("Error retrieving message attempts: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Database error: " + err.Error()})
+	}
+	return c.Status(http.StatusOK).JSON(history)
+}
+
+// requestContext возвращает контекст запроса с ID корреляции из middleware requestid
+func requestContext(c *fiber.Ctx) context.Context {
+	ctx := c.UserContext()
+	if id, ok := c.Locals("requestid").(string); ok && id != "" {
+		return services.WithCorrelationID(ctx, id)
+	}
+	return ctx
+}
+
+// pagination разбирает параметры offset и limit из query parameters
+func pagination(c *fiber.Ctx) (int, int, error) {
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		return 0, 0, errors.New("Invalid offset parameter")
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit", "10"))
+	if err != nil || limit <= 0 {
+		return 0, 0, errors.New("Invalid limit parameter")
+	}
+	return offset, limit, nil
+}
@@ -0,0 +1,181 @@
+// Package handlers schedules.go
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go_microsvc/models"
+	"go_microsvc/services"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// ListSchedules возвращает расписания
+// @Summary Список расписаний
+// @Description Возвращает расписания периодической отправки сообщений с учетом offset и limit
+// @Tags Schedules
+// @Produce json
+// @Param offset query int false "Смещение" default(0)
+// @Param limit query int false "Лимит" default(10)
+// @Success 200 {array} models.Schedule
+// @Failure 400 {object} fiber.Map "Неверные параметры запроса"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/schedules [get]
+func ListSchedules(c *fiber.Ctx, schedules *services.ScheduleService) error {
+	offset, limit, err := pagination(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	list, err := schedules.List(c.UserContext(), offset, limit)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+	return c.Status(http.StatusOK).JSON(list)
+}
+
+// CreateSchedule создает расписание
+// @Summary Создание расписания
+// @Description Создает расписание: cron выражение, часовой пояс, шаблон содержимого сообщения и политику пропущенных запусков.
+// @Description Шаблон text/template получает поля ScheduleID, Name, ScheduledAt и Missed.
+// @Tags Schedules
+// @Accept json
+// @Produce json
+// @Param request body models.ScheduleRequest true "Параметры расписания"
+// @Success 201 {object} models.Schedule
+// @Failure 400 {object} fiber.Map "Неверный формат данных"
+// @Failure 422 {object} fiber.Map "Некорректные параметры расписания"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/schedules [post]
+func CreateSchedule(c *fiber.Ctx, schedules *services.ScheduleService) error {
+	var request models.ScheduleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input: " + err.Error()})
+	}
+
+	schedule, err := schedules.Create(c.UserContext(), request)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+	return c.Status(http.StatusCreated).JSON(schedule)
+}
+
+// GetSchedule возвращает расписание
+// @Summary Просмотр расписания
+// @Tags Schedules
+// @Produce json
+// @Param id path int true "ID расписания"
+// @Success 200 {object} models.Schedule
+// @Failure 400 {object} fiber.Map "Неверный ID"
+// @Failure 404 {object} fiber.Map "Расписание не найдено"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/schedules/{id} [get]
+func GetSchedule(c *fiber.Ctx, schedules *services.ScheduleService) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid id parameter"})
+	}
+
+	schedule, err := schedules.Get(c.UserContext(), uint(id))
+	if err != nil {
+		return scheduleError(c, err)
+	}
+	return c.Status(http.StatusOK).JSON(schedule)
+}
+
+// UpdateSchedule изменяет расписание
+// @Summary Изменение расписания
+// @Description Заменяет параметры расписания. Следующий запуск вычисляется от текущего времени; enabled без значения сохраняет текущее состояние.
+// @Tags Schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "ID расписания"
+// @Param request body models.ScheduleRequest true "Параметры расписания"
+// @Success 200 {object} models.Schedule
+// @Failure 400 {object} fiber.Map "Неверный формат данных"
+// @Failure 404 {object} fiber.Map "Расписание не найдено"
+// @Failure 422 {object} fiber.Map "Некорректные параметры расписания"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/schedules/{id} [put]
+func UpdateSchedule(c *fiber.Ctx, schedules *services.ScheduleService) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid id parameter"})
+	}
+	var request models.ScheduleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input: " + err.Error()})
+	}
+
+	schedule, err := schedules.Update(c.UserContext(), uint(id), request)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+	return c.Status(http.StatusOK).JSON(schedule)
+}
+
+// DeleteSchedule удаляет расписание
+// @Summary Удаление расписания
+// @Description Удаляет расписание и историю его запусков. Созданные сообщения сохраняются.
+// @Tags Schedules
+// @Param id path int true "ID расписания"
+// @Success 204
+// @Failure 400 {object} fiber.Map "Неверный ID"
+// @Failure 404 {object} fiber.Map "Расписание не найдено"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/schedules/{id} [delete]
+func DeleteSchedule(c *fiber.Ctx, schedules *services.ScheduleService) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid id parameter"})
+	}
+
+	if err := schedules.Delete(c.UserContext(), uint(id)); err != nil {
+		return scheduleError(c, err)
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// GetScheduleRuns возвращает историю запусков расписания
+// @Summary История запусков расписания
+// @Description Возвращает запуски расписания начиная с последних: время по расписанию, результат, созданное сообщение и ошибку
+// @Tags Schedules
+// @Produce json
+// @Param id path int true "ID расписания"
+// @Param offset query int false "Смещение" default(0)
+// @Param limit query int false "Лимит" default(10)
+// @Success 200 {array} models.ScheduleRun
+// @Failure 400 {object} fiber.Map "Неверные параметры запроса"
+// @Failure 404 {object} fiber.Map "Расписание не найдено"
+// @Failure 500 {object} fiber.Map "Ошибка сервера"
+// @Router /api/schedules/{id}/runs [get]
+func GetScheduleRuns(c *fiber.Ctx, schedules *services.ScheduleService) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid id parameter"})
+	}
+	offset, limit, err := pagination(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	runs, err := schedules.Runs(c.UserContext(), uint(id), offset, limit)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+	return c.Status(http.StatusOK).JSON(runs)
+}
+
+// scheduleError преобразует ошибку сервиса расписаний в HTTP ответ
+func scheduleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrScheduleNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidScheduleSpec):
+		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	default:
+		log.Printf("Error processing schedule: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Database error: " + err.Error()})
+	}
+}
@@ -0,0 +1,190 @@
+// Package handlers topics.go
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"go_microsvc/config"
+	"go_microsvc/models"
+	"go_microsvc/services"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CreateTopicRequest запрос на создание топика
+// swagger:model CreateTopicRequest
+type CreateTopicRequest struct {
+	Name              string            `json:"name"`
+	Partitions        int               `json:"partitions"`
+	ReplicationFactor int               `json:"replication_factor"`
+	Retention         string            `json:"retention,omitempty"`      // Например "168h"
+	CleanupPolicy     string            `json:"cleanup_policy,omitempty"` // delete, compact или "compact,delete"
+	Compression       string            `json:"compression,omitempty"`    // producer, gzip, snappy, lz4, zstd, uncompressed
+	Configs           map[string]string `json:"configs,omitempty"`        // Дополнительные параметры топика
+}
+
+// AddPartitionsRequest запрос на увеличение количества партиций
+// swagger:model AddPartitionsRequest
+type AddPartitionsRequest struct {
+	Count int `json:"count"` // Новое общее количество партиций
+}
+
+// ListTopics возвращает топики кластера
+// @Summary Список топиков
+// @Description Возвращает топики кластера с количеством партиций и фактором репликации. Служебные топики возвращаются с internal=true.
+// @Tags Topics
+// @Produce json
+// @Success 200 {array} services.TopicSummary
+// @Failure 501 {object} fiber.Map "Брокер сообщений не Kafka"
+// @Failure 502 {object} fiber.Map "Ошибка Kafka"
+// @Router /api/admin/topics [get]
+func ListTopics(c *fiber.Ctx, topics *services.TopicManager) error {
+	list, err := topics.ListTopics(c.UserContext())
+	if err != nil {
+		log.Printf("Error listing topics: %v", err)
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "Kafka error: " + err.Error()})
+	}
+	return c.Status(http.StatusOK).JSON(list)
+}
+
+// DescribeTopic возвращает партиции, лидеров, реплики и параметры топика
+// @Summary Описание топика
+// @Description Возвращает партиции с лидерами, репликами, ISR и смещениями, а также параметры топика
+// @Tags Topics
+// @Produce json
+// @Param name path string true "Имя топика"
+// @Success 200 {object} services.TopicDescription
+// @Failure 404 {object} fiber.Map "Топик не найден"
+// @Failure 501 {object} fiber.Map "Брокер сообщений не Kafka"
+// @Failure 502 {object} fiber.Map "Ошибка Kafka"
+// @Router /api/admin/topics/{name} [get]
+func DescribeTopic(c *fiber.Ctx, topics *services.TopicManager) error {
+	description, err := topics.DescribeTopic(c.UserContext(), c.Params("name"))
+	if err != nil {
+		return topicError(c, err)
+	}
+	return c.Status(http.StatusOK).JSON(description)
+}
+
+// CreateTopic создает топик
+// @Summary Создание топика
+// @Description Создает топик через контроллер кластера. Действие записывается в журнал аудита с пользователем из заголовка X-Actor.
+// @Tags Topics
+// @Accept json
+// @Produce json
+// @Param X-Actor header string true "Пользователь, выполняющий действие"
+// @Param request body CreateTopicRequest true "Параметры топика"
+// @Success 201 {object} services.TopicDescription
+// @Failure 400 {object} fiber.Map "Некорректные параметры или не указан X-Actor"
+// @Failure 409 {object} fiber.Map "Топик уже существует"
+// @Failure 501 {object} fiber.Map "Брокер сообщений не Kafka"
+// @Failure 502 {object} fiber.Map "Ошибка Kafka"
+// @Router /api/admin/topics [post]
+func CreateTopic(c *fiber.Ctx, topics *services.TopicManager, audit *services.AuditLog) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return actorRequired(c)
+	}
+	var request CreateTopicRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	spec := config.TopicSpec{
+		Name:              request.Name,
+		Partitions:        request.Partitions,
+		ReplicationFactor: request.ReplicationFactor,
+		CleanupPolicy:     request.CleanupPolicy,
+		Compression:       request.Compression,
+	}
+	if request.Retention != "" {
+		retention, err := time.ParseDuration(request.Retention)
+		if err != nil || retention <= 0 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid retention"})
+		}
+		spec.Retention = retention
+	}
+
+	details := models.AuditDetails{
+		"partitions":         strconv.Itoa(request.Partitions),
+		"replication_factor": strconv.Itoa(request.ReplicationFactor),
+	}
+	for name, value := range request.Configs {
+		details[name] = value
+	}
+	for name, value := range map[string]string{"retention": request.Retention, "cleanup_policy": request.CleanupPolicy, "compression": request.Compression} {
+		if value != "" {
+			details[name] = value
+		}
+	}
+
+	err := topics.CreateTopic(c.UserContext(), spec, request.Configs)
+	audit.Record(c.UserContext(), auditEntry(c, actor, services.AuditTopicCreate, request.Name, details), err)
+	if err != nil {
+		return topicError(c, err)
+	}
+
+	description, err := topics.DescribeTopic(c.UserContext(), request.Name)
+	if err != nil {
+		// Метаданные нового топика могут появиться не сразу
+		return c.Status(http.StatusCreated).JSON(fiber.Map{"name": request.Name})
+	}
+	return c.Status(http.StatusCreated).JSON(description)
+}
+
+// AddTopicPartitions увеличивает количество партиций топика
+// @Summary Увеличение количества партиций
+// @Description Увеличивает количество партиций топика до count. Уменьшение не поддерживается. Действие записывается в журнал аудита.
+// @Tags Topics
+// @Accept json
+// @Produce json
+// @Param X-Actor header string true "Пользователь, выполняющий действие"
+// @Param name path string true "Имя топика"
+// @Param request body AddPartitionsRequest true "Новое количество партиций"
+// @Success 200 {object} services.TopicDescription
+// @Failure 400 {object} fiber.Map "Некорректное количество партиций или не указан X-Actor"
+// @Failure 404 {object} fiber.Map "Топик не найден"
+// @Failure 501 {object} fiber.Map "Брокер сообщений не Kafka"
+// @Failure 502 {object} fiber.Map "Ошибка Kafka"
+// @Router /api/admin/topics/{name}/partitions [post]
+func AddTopicPartitions(c *fiber.Ctx, topics *services.TopicManager, audit *services.AuditLog) error {
+	actor, ok := auditActor(c)
+	if !ok {
+		return actorRequired(c)
+	}
+	name := c.Params("name")
+	var request AddPartitionsRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	err := topics.AddPartitions(c.UserContext(), name, request.Count)
+	details := models.AuditDetails{"count": strconv.Itoa(request.Count)}
+	audit.Record(c.UserContext(), auditEntry(c, actor, services.AuditTopicAddPartitions, name, details), err)
+	if err != nil {
+		return topicError(c, err)
+	}
+
+	description, err := topics.DescribeTopic(c.UserContext(), name)
+	if err != nil {
+		return topicError(c, err)
+	}
+	return c.Status(http.StatusOK).JSON(description)
+}
+
+// topicError преобразует ошибку администрирования топиков в HTTP ответ
+func topicError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrTopicNotFound):
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrTopicExists):
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTopicRequest):
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		log.Printf("Error managing topics: %v", err)
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{"error": "Kafka error: " + err.Error()})
+	}
+}
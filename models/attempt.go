@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Результаты попытки обработки сообщения
+const (
+	AttemptSucceeded = "succeeded" // Обработчик завершился успешно
+	AttemptFailed    = "failed"    // Обработчик вернул ошибку или завершился паникой
+)
+
+// MessageAttempt попытка обработки сообщения consumer. Записывается каждый вызов обработчика,
+// включая повторы на месте и повторы через retry топики.
+// swagger:model MessageAttempt
+type MessageAttempt struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	MessageID  uint      `json:"message_id" gorm:"index;not null"`     // ID сообщения в таблице messages
+	Message    *Message  `json:"-" gorm:"constraint:OnDelete:CASCADE"` // Внешний ключ: история удаляется вместе с сообщением
+	StartedAt  time.Time `json:"started_at"`
+	Consumer   string    `json:"consumer"`    // Экземпляр consumer: хост и PID
+	Topic      string    `json:"topic"`       // Топик записи: основной или retry
+	Partition  int       `json:"partition"`   // Партиция записи
+	Offset     int64     `json:"offset"`      // Смещение записи
+	DurationMs int64     `json:"duration_ms"` // Длительность обработки
+	Outcome    string    `json:"outcome" gorm:"index"`
+	Error      string    `json:"error,omitempty"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// AuditEntry запись журнала административных действий
+// swagger:model AuditEntry
+type AuditEntry struct {
+	ID        uint         `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time    `json:"created_at" gorm:"index"`
+	Actor     string       `json:"actor" gorm:"index"`    // Кто выполнил действие (заголовок X-Actor)
+	RequestID string       `json:"request_id"`            // ID HTTP запроса
+	Action    string       `json:"action" gorm:"index"`   // Действие, например topic.create
+	Resource  string       `json:"resource" gorm:"index"` // Объект действия, например имя топика
+	Details   AuditDetails `json:"details" gorm:"type:jsonb"`
+	Success   bool         `json:"success"`
+	Error     string       `json:"error"`
+}
+
+// AuditDetails параметры административного действия, хранящиеся в колонке jsonb
+type AuditDetails map[string]string
+
+// Value сериализует параметры в JSON для записи в базу данных
+func (d AuditDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan читает параметры из JSON колонки базы данных
+func (d *AuditDetails) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*d = AuditDetails{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("неподдерживаемый тип колонки details")
+	}
+	return json.Unmarshal(data, d)
+}
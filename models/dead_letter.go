@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// DeadLetter представляет запись, отправленную в dead-letter топик.
+// Хранится в базе данных для просмотра и повторной отправки через API.
+// swagger:model DeadLetter
+type DeadLetter struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt         time.Time  `json:"created_at"`
+	Topic             string     `json:"topic" gorm:"not null"`          // Dead-letter топик
+	OriginalTopic     string     `json:"original_topic" gorm:"index"`    // Топик, из которого прочитана запись
+	OriginalPartition int        `json:"original_partition"`             // Партиция исходной записи
+	OriginalOffset    int64      `json:"original_offset"`                // Смещение исходной записи
+	Key               string     `json:"key"`                            // Ключ исходной записи
+	Value             []byte     `json:"value" gorm:"type:bytea"`        // Тело исходной записи
+	Headers           Headers    `json:"headers" gorm:"type:jsonb"`      // Заголовки исходной записи
+	Reason            string     `json:"reason" gorm:"index"`            // Причина: decode_error, processing_failed
+	Error             string     `json:"error"`                          // Текст последней ошибки
+	Attempts          int        `json:"attempts"`                       // Количество попыток обработки
+	RedriveCount      int        `json:"redrive_count" gorm:"default:0"` // Сколько раз запись возвращалась в основной топик
+	RedrivenAt        *time.Time `json:"redriven_at"`                    // Время последней повторной отправки
+}
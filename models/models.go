@@ -2,26 +2,146 @@ package models
 
 import (
 	"gorm.io/gorm"
+	"time"
 )
 
+// Статусы сообщения. Допустимые переходы проверяются в services (TransitionMessage):
+// pending -> published -> processing -> processed; processing -> failed -> processing при повторе;
+// failed -> dead_lettered, когда попытки исчерпаны; dead_lettered -> published при возврате из DLQ.
+// Отложенное сообщение: scheduled -> published в момент доставки или scheduled -> cancelled.
+// Сообщение с expires_at, не обработанное до этого времени, переходит в expired.
+const (
+	MessageStatusScheduled    = "scheduled"     // Ожидает времени доставки deliver_at
+	MessageStatusCancelled    = "cancelled"     // Отложенная доставка отменена
+	MessageStatusPending      = "pending"       // Сохранено, событие еще не опубликовано
+	MessageStatusPublished    = "published"     // Событие опубликовано в брокер
+	MessageStatusProcessing   = "processing"    // Обрабатывается consumer
+	MessageStatusProcessed    = "processed"     // Успешно обработано
+	MessageStatusFailed       = "failed"        // Обработка завершилась ошибкой и будет повторена
+	MessageStatusDeadLettered = "dead_lettered" // Отправлено в DLQ
+	MessageStatusExpired      = "expired"       // Срок действия истек до обработки
+)
+
+// MessageStatuses перечисляет все статусы сообщения
+var MessageStatuses = []string{
+	MessageStatusScheduled,
+	MessageStatusCancelled,
+	MessageStatusPending,
+	MessageStatusPublished,
+	MessageStatusProcessing,
+	MessageStatusProcessed,
+	MessageStatusFailed,
+	MessageStatusDeadLettered,
+	MessageStatusExpired,
+}
+
+// MessageBacklogStatuses статусы сообщений, доставка которых еще не завершена. Отложенные сообщения
+// до наступления времени доставки в очередь не входят.
+var MessageBacklogStatuses = []string{
+	MessageStatusPending,
+	MessageStatusPublished,
+	MessageStatusProcessing,
+	MessageStatusFailed,
+}
+
+// Приоритеты сообщений. Сообщения каждого приоритета публикуются в свой топик, а consumer читает
+// топики с весами приоритетов, поэтому срочные сообщения не ждут обработки накопившихся массовых.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal" // Приоритет по умолчанию, публикуется в основной топик
+	PriorityLow    = "low"
+)
+
+// MessagePriorities перечисляет приоритеты сообщения от высшего к низшему
+var MessagePriorities = []string{PriorityHigh, PriorityNormal, PriorityLow}
+
 // Message представляет структуру сообщения в базе данных
 // swagger:model
 type Message struct {
-	gorm.Model        // Включает ID, CreatedAt, UpdatedAt, DeletedAt
-	Content    string `json:"content"`
-	Processed  bool   `json:"processed" gorm:"default:false"` // Устанавливаем значение по умолчанию для processed
+	gorm.Model              // Включает ID, CreatedAt, UpdatedAt, DeletedAt
+	Content      string     `json:"content"`
+	Status       string     `json:"status" gorm:"index;not null;default:pending"` // Статус жизненного цикла, см. MessageStatus*
+	Attempts     int        `json:"attempts" gorm:"not null;default:0"`           // Количество попыток обработки
+	LastError    string     `json:"last_error,omitempty"`                         // Последняя ошибка обработки
+	PublishedAt  *time.Time `json:"published_at,omitempty"`
+	ProcessedAt  *time.Time `json:"processed_at,omitempty"`
+	FailedAt     *time.Time `json:"failed_at,omitempty"`               // Последняя неудачная попытка или отправка в DLQ
+	DeliverAt    *time.Time `json:"deliver_at,omitempty" gorm:"index"` // Время отложенной доставки
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" gorm:"index"`             // Сообщение не обрабатывается после этого времени
+	Priority     string     `json:"priority" gorm:"index;not null;default:normal"` // high, normal или low
+	PartitionKey string     `json:"partition_key,omitempty"`                       // Ключ упорядочивания, переданный клиентом
+	TenantID     string     `json:"tenant_id,omitempty" gorm:"index"`
+
+	// Метаданные, которые также передаются в заголовках записи Kafka
+	CorrelationID string `json:"correlation_id,omitempty" gorm:"index"` // ID HTTP запроса, создавшего сообщение
+	SourceService string `json:"source_service,omitempty"`              // Сервис, создавший сообщение
+	SchemaVersion string `json:"schema_version,omitempty"`              // Версия схемы сообщения
 }
 
 // CreateMessageRequest Структура для передачи данных при создании сообщения
 // swagger:model CreateMessageRequest
 type CreateMessageRequest struct {
-	Content string `json:"content"` // validate:"required"`
+	Content      string `json:"content"`                 // validate:"required"`
+	PartitionKey string `json:"partition_key,omitempty"` // Сообщения с одинаковым ключом обрабатываются по порядку
+	TenantID     string `json:"tenant_id,omitempty"`     // Идентификатор арендатора
+	Priority     string `json:"priority,omitempty"`      // high, normal или low; по умолчанию normal
+
+	// Отложенная доставка: указывается не более одного поля. Время в прошлом означает немедленную доставку
+	DeliverAt *time.Time `json:"deliver_at,omitempty"` // Время доставки (RFC 3339)
+	Delay     string     `json:"delay,omitempty"`      // Задержка доставки от момента создания, например "15m"
+
+	// Срок действия: указывается не более одного поля. Сообщение, не обработанное до истечения срока,
+	// не передается обработчику и переходит в статус expired
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Время истечения срока (RFC 3339)
+	TTL       string     `json:"ttl,omitempty"`        // Срок действия от момента создания, например "5m"
+}
+
+// ScheduleMessageRequest запрос на перенос отложенной доставки
+// swagger:model ScheduleMessageRequest
+type ScheduleMessageRequest struct {
+	DeliverAt *time.Time `json:"deliver_at,omitempty"` // Новое время доставки (RFC 3339)
+	Delay     string     `json:"delay,omitempty"`      // Новая задержка от текущего момента, например "1h"
 }
 
 // CreateMessageResponse структура для отображения ответа после создания сообщения
 // swagger:model CreateMessageResponse
 type CreateMessageResponse struct {
-	ID        uint   `json:"id"`
-	Content   string `json:"content"`
-	Processed bool   `json:"processed"`
+	ID      uint   `json:"id"`
+	Content string `json:"content"`
+	Status  string `json:"status"`
+}
+
+// MessageStats статистика сообщений по статусам
+// swagger:model MessageStats
+type MessageStats struct {
+	ProcessedMessages int64            `json:"processed_messages"`
+	ExpiredMessages   int64            `json:"expired_messages"` // Сообщения, срок действия которых истек до обработки
+	Statuses          map[string]int64 `json:"statuses"`         // Количество сообщений в каждом статусе
+	Backlog           map[string]int64 `json:"backlog"`          // Количество сообщений с незавершенной доставкой по приоритетам
+}
+
+// Статусы элементов пакетного создания сообщений
+const (
+	BatchItemCreated = "created"
+	BatchItemFailed  = "failed"
+)
+
+// BatchItemResult результат обработки одного элемента пакета
+// swagger:model BatchItemResult
+type BatchItemResult struct {
+	Index  int    `json:"index"`           // Позиция элемента в запросе
+	ID     uint   `json:"id,omitempty"`    // ID созданного сообщения
+	Status string `json:"status"`          // created или failed
+	Error  string `json:"error,omitempty"` // Причина ошибки
+}
+
+// BatchCreateResponse структура ответа пакетного создания сообщений
+// swagger:model BatchCreateResponse
+type BatchCreateResponse struct {
+	Total     int               `json:"total"`
+	Created   int               `json:"created"`
+	Failed    int               `json:"failed"`
+	Published int               `json:"published"` // Сколько сообщений сразу опубликовано в Kafka, остальные опубликует outbox relay
+	Items     []BatchItemResult `json:"items"`
 }
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Статусы записей outbox
+const (
+	OutboxStatusPending   = "pending"   // Ожидает публикации в Kafka
+	OutboxStatusSent      = "sent"      // Опубликована в Kafka
+	OutboxStatusCancelled = "cancelled" // Отложенная доставка отменена
+	OutboxStatusExpired   = "expired"   // Срок действия сообщения истек до публикации
+)
+
+// OutboxRecord представляет событие, записанное в той же транзакции, что и сообщение,
+// и ожидающее публикации в Kafka фоновым relay
+type OutboxRecord struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	MessageID     uint       `json:"message_id" gorm:"index"`
+	Topic         string     `json:"topic" gorm:"not null"`
+	Key           string     `json:"key"`
+	Payload       []byte     `json:"payload" gorm:"type:bytea;not null"`
+	Headers       Headers    `json:"headers" gorm:"type:jsonb"`
+	Status        string     `json:"status" gorm:"index;not null;default:pending"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index;not null"`
+	LastError     string     `json:"last_error"`
+	SentAt        *time.Time `json:"sent_at"`
+}
+
+// TableName задает имя таблицы outbox
+func (OutboxRecord) TableName() string {
+	return "outbox"
+}
+
+// Headers представляет заголовки записи Kafka, хранящиеся в колонке jsonb
+type Headers map[string]string
+
+// Value сериализует заголовки в JSON для записи в базу данных
+func (h Headers) Value() (driver.Value, error) {
+	if h == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan читает заголовки из JSON колонки базы данных
+func (h *Headers) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*h = Headers{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("неподдерживаемый тип колонки headers")
+	}
+	return json.Unmarshal(data, h)
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Статусы заданий очереди в Postgres
+const (
+	QueueJobStatusPending = "pending" // Ожидает обработки или обрабатывается потребителем
+	QueueJobStatusDead    = "dead"    // Превышено количество выдач без подтверждения
+)
+
+// QueueJob представляет запись топика в очереди на Postgres. Задание захватывается потребителем
+// через SELECT ... FOR UPDATE SKIP LOCKED и скрывается до VisibleAt; подтвержденное задание удаляется.
+// Если потребитель не подтвердил задание до истечения VisibleAt, оно выдается повторно.
+type QueueJob struct {
+	ID        uint64    `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Topic     string    `json:"topic" gorm:"not null;index:idx_queue_jobs_claim,priority:1"`
+	Key       string    `json:"key"`
+	Payload   []byte    `json:"payload" gorm:"type:bytea"`
+	Headers   Headers   `json:"headers" gorm:"type:jsonb"`
+	Status    string    `json:"status" gorm:"not null;default:pending;index:idx_queue_jobs_claim,priority:2"`
+	VisibleAt time.Time `json:"visible_at" gorm:"not null;index:idx_queue_jobs_claim,priority:3"`
+	Attempts  int       `json:"attempts" gorm:"not null;default:0"` // Количество выдач потребителям
+	LockedBy  string    `json:"locked_by"`                          // Потребитель, захвативший задание последним
+	LastError string    `json:"last_error"`
+}
+
+// TableName задает имя таблицы заданий очереди
+func (QueueJob) TableName() string {
+	return "queue_jobs"
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+// Политики пропущенных запусков расписания: запуски, время которых прошло более чем на
+// допустимую задержку, например пока сервис был остановлен
+const (
+	MissedRunSkip    = "skip"     // Пропустить все пропущенные запуски
+	MissedRunRunOnce = "run_once" // Выполнить один запуск вместо всех пропущенных
+	MissedRunRunAll  = "run_all"  // Выполнить каждый пропущенный запуск
+)
+
+// Результаты запуска расписания
+const (
+	ScheduleRunSucceeded = "succeeded" // Сообщение создано
+	ScheduleRunFailed    = "failed"    // Сообщение не создано, см. Error
+	ScheduleRunSkipped   = "skipped"   // Пропущенный запуск не выполнялся по политике расписания
+)
+
+// Schedule расписание периодической отправки сообщения. Сообщения создаются планировщиком сервиса
+// так же, как через POST /api/message.
+// swagger:model Schedule
+type Schedule struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Name            string     `json:"name" gorm:"index;not null"`
+	Cron            string     `json:"cron" gorm:"not null"`                           // Cron выражение из пяти полей или дескриптор (@hourly, @every 15m)
+	Timezone        string     `json:"timezone" gorm:"not null;default:UTC"`           // Часовой пояс IANA, в котором вычисляется cron выражение
+	Template        string     `json:"template" gorm:"not null"`                       // Шаблон text/template содержимого сообщения
+	PartitionKey    string     `json:"partition_key,omitempty"`                        // Ключ упорядочивания создаваемых сообщений
+	TenantID        string     `json:"tenant_id,omitempty"`                            // Арендатор создаваемых сообщений
+	Enabled         bool       `json:"enabled" gorm:"not null"`                        // Выключенное расписание не запускается
+	MissedRunPolicy string     `json:"missed_run_policy" gorm:"not null;default:skip"` // skip, run_once или run_all
+	NextRunAt       *time.Time `json:"next_run_at,omitempty" gorm:"index"`             // Время следующего запуска
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`                          // Время последнего выполненного запуска
+}
+
+// ScheduleRun запуск расписания в истории
+// swagger:model ScheduleRun
+type ScheduleRun struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt   time.Time `json:"created_at"`
+	ScheduleID  uint      `json:"schedule_id" gorm:"index;not null"`
+	ScheduledAt time.Time `json:"scheduled_at"`         // Время запуска по расписанию
+	Missed      bool      `json:"missed"`               // Запуск выполнен или пропущен позже допустимой задержки
+	Status      string    `json:"status" gorm:"index"`  // succeeded, failed или skipped
+	MessageID   *uint     `json:"message_id,omitempty"` // Созданное сообщение
+	Error       string    `json:"error,omitempty"`
+}
+
+// ScheduleRequest запрос на создание или изменение расписания
+// swagger:model ScheduleRequest
+type ScheduleRequest struct {
+	Name            string `json:"name"`
+	Cron            string `json:"cron"`               // Например "0 9 * * 1-5" или "@every 15m"
+	Timezone        string `json:"timezone,omitempty"` // По умолчанию UTC
+	Template        string `json:"template"`           // Например: Отчет за {{.ScheduledAt.Format "2006-01-02"}}
+	PartitionKey    string `json:"partition_key,omitempty"`
+	TenantID        string `json:"tenant_id,omitempty"`
+	Enabled         *bool  `json:"enabled,omitempty"`           // По умолчанию true
+	MissedRunPolicy string `json:"missed_run_policy,omitempty"` // По умолчанию skip
+}
@@ -5,21 +5,145 @@ import (
 	"github.com/gofiber/fiber/v2" // Импортируем Fiber
 	"go_microsvc/database"        // Подключение к базе данных
 	"go_microsvc/handlers"        // Импортируем пакет с обработчиками
+	"go_microsvc/services"        // Сервисный слой
 )
 
-// SetupRoutes инициализирует все маршруты для API
-func SetupRoutes(app *fiber.App, db *database.Database) {
+// Dependencies сервисы, используемые обработчиками API
+type Dependencies struct {
+	Broker    string // Брокер сообщений (config.Broker)
+	DB        *database.Database
+	Messages  *services.MessageService
+	DLQ       *services.DeadLetterQueue
+	Consumer  *services.Consumer
+	Offsets   *services.OffsetAdmin
+	Audit     *services.AuditLog
+	Attempts  *services.AttemptLog
+	Schedules *services.ScheduleService
+
+	// Используются только с брокером Kafka
+	Monitor *services.ConsumerMonitor
+	Topics  *services.TopicManager
+}
+
+// SetupRoutes инициализирует все маршруты для API. Мониторинг групп потребителей, сброс смещений
+// и администрирование топиков работают только с Kafka: для других брокеров они отвечают 501.
+func SetupRoutes(app *fiber.App, deps Dependencies) {
+	// kafkaOnly заменяет обработчик ответом 501, если брокер сообщений не Kafka
+	kafkaOnly := func(handler fiber.Handler) fiber.Handler {
+		if services.IsKafkaBroker(deps.Broker) {
+			return handler
+		}
+		return handlers.BrokerNotSupported
+	}
+
 	api := app.Group("/api")
 
 	api.Post("/message", func(c *fiber.Ctx) error {
-		return handlers.CreateMessage(c, db) // Вызов обработчика для создания сообщения
+		return handlers.CreateMessage(c, deps.Messages) // Вызов обработчика для создания сообщения
+	})
+
+	api.Post("/messages/batch", func(c *fiber.Ctx) error {
+		return handlers.CreateMessagesBatch(c, deps.Messages) // Вызов обработчика для пакетного создания сообщений
 	})
 
 	api.Get("/stats", func(c *fiber.Ctx) error {
-		return handlers.GetMessageStats(c, db) // Вызов обработчика для получения статистики
+		return handlers.GetMessageStats(c, deps.DB) // Вызов обработчика для получения статистики
 	})
 
 	api.Get("/messages", func(c *fiber.Ctx) error {
-		return handlers.GetMessages(c, db) // Вызов обработчика для получения сообщений из базы данных
+		return handlers.GetMessages(c, deps.DB) // Вызов обработчика для получения сообщений из базы данных
+	})
+
+	api.Post("/messages/:id/cancel", func(c *fiber.Ctx) error {
+		return handlers.CancelMessage(c, deps.Messages) // Отмена отложенного сообщения
+	})
+
+	api.Post("/messages/:id/reschedule", func(c *fiber.Ctx) error {
+		return handlers.RescheduleMessage(c, deps.Messages) // Перенос отложенного сообщения
+	})
+
+	api.Get("/messages/:id/attempts", func(c *fiber.Ctx) error {
+		return handlers.GetMessageAttempts(c, deps.Attempts) // История попыток обработки сообщения
+	})
+
+	api.Get("/schedules", func(c *fiber.Ctx) error {
+		return handlers.ListSchedules(c, deps.Schedules) // Список расписаний
+	})
+
+	api.Post("/schedules", func(c *fiber.Ctx) error {
+		return handlers.CreateSchedule(c, deps.Schedules) // Создание расписания
+	})
+
+	api.Get("/schedules/:id", func(c *fiber.Ctx) error {
+		return handlers.GetSchedule(c, deps.Schedules) // Просмотр расписания
+	})
+
+	api.Put("/schedules/:id", func(c *fiber.Ctx) error {
+		return handlers.UpdateSchedule(c, deps.Schedules) // Изменение расписания
+	})
+
+	api.Delete("/schedules/:id", func(c *fiber.Ctx) error {
+		return handlers.DeleteSchedule(c, deps.Schedules) // Удаление расписания
+	})
+
+	api.Get("/schedules/:id/runs", func(c *fiber.Ctx) error {
+		return handlers.GetScheduleRuns(c, deps.Schedules) // История запусков расписания
+	})
+
+	api.Get("/metrics", handlers.GetMetrics) // Метрики consumer
+
+	api.Get("/health", func(c *fiber.Ctx) error {
+		return handlers.GetHealth(c, deps.Consumer) // Проверка работоспособности consumer
+	})
+
+	// Администрирование consumer и dead-letter очереди
+	admin := api.Group("/admin")
+
+	admin.Get("/consumers", kafkaOnly(func(c *fiber.Ctx) error {
+		return handlers.GetConsumers(c, deps.Monitor) // Отставание групп потребителей
+	}))
+
+	admin.Post("/consumers/pause", func(c *fiber.Ctx) error {
+		return handlers.PauseConsumers(c, deps.Offsets, deps.Audit) // Пауза чтения
+	})
+
+	admin.Post("/consumers/resume", func(c *fiber.Ctx) error {
+		return handlers.ResumeConsumers(c, deps.Offsets, deps.Audit) // Возобновление чтения
+	})
+
+	admin.Post("/consumers/reset-offsets", kafkaOnly(func(c *fiber.Ctx) error {
+		return handlers.ResetConsumerOffsets(c, deps.Offsets, deps.Audit) // Сброс смещений группы
+	}))
+
+	admin.Get("/dlq", func(c *fiber.Ctx) error {
+		return handlers.ListDeadLetters(c, deps.DLQ) // Список записей DLQ
+	})
+
+	admin.Get("/dlq/:id", func(c *fiber.Ctx) error {
+		return handlers.GetDeadLetter(c, deps.DLQ) // Просмотр записи DLQ
+	})
+
+	admin.Post("/dlq/:id/redrive", func(c *fiber.Ctx) error {
+		return handlers.RedriveDeadLetter(c, deps.DLQ, deps.Audit) // Возврат записи DLQ в исходный топик
+	})
+
+	admin.Get("/topics", kafkaOnly(func(c *fiber.Ctx) error {
+		return handlers.ListTopics(c, deps.Topics) // Список топиков
+	}))
+
+	admin.Get("/topics/:name", kafkaOnly(func(c *fiber.Ctx) error {
+		return handlers.DescribeTopic(c, deps.Topics) // Партиции, лидеры и параметры топика
+	}))
+
+	admin.Post("/topics", kafkaOnly(func(c *fiber.Ctx) error {
+		return handlers.CreateTopic(c, deps.Topics, deps.Audit) // Создание топика
+	}))
+
+	admin.Post("/topics/:name/partitions", kafkaOnly(func(c *fiber.Ctx) error {
+		return handlers.AddTopicPartitions(c, deps.Topics, deps.Audit) // Увеличение количества партиций
+	}))
+
+	admin.Get("/audit", func(c *fiber.Ctx) error {
+		return handlers.ListAuditLog(c, deps.Audit) // Журнал административных действий
 	})
 }
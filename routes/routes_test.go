@@ -0,0 +1,261 @@
+// Package routes routes_test.go
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"go_microsvc/config"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"go_microsvc/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pipeline API и consumer одного процесса на брокере в памяти и SQLite в памяти
+type pipeline struct {
+	app    *fiber.App
+	db     *database.Database
+	broker *services.MemoryBroker
+	fail   atomic.Bool // Обработчик сообщений завершается ошибкой
+}
+
+// newPipeline запускает outbox relay, consumer и маршруты API так же, как cmd/api, но без внешних сервисов
+func newPipeline(t *testing.T, broker string) *pipeline {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	gormDB, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared&_pragma=foreign_keys(1)", name)), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	// SQLite допускает одну запись за раз: запросы relay, consumer и API выполняются по очереди
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		t.Fatalf("gorm.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err := database.Migrate(gormDB); err != nil {
+		t.Fatalf("database.Migrate: %v", err)
+	}
+
+	p := &pipeline{db: &database.Database{DB: gormDB}, broker: services.NewMemoryBroker(services.MemoryBrokerConfig{Partitions: 2})}
+	consumerCfg := services.ConsumerConfig{Topic: "orders", GroupID: "group", RetryBackoff: 10 * time.Millisecond}
+	producer := p.broker.Publisher(services.ProducerConfig{Topic: consumerCfg.Topic})
+	dlq := services.NewDeadLetterQueue(p.db, producer, "orders.dlq")
+	retries := services.NewRetryRouter(producer, dlq, config.RetryPolicy{Tiers: []time.Duration{50 * time.Millisecond}, MaxAttempts: 2}, nil)
+	retries.PriorityTopics(consumerCfg.Topic)
+
+	handlers := services.NewHandlerRegistry(p.db, consumerCfg)
+	builtin := services.MessageHandler(p.db)
+	handlers.Register(services.MessageTypeDefault, services.HandlerFunc(func(ctx context.Context, env services.Envelope) error {
+		if p.fail.Load() {
+			return errors.New("обработка не удалась")
+		}
+		return builtin.Handle(ctx, env)
+	}))
+	consumer := services.NewConsumer(p.db, p.broker.Subscriber(consumerCfg), handlers, consumerCfg, retries)
+	if err := consumer.Start(context.Background()); err != nil {
+		t.Fatalf("consumer.Start: %v", err)
+	}
+	t.Cleanup(func() { _ = consumer.Stop(context.Background()) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	relay := services.NewOutboxRelay(p.db, producer, services.OutboxRelayConfig{PollInterval: 20 * time.Millisecond})
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-relayDone
+	})
+
+	p.app = fiber.New()
+	p.app.Use(requestid.New())
+	SetupRoutes(p.app, Dependencies{
+		Broker:    broker,
+		DB:        p.db,
+		Messages:  services.NewMessageService(p.db, services.MessageServiceConfig{Topic: consumerCfg.Topic, SourceService: "test", Relay: relay}),
+		DLQ:       dlq,
+		Consumer:  consumer,
+		Offsets:   services.NewOffsetAdmin(consumerCfg, consumer.PauseController()),
+		Audit:     services.NewAuditLog(p.db),
+		Attempts:  services.NewAttemptLog(p.db),
+		Schedules: services.NewScheduleService(p.db, nil, services.SchedulerConfig{}),
+	})
+	return p
+}
+
+// do выполняет запрос к API и разбирает JSON ответа в out, если он задан
+func (p *pipeline) do(t *testing.T, method, path string, body interface{}, headers map[string]string, out interface{}) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("json.Marshal: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := p.app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("чтение ответа %s %s: %v", method, path, err)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("разбор ответа %s %s (%d): %v: %s", method, path, resp.StatusCode, err, data)
+		}
+	}
+	return resp.StatusCode
+}
+
+// waitStatus ждет, пока сообщение id перейдет в статус status, и возвращает его
+func (p *pipeline) waitStatus(t *testing.T, id uint, status string) models.Message {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var messages []models.Message
+		if code := p.do(t, "GET", "/api/messages?limit=100&status="+status, nil, nil, &messages); code != 200 {
+			t.Fatalf("GET /api/messages: %d", code)
+		}
+		for _, msg := range messages {
+			if msg.ID == id {
+				return msg
+			}
+		}
+		if time.Now().After(deadline) {
+			var msg models.Message
+			p.db.Unscoped().First(&msg, id)
+			t.Fatalf("сообщение %d не перешло в статус %s, текущий статус %s", id, status, msg.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestPipelineDeliversMessageFromAPIToConsumer(t *testing.T) {
+	p := newPipeline(t, services.BrokerMemory)
+
+	var created models.Message
+	if code := p.do(t, "POST", "/api/message", models.CreateMessageRequest{Content: "hello"}, nil, &created); code != 201 {
+		t.Fatalf("POST /api/message: %d", code)
+	}
+	if created.Status != models.MessageStatusPending {
+		t.Errorf("статус созданного сообщения: %s, ожидался pending", created.Status)
+	}
+
+	// Relay публикует запись outbox, consumer обрабатывает ее встроенным обработчиком
+	msg := p.waitStatus(t, created.ID, models.MessageStatusProcessed)
+	if msg.PublishedAt == nil || msg.ProcessedAt == nil || msg.Attempts != 1 {
+		t.Errorf("обработанное сообщение: published_at=%v processed_at=%v attempts=%d", msg.PublishedAt, msg.ProcessedAt, msg.Attempts)
+	}
+	var record models.OutboxRecord
+	if err := p.db.Where("message_id = ?", created.ID).First(&record).Error; err != nil || record.Status != models.OutboxStatusSent {
+		t.Errorf("запись outbox: %+v (err=%v), ожидался статус sent", record, err)
+	}
+
+	var attempts []models.MessageAttempt
+	if code := p.do(t, "GET", fmt.Sprintf("/api/messages/%d/attempts", created.ID), nil, nil, &attempts); code != 200 {
+		t.Fatalf("GET attempts: %d", code)
+	}
+	if len(attempts) != 1 || attempts[0].Outcome != models.AttemptSucceeded || attempts[0].Topic != "orders" {
+		t.Errorf("попытки обработки: %+v", attempts)
+	}
+}
+
+func TestPipelineRoutesFailedMessageThroughDLQAndRedrive(t *testing.T) {
+	p := newPipeline(t, services.BrokerMemory)
+	p.fail.Store(true)
+
+	var created models.Message
+	if code := p.do(t, "POST", "/api/message", models.CreateMessageRequest{Content: "order"}, nil, &created); code != 201 {
+		t.Fatalf("POST /api/message: %d", code)
+	}
+
+	// Первая попытка завершается ошибкой, вторая через retry топик исчерпывает попытки
+	p.waitStatus(t, created.ID, models.MessageStatusDeadLettered)
+	var letters []models.DeadLetter
+	if code := p.do(t, "GET", "/api/admin/dlq", nil, nil, &letters); code != 200 || len(letters) != 1 {
+		t.Fatalf("GET /api/admin/dlq: %d, записей %d", code, len(letters))
+	}
+	if letter := letters[0]; letter.OriginalTopic != "orders" || letter.Attempts != 2 || letter.Reason != services.DLQReasonProcessing {
+		t.Errorf("запись DLQ: %+v", letter)
+	}
+	var attempts []models.MessageAttempt
+	p.do(t, "GET", fmt.Sprintf("/api/messages/%d/attempts", created.ID), nil, nil, &attempts)
+	if len(attempts) != 2 || attempts[0].Outcome != models.AttemptFailed || attempts[1].Topic != "orders.retry.50ms" {
+		t.Errorf("попытки обработки: %+v", attempts)
+	}
+
+	// Возврат из DLQ требует пользователя для журнала аудита
+	redrive := fmt.Sprintf("/api/admin/dlq/%d/redrive", letters[0].ID)
+	if code := p.do(t, "POST", redrive, nil, nil, nil); code != 400 {
+		t.Errorf("redrive без X-Actor: %d, ожидался 400", code)
+	}
+	p.fail.Store(false)
+	if code := p.do(t, "POST", redrive, nil, map[string]string{"X-Actor": "alice"}, nil); code != 200 {
+		t.Fatalf("redrive: %d", code)
+	}
+	p.waitStatus(t, created.ID, models.MessageStatusProcessed)
+
+	var entries []models.AuditEntry
+	p.do(t, "GET", "/api/admin/audit?action="+services.AuditDLQRedrive, nil, nil, &entries)
+	if len(entries) != 1 || entries[0].Actor != "alice" || !entries[0].Success || entries[0].Details["original_topic"] != "orders" {
+		t.Errorf("журнал аудита: %+v", entries)
+	}
+}
+
+func TestKafkaOnlyRoutesWithOtherBrokers(t *testing.T) {
+	p := newPipeline(t, services.BrokerMemory)
+	for _, route := range []struct{ method, path string }{
+		{"GET", "/api/admin/consumers"},
+		{"POST", "/api/admin/consumers/reset-offsets"},
+		{"GET", "/api/admin/topics"},
+		{"GET", "/api/admin/topics/orders"},
+		{"POST", "/api/admin/topics"},
+		{"POST", "/api/admin/topics/orders/partitions"},
+	} {
+		if code := p.do(t, route.method, route.path, nil, map[string]string{"X-Actor": "alice"}, nil); code != 501 {
+			t.Errorf("%s %s: %d, ожидался 501", route.method, route.path, code)
+		}
+	}
+
+	// Пауза работает с любым брокером и записывается в журнал аудита
+	if code := p.do(t, "POST", "/api/admin/consumers/pause", nil, nil, nil); code != 400 {
+		t.Errorf("пауза без X-Actor: %d, ожидался 400", code)
+	}
+	var pause struct {
+		Paused bool `json:"paused"`
+	}
+	if code := p.do(t, "POST", "/api/admin/consumers/pause", nil, map[string]string{"X-Actor": "alice"}, &pause); code != 200 || !pause.Paused {
+		t.Errorf("пауза: %d, paused=%t", code, pause.Paused)
+	}
+	var entries []models.AuditEntry
+	p.do(t, "GET", "/api/admin/audit?action="+services.AuditConsumerPause, nil, nil, &entries)
+	if len(entries) != 1 || entries[0].Actor != "alice" {
+		t.Errorf("журнал аудита: %+v", entries)
+	}
+}
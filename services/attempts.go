@@ -0,0 +1,119 @@
+// Package services attempts.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"gorm.io/gorm"
+	"log"
+	"os"
+	"time"
+)
+
+// instanceID идентификатор экземпляра consumer в истории попыток: хост и PID
+var instanceID = func() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}()
+
+// InstanceID возвращает идентификатор экземпляра: хост и PID
+func InstanceID() string {
+	return instanceID
+}
+
+// AttemptLog история попыток обработки сообщений
+type AttemptLog struct {
+	db *database.Database
+}
+
+// NewAttemptLog создает историю попыток обработки
+func NewAttemptLog(db *database.Database) *AttemptLog {
+	return &AttemptLog{db: db}
+}
+
+// Record сохраняет попытки обработки. Ошибка сохранения не влияет на обработку записи и только логируется.
+// Попытки сообщений, которых нет в таблице messages (например, записей внешних производителей), нарушают
+// внешний ключ и не сохраняются; остальные попытки пачки при этом сохраняются.
+func (a *AttemptLog) Record(ctx context.Context, attempts ...models.MessageAttempt) {
+	if len(attempts) == 0 {
+		return
+	}
+	err := a.db.WithContext(ctx).CreateInBatches(&attempts, batchInsertSize).Error
+	if err != nil {
+		// Пачка вставляется в одной транзакции: повторяется вставка только попыток существующих сообщений
+		known, knownErr := a.knownAttempts(ctx, attempts)
+		if knownErr == nil && len(known) < len(attempts) {
+			log.Printf("Попыток сообщений, отсутствующих в базе данных, пропущено: %d", len(attempts)-len(known))
+			err = nil
+			if len(known) > 0 {
+				err = a.db.WithContext(ctx).CreateInBatches(&known, batchInsertSize).Error
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("Ошибка сохранения истории попыток обработки: %v", err)
+	}
+}
+
+// knownAttempts возвращает попытки сообщений, которые есть в таблице messages, включая удаленные мягко
+func (a *AttemptLog) knownAttempts(ctx context.Context, attempts []models.MessageAttempt) ([]models.MessageAttempt, error) {
+	ids := make([]uint, len(attempts))
+	for i, attempt := range attempts {
+		ids[i] = attempt.MessageID
+	}
+	var existing []uint
+	if err := a.db.WithContext(ctx).Unscoped().Model(&models.Message{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+		return nil, err
+	}
+	found := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		found[id] = true
+	}
+	known := make([]models.MessageAttempt, 0, len(attempts))
+	for _, attempt := range attempts {
+		if found[attempt.MessageID] {
+			known = append(known, attempt)
+		}
+	}
+	return known, nil
+}
+
+// List возвращает попытки обработки сообщения в порядке выполнения
+func (a *AttemptLog) List(ctx context.Context, messageID uint, offset, limit int) ([]models.MessageAttempt, error) {
+	err := a.db.WithContext(ctx).Unscoped().Select("id").First(&models.Message{}, messageID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := []models.MessageAttempt{}
+	err = a.db.WithContext(ctx).Where("message_id = ?", messageID).Order("id").Offset(offset).Limit(limit).Find(&attempts).Error
+	return attempts, err
+}
+
+// newAttempt формирует попытку обработки записи, начатую в start
+func newAttempt(env Envelope, start time.Time, cause error) models.MessageAttempt {
+	attempt := models.MessageAttempt{
+		MessageID:  env.MessageID,
+		StartedAt:  start,
+		Consumer:   instanceID,
+		Topic:      env.Topic,
+		Partition:  env.Partition,
+		Offset:     env.Offset,
+		DurationMs: time.Since(start).Milliseconds(),
+		Outcome:    models.AttemptSucceeded,
+	}
+	if cause != nil {
+		attempt.Outcome = models.AttemptFailed
+		attempt.Error = cause.Error()
+	}
+	return attempt
+}
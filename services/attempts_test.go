@@ -0,0 +1,35 @@
+// Package services attempts_test.go
+package services
+
+import (
+	"context"
+	"go_microsvc/models"
+	"testing"
+	"time"
+)
+
+func TestAttemptLogRecordSkipsUnknownMessages(t *testing.T) {
+	db := testDatabase(t)
+	msg := models.Message{Content: "known", Status: models.MessageStatusPublished}
+	if err := db.Create(&msg).Error; err != nil {
+		t.Fatalf("создание сообщения: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Unscoped().Delete(&models.Message{}, msg.ID)
+	})
+
+	// Попытка записи внешнего производителя нарушает внешний ключ, но не отменяет остальные попытки пачки
+	attempts := NewAttemptLog(db)
+	attempts.Record(context.Background(),
+		models.MessageAttempt{MessageID: msg.ID, StartedAt: time.Now(), Outcome: models.AttemptSucceeded},
+		models.MessageAttempt{MessageID: msg.ID + 1_000_000, StartedAt: time.Now(), Outcome: models.AttemptSucceeded},
+	)
+
+	got, err := attempts.List(context.Background(), msg.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("сохранено попыток сообщения: %d, ожидалась 1", len(got))
+	}
+}
@@ -0,0 +1,55 @@
+// Package services audit.go
+package services
+
+import (
+	"context"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"log"
+)
+
+// Действия, записываемые в журнал аудита
+const (
+	AuditTopicCreate        = "topic.create"
+	AuditTopicAddPartitions = "topic.add_partitions"
+	AuditConsumerPause      = "consumer.pause"
+	AuditConsumerResume     = "consumer.resume"
+	AuditOffsetReset        = "consumer.reset_offsets"
+	AuditDLQRedrive         = "dlq.redrive"
+)
+
+// AuditLog журнал административных действий
+type AuditLog struct {
+	db *database.Database
+}
+
+// NewAuditLog создает журнал аудита
+func NewAuditLog(db *database.Database) *AuditLog {
+	return &AuditLog{db: db}
+}
+
+// Record записывает действие в журнал. Ошибка записи журнала не отменяет действие и только логируется.
+func (a *AuditLog) Record(ctx context.Context, entry models.AuditEntry, cause error) {
+	entry.Success = cause == nil
+	if cause != nil {
+		entry.Error = cause.Error()
+	}
+	log.Printf("Аудит: %s %s %s (успешно: %t)", entry.Actor, entry.Action, entry.Resource, entry.Success)
+	if err := a.db.WithContext(ctx).Create(&entry).Error; err != nil {
+		log.Printf("Ошибка записи в журнал аудита: %v", err)
+	}
+}
+
+// List возвращает записи журнала начиная с последних. Пустые action и resource не фильтруют.
+func (a *AuditLog) List(ctx context.Context, action, resource string, offset, limit int) ([]models.AuditEntry, error) {
+	query := a.db.WithContext(ctx).Order("id DESC").Offset(offset).Limit(limit)
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if resource != "" {
+		query = query.Where("resource = ?", resource)
+	}
+	var entries []models.AuditEntry
+	err := query.Find(&entries).Error
+	return entries, err
+}
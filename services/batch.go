@@ -0,0 +1,191 @@
+// Package services batch.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+// batchConsumer читает записи пачками до BatchSize записей или BatchLinger времени ожидания,
+// сохраняет их одним multi-row upsert в одной транзакции и фиксирует смещения пачки после этого
+type batchConsumer struct {
+	reader   MessageReader
+	db       *database.Database
+	handlers *HandlerRegistry
+	cfg      ConsumerConfig
+	retries  *RetryRouter
+}
+
+// run читает пачки до завершения ctx и обрабатывает их с processCtx, чтобы прочитанная пачка
+// дорабатывалась и после завершения ctx
+func (b *batchConsumer) run(ctx, processCtx context.Context) {
+	metrics.batchSize.Store(int64(b.cfg.BatchSize))
+	metrics.batchLinger.Store(int64(b.cfg.BatchLinger))
+
+	for {
+		batch, err := b.fetchBatch(ctx)
+		if len(batch) > 0 {
+			start := time.Now()
+			if err := b.processBatch(processCtx, batch); err != nil {
+				log.Printf("Обработка пачки прервана: %v", err)
+				return
+			}
+			metrics.observeBatch(len(batch), time.Since(start))
+
+			// Фиксируем смещения всей пачки после записи в базу данных
+			if err := commitMessages(processCtx, b.reader, batch...); err != nil {
+				log.Printf("Ошибка при коммите смещений пачки: %v", err)
+			}
+		}
+
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				log.Printf("Завершение работы пакетного consumer топика %s по запросу контекста", b.cfg.Topic)
+				return
+			}
+			metrics.observeFetch(0, err)
+			log.Printf("Не удалось прочитать сообщение: %v", err)
+			if err := sleepContext(ctx, 5*time.Second); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// fetchBatch ждет первую запись, а затем добирает пачку до BatchSize записей, пока не истечет BatchLinger
+func (b *batchConsumer) fetchBatch(ctx context.Context) ([]kafka.Message, error) {
+	first, err := b.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	batch := []kafka.Message{first}
+	defer func() { metrics.observeFetch(len(batch), nil) }()
+
+	lingerCtx, cancel := context.WithTimeout(ctx, b.cfg.BatchLinger)
+	defer cancel()
+	for len(batch) < b.cfg.BatchSize {
+		m, err := b.reader.FetchMessage(lingerCtx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			return batch, err
+		}
+		batch = append(batch, m)
+	}
+	return batch, nil
+}
+
+// processBatch разбирает записи пачки и сохраняет сообщения одним upsert. Записи, которые
+// не удалось разобрать, отправляются в DLQ, просроченные записи пропускаются, а записи других типов и записи, для которых зарегистрирован
+// собственный обработчик, передаются обработчикам по одной. Если upsert не удался, записи обрабатываются
+// по одной с обычной маршрутизацией ошибок в retry топики.
+func (b *batchConsumer) processBatch(ctx context.Context, batch []kafka.Message) error {
+	var valid []kafka.Message
+	var envs []Envelope // Разобранные записи valid для истории попыток
+	var msgs []models.Message
+	index := make(map[uint]int) // ID сообщения -> позиция в msgs, повторно доставленные записи схлопываются
+	now := time.Now()
+	for _, m := range batch {
+		env, err := DecodeEnvelope(m)
+		if err != nil {
+			log.Printf("Ошибка при десериализации сообщения: %v", err)
+			if err := deadLetter(ctx, b.cfg, b.retries, m, DLQReasonDecode, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if env.Expired(now) {
+			b.handlers.expire(ctx, env)
+			continue
+		}
+		if !b.handlers.bulkUpsert(env.Type) {
+			if err := handleRecord(ctx, b.handlers, b.cfg, b.retries, m); err != nil {
+				return err
+			}
+			continue
+		}
+
+		msg := env.Message
+		msg.Status = models.MessageStatusProcessed
+		msg.Attempts = 1
+		msg.ProcessedAt = &now
+		valid = append(valid, m)
+		if env.MessageID == 0 {
+			env.MessageID = msg.ID
+		}
+		envs = append(envs, env)
+		if i, ok := index[msg.ID]; ok && msg.ID != 0 {
+			msgs[i] = msg
+			continue
+		}
+		index[msg.ID] = len(msgs)
+		msgs = append(msgs, msg)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.ID != 0 {
+			ids = append(ids, msg.ID)
+		}
+	}
+	start := time.Now()
+	var updated []uint
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Существующим сообщениям меняется только статус, и только если переход в processed допустим
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"status":       models.MessageStatusProcessed,
+				"processed_at": now,
+				"last_error":   "",
+				"attempts":     gorm.Expr("messages.attempts + 1"),
+				"updated_at":   now,
+			}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: "messages.status IN ?", Vars: []interface{}{messageTransitions[models.MessageStatusProcessed]}},
+			}},
+		}).CreateInBatches(&msgs, batchInsertSize).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		// Строки с недопустимым переходом upsert пропускает; вставленные и обновленные получили processed_at этой пачки
+		return tx.Model(&models.Message{}).Where("id IN ? AND processed_at = ?", ids, now).Pluck("id", &updated).Error
+	})
+	if err == nil {
+		metrics.recordsProcessed.Add(int64(len(valid)))
+		// Сообщения пачки сохраняются одним запросом, поэтому длительность попытки — время upsert.
+		// Для пропущенных сообщений попытка не записывается, как и смена статуса.
+		saved := make(map[uint]bool, len(updated))
+		for _, id := range updated {
+			saved[id] = true
+		}
+		var attempts []models.MessageAttempt
+		for _, env := range envs {
+			if saved[env.MessageID] {
+				attempts = append(attempts, newAttempt(env, start, nil))
+			}
+		}
+		NewAttemptLog(b.db).Record(context.WithoutCancel(ctx), attempts...)
+		return nil
+	}
+
+	log.Printf("Ошибка bulk upsert пачки из %d сообщений, обработка по одному: %v", len(msgs), err)
+	metrics.batchFallbacks.Add(1)
+	for _, m := range valid {
+		if err := handleRecord(ctx, b.handlers, b.cfg, b.retries, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package services batch_test.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/models"
+	"strconv"
+	"testing"
+)
+
+// messageRecord формирует запись сообщения в формате, который публикует outbox relay
+func messageRecord(t *testing.T, msg models.Message, offset int64) kafka.Message {
+	t.Helper()
+	value, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return kafka.Message{
+		Topic:   "orders",
+		Offset:  offset,
+		Value:   value,
+		Headers: HeadersToKafka(map[string]string{HeaderMessageID: strconv.FormatUint(uint64(msg.ID), 10)}),
+	}
+}
+
+func TestBatchRecordsAttemptsOnlyForUpdatedMessages(t *testing.T) {
+	db := testDatabase(t)
+	published := models.Message{Content: "published", Status: models.MessageStatusPublished}
+	processed := models.Message{Content: "processed", Status: models.MessageStatusProcessed}
+	for _, msg := range []*models.Message{&published, &processed} {
+		if err := db.Create(msg).Error; err != nil {
+			t.Fatalf("создание сообщения: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Unscoped().Delete(&models.Message{}, []uint{published.ID, processed.ID})
+	})
+
+	cfg := ConsumerConfig{Topic: "orders", BatchSize: 10}
+	consumer := &batchConsumer{db: db, handlers: NewHandlerRegistry(db, cfg), cfg: cfg}
+	batch := []kafka.Message{messageRecord(t, published, 1), messageRecord(t, processed, 2)}
+	if err := consumer.processBatch(context.Background(), batch); err != nil {
+		t.Fatalf("processBatch: %v", err)
+	}
+
+	attempts := NewAttemptLog(db)
+	got, err := attempts.List(context.Background(), published.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != 1 || got[0].Outcome != models.AttemptSucceeded || got[0].Offset != 1 {
+		t.Errorf("попытки обновленного сообщения: %+v", got)
+	}
+
+	// Повторно доставленное обработанное сообщение upsert пропускает, попытка не записывается
+	got, err = attempts.List(context.Background(), processed.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("попытки пропущенного сообщения: %+v", got)
+	}
+}
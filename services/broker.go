@@ -0,0 +1,125 @@
+// Package services broker.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/config"
+	"go_microsvc/database"
+	"time"
+)
+
+// Реализации брокера сообщений (config.Broker)
+const (
+	BrokerKafka    = "kafka"    // Kafka через segmentio/kafka-go
+	BrokerPostgres = "postgres" // Очередь на таблице Postgres для развертываний без Kafka
+	BrokerNATS     = "nats"     // NATS JetStream, в том числе встроенный сервер
+	BrokerMemory   = "memory"   // Брокер в памяти процесса для локального запуска и тестов
+)
+
+// Publisher публикует записи. PublishSync возвращается после подтверждения всех записей;
+// при частичной ошибке возвращается kafka.WriteErrors с ошибкой для каждой записи.
+// Записи без Topic публикуются в топик по умолчанию.
+type Publisher interface {
+	PublishSync(ctx context.Context, msgs ...kafka.Message) error
+	Close(ctx context.Context) error
+}
+
+// Subscriber создает читателей топиков. Читатели с одинаковым groupID делят партиции топика
+// и фиксируют смещения группы; читатель без groupID читает все партиции без фиксации смещений.
+type Subscriber interface {
+	Subscribe(topic, groupID string) MessageReader
+}
+
+// MessageReader читает записи топика. Смещение записи фиксируется явно после обработки.
+type MessageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+	Topic() string
+	GroupID() string
+}
+
+// recordAcker реализуют читатели очередей, которые подтверждают каждую запись отдельно:
+// CommitMessages подтверждает только переданные записи, а не все записи партиции до смещения, как в Kafka
+type recordAcker interface {
+	acksRecords() bool
+}
+
+// acksRecords сообщает, подтверждает ли reader каждую запись отдельно
+func acksRecords(reader MessageReader) bool {
+	r, ok := reader.(recordAcker)
+	return ok && r.acksRecords()
+}
+
+// IsKafkaBroker сообщает, выбрана ли Kafka брокером сообщений. Администрирование топиков,
+// смещений и мониторинг групп потребителей доступны только для Kafka.
+func IsKafkaBroker(broker string) bool {
+	return broker == "" || broker == BrokerKafka
+}
+
+// NewBroker создает публикатор и подписчика реализации брокера, выбранной cfg.Broker
+func NewBroker(cfg config.Config, db *database.Database, producerCfg ProducerConfig, consumerCfg ConsumerConfig) (Publisher, Subscriber, error) {
+	switch cfg.Broker {
+	case "", BrokerKafka:
+		return NewKafkaProducer(producerCfg), NewKafkaSubscriber(consumerCfg), nil
+	case BrokerPostgres:
+		queue := NewPostgresQueue(db, PostgresQueueConfig{
+			PollInterval:      cfg.QueuePollInterval,
+			BatchSize:         cfg.QueueBatchSize,
+			VisibilityTimeout: cfg.QueueVisibilityTimeout,
+			MaxDeliveries:     cfg.QueueMaxDeliveries,
+		})
+		return queue.Publisher(producerCfg.Topic), queue.Subscriber(), nil
+	case BrokerNATS:
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		broker, err := NewNATSBroker(ctx, NATSConfig{
+			URL:           cfg.NatsURL,
+			Stream:        cfg.NatsStream,
+			SubjectPrefix: cfg.NatsSubjectPrefix,
+			AckWait:       cfg.NatsAckWait,
+			MaxDeliver:    cfg.NatsMaxDeliver,
+		}, consumerCfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return broker.Publisher(producerCfg.Topic), broker.Subscriber(), nil
+	case BrokerMemory:
+		broker := NewMemoryBroker(MemoryBrokerConfig{Partitions: 1})
+		return broker.Publisher(producerCfg), broker.Subscriber(consumerCfg), nil
+	default:
+		return nil, nil, fmt.Errorf("неизвестный брокер сообщений: %q (ожидается %s, %s, %s или %s)", cfg.Broker, BrokerKafka, BrokerPostgres, BrokerNATS, BrokerMemory)
+	}
+}
+
+// KafkaSubscriber создает kafka.Reader с параметрами ConsumerConfig
+type KafkaSubscriber struct {
+	cfg ConsumerConfig
+}
+
+// NewKafkaSubscriber создает подписчика Kafka
+func NewKafkaSubscriber(cfg ConsumerConfig) *KafkaSubscriber {
+	return &KafkaSubscriber{cfg: cfg}
+}
+
+// Subscribe создает kafka.Reader топика в составе группы
+func (s *KafkaSubscriber) Subscribe(topic, groupID string) MessageReader {
+	return kafkaReader{kafka.NewReader(s.cfg.ReaderConfig(topic, groupID))}
+}
+
+// kafkaReader добавляет к kafka.Reader методы MessageReader
+type kafkaReader struct {
+	*kafka.Reader
+}
+
+// Topic возвращает читаемый топик
+func (r kafkaReader) Topic() string {
+	return r.Config().Topic
+}
+
+// GroupID возвращает группу потребителей
+func (r kafkaReader) GroupID() string {
+	return r.Config().GroupID
+}
@@ -0,0 +1,261 @@
+// Package services consumer.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/database"
+	"log"
+	"sync"
+	"time"
+)
+
+// Состояния consumer
+const (
+	ConsumerStateIdle     = "idle"     // Создан, но не запущен
+	ConsumerStateRunning  = "running"  // Читает записи
+	ConsumerStatePaused   = "paused"   // Приостановлен, readers покинули группы
+	ConsumerStateStopping = "stopping" // Дорабатывает прочитанные записи
+	ConsumerStateStopped  = "stopped"  // Остановлен
+)
+
+// ErrConsumerStarted возвращается при повторном запуске consumer
+var ErrConsumerStarted = errors.New("consumer уже запущен")
+
+// ConsumerHealth состояние consumer для проверки работоспособности
+// swagger:model ConsumerHealth
+type ConsumerHealth struct {
+	State        string     `json:"state"`
+	Healthy      bool       `json:"healthy"`
+	Topics       []string   `json:"topics"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	LastRecordAt *time.Time `json:"last_record_at,omitempty"` // Последнее успешное чтение записи
+	LastError    string     `json:"last_error,omitempty"`     // Последняя ошибка чтения
+	LastErrorAt  *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Consumer читает топики приоритетов и retry топики основного топика в составе групп и передает записи
+// обработчикам. Топики приоритетов читаются вместе с учетом весов (см. priorityReader).
+//
+// Гарантия доставки "как минимум один раз": смещение записи фиксируется только после того, как
+// обработчик завершился успешно или запись перенаправлена в retry топик или DLQ. Запись, обработка
+// которой прервана остановкой, будет прочитана повторно, поэтому обработчики должны быть идемпотентными.
+type Consumer struct {
+	db         *database.Database
+	subscriber Subscriber
+	handlers   *HandlerRegistry
+	cfg        ConsumerConfig
+	retries    *RetryRouter
+	pause      *PauseController
+
+	mu        sync.Mutex
+	state     string
+	startedAt time.Time
+	stopFetch context.CancelFunc // Прекращает чтение новых записей
+	abort     context.CancelFunc // Прерывает обработку прочитанных записей
+	done      chan struct{}
+}
+
+// NewConsumer создает consumer, читающий подписки через subscriber. Для запуска используется Start,
+// для остановки — Stop.
+func NewConsumer(db *database.Database, subscriber Subscriber, handlers *HandlerRegistry, cfg ConsumerConfig, retries *RetryRouter) *Consumer {
+	return &Consumer{
+		db:         db,
+		subscriber: subscriber,
+		handlers:   handlers,
+		cfg:        cfg,
+		retries:    retries,
+		pause:      NewPauseController(),
+		state:      ConsumerStateIdle,
+	}
+}
+
+// PauseController возвращает контроллер паузы consumer
+func (c *Consumer) PauseController() *PauseController {
+	return c.pause
+}
+
+// Start запускает чтение всех подписок в фоне. Завершение ctx равносильно Stop без ограничения
+// времени: прочитанные записи дорабатываются, их смещения фиксируются.
+func (c *Consumer) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state != ConsumerStateIdle {
+		return ErrConsumerStarted
+	}
+
+	fetchCtx, stopFetch := context.WithCancel(ctx)
+	// Обработка не прерывается вместе с ctx, чтобы дождаться прочитанных записей
+	processCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	c.stopFetch, c.abort = stopFetch, abort
+	c.done = make(chan struct{})
+	c.state = ConsumerStateRunning
+	c.startedAt = time.Now()
+
+	go func() {
+		defer close(c.done)
+		defer abort()
+		c.run(fetchCtx, processCtx)
+
+		c.mu.Lock()
+		c.state = ConsumerStateStopped
+		c.mu.Unlock()
+		log.Println("Kafka consumer остановлен")
+	}()
+	log.Printf("Kafka consumer запущен: группа %s, топик %s", c.cfg.GroupID, c.cfg.Topic)
+	return nil
+}
+
+// Stop прекращает чтение новых записей и ждет, пока прочитанные записи будут обработаны и их смещения
+// зафиксированы. Если ctx завершается раньше, обработка прерывается, а незафиксированные записи будут
+// прочитаны повторно после перезапуска.
+func (c *Consumer) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	if c.done == nil {
+		c.mu.Unlock()
+		return nil
+	}
+	if c.state == ConsumerStateRunning || c.state == ConsumerStatePaused {
+		c.state = ConsumerStateStopping
+	}
+	stopFetch, abort, done := c.stopFetch, c.abort, c.done
+	c.mu.Unlock()
+
+	log.Println("Остановка Kafka consumer: ожидание обработки прочитанных записей...")
+	stopFetch()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		log.Println("Время ожидания истекло, обработка прочитанных записей прерывается")
+		abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Health возвращает состояние consumer. Consumer работоспособен, если он запущен (или приостановлен)
+// и последнее чтение записей не завершилось ошибкой.
+func (c *Consumer) Health() ConsumerHealth {
+	c.mu.Lock()
+	state, startedAt := c.state, c.startedAt
+	c.mu.Unlock()
+	if state == ConsumerStateRunning && c.pause.Paused() {
+		state = ConsumerStatePaused
+	}
+
+	health := ConsumerHealth{State: state}
+	for _, sub := range Subscriptions(c.cfg, c.retries) {
+		health.Topics = append(health.Topics, sub.Topic)
+	}
+	if !startedAt.IsZero() {
+		health.StartedAt = &startedAt
+	}
+
+	fetch := metrics.lastFetch()
+	if !fetch.recordAt.IsZero() {
+		health.LastRecordAt = &fetch.recordAt
+	}
+	if fetch.err != "" {
+		health.LastError = fetch.err
+		health.LastErrorAt = &fetch.errorAt
+	}
+
+	healthy := state == ConsumerStateRunning || state == ConsumerStatePaused
+	health.Healthy = healthy && (fetch.err == "" || fetch.recordAt.After(fetch.errorAt))
+	return health
+}
+
+// run читает все подписки до завершения fetchCtx, перезапуская readers после каждой паузы
+func (c *Consumer) run(fetchCtx, processCtx context.Context) {
+	c.pause.run(fetchCtx, func(ctx context.Context) {
+		var wg sync.WaitGroup
+		var prioritySubs []Subscription
+		for _, sub := range Subscriptions(c.cfg, c.retries) {
+			if sub.Priority != "" {
+				prioritySubs = append(prioritySubs, sub)
+				continue
+			}
+			wg.Add(1)
+			go func(sub Subscription) {
+				defer wg.Done()
+				c.consume(ctx, processCtx, sub, c.subscriber.Subscribe(sub.Topic, sub.GroupID))
+			}(sub)
+		}
+
+		// Топики приоритетов читаются одним reader с учетом весов и обрабатываются как основной топик
+		reader := newPriorityReader(c.subscriber, prioritySubs)
+		c.consume(ctx, processCtx, Subscription{Topic: reader.Topic(), GroupID: reader.GroupID()}, reader)
+		wg.Wait()
+	})
+}
+
+// consume читает записи подписки из reader до завершения ctx и закрывает его. Записи обрабатываются
+// с processCtx, поэтому прочитанная запись дорабатывается и после завершения ctx. Для retry топиков
+// (Delayed) обработка каждой записи откладывается до времени из заголовка retry-due-at.
+func (c *Consumer) consume(ctx, processCtx context.Context, sub Subscription, reader MessageReader) {
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Ошибка при закрытии reader: %v", err)
+		}
+	}()
+
+	// Основной топик может обрабатываться пачками с bulk upsert
+	if !sub.Delayed && c.cfg.BatchSize > 1 {
+		consumer := &batchConsumer{reader: reader, db: c.db, handlers: c.handlers, cfg: c.cfg, retries: c.retries}
+		consumer.run(ctx, processCtx)
+		return
+	}
+
+	// Основной топик может обрабатываться пулом с сохранением порядка по ключу
+	if !sub.Delayed && c.cfg.Workers > 1 {
+		handle := func(_ context.Context, m kafka.Message) error {
+			return handleRecord(processCtx, c.handlers, c.cfg, c.retries, m)
+		}
+		newWorkerPool(reader, handle, c.cfg.Workers, c.cfg.QueueSize).run(ctx)
+		return
+	}
+
+	for {
+		// Чтение записи без автоматической фиксации смещения
+		m, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				log.Printf("Завершение работы Kafka consumer топика %s по запросу контекста", sub.Topic)
+				return
+			}
+			metrics.observeFetch(0, err)
+			log.Printf("Не удалось прочитать сообщение: %v", err)
+			// Задержка на 5 секунд перед следующей итерацией
+			if err := sleepContext(ctx, 5*time.Second); err != nil {
+				return
+			}
+			continue
+		}
+
+		metrics.observeFetch(1, nil)
+		log.Printf("Сообщение успешно прочитано из Kafka: Topic: %s, Partition: %d, Offset: %d, Key: %s, Value: %s",
+			m.Topic, m.Partition, m.Offset, string(m.Key), string(m.Value))
+
+		// Записи retry топика упорядочены по времени готовности, поэтому ожидание блокирует только этот уровень.
+		// При остановке запись, срок которой не наступил, не обрабатывается и будет прочитана повторно.
+		// Очередь в Postgres и NATS выдают записи retry топика только к сроку, поэтому ожидание здесь
+		// не удерживает захват записи (см. PostgresQueue и NATSBroker).
+		if sub.Delayed {
+			if err := sleepContext(ctx, time.Until(RetryDueAt(m))); err != nil {
+				return
+			}
+		}
+
+		if err := handleRecord(processCtx, c.handlers, c.cfg, c.retries, m); err != nil {
+			// Запись не обработана и не перенаправлена только при прерывании обработки
+			log.Printf("Обработка сообщения прервана: %v", err)
+			return
+		}
+
+		if err := commitMessages(processCtx, reader, m); err != nil {
+			log.Printf("Ошибка при коммите смещения: %v", err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package services consumer_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/config"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testConsumer consumer основного топика "orders" на брокере в памяти
+type testConsumer struct {
+	*Consumer
+	broker    *MemoryBroker
+	publisher Publisher
+}
+
+// newTestConsumer создает consumer, передающий записи типа "test" обработчику handle
+func newTestConsumer(t *testing.T, handle HandlerFunc) *testConsumer {
+	t.Helper()
+	db := offlineDatabase(t)
+	broker := NewMemoryBroker(MemoryBrokerConfig{Partitions: 1})
+	publisher := broker.Publisher(ProducerConfig{Topic: "orders"})
+
+	cfg := ConsumerConfig{Topic: "orders", GroupID: "group", RetryBackoff: 10 * time.Millisecond}
+	dlq := NewDeadLetterQueue(db, publisher, "orders.dlq")
+	retries := NewRetryRouter(publisher, dlq, config.RetryPolicy{Tiers: []time.Duration{50 * time.Millisecond}, MaxAttempts: 2}, nil)
+	retries.PriorityTopics(cfg.Topic)
+
+	handlers := NewHandlerRegistry(db, cfg)
+	handlers.Register("test", handle)
+	return &testConsumer{Consumer: NewConsumer(db, broker.Subscriber(cfg), handlers, cfg, retries), broker: broker, publisher: publisher}
+}
+
+// publish публикует запись типа "test" в основной топик
+func (c *testConsumer) publish(t *testing.T) {
+	t.Helper()
+	err := c.publisher.PublishSync(context.Background(), kafka.Message{
+		Value:   []byte(`{}`),
+		Headers: HeadersToKafka(map[string]string{HeaderMessageType: "test"}),
+	})
+	if err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+}
+
+// committed возвращает зафиксированное смещение основного топика
+func (c *testConsumer) committed() int64 {
+	return c.broker.Committed("group", "orders")[0]
+}
+
+func TestConsumerLifecycle(t *testing.T) {
+	c := newTestConsumer(t, func(context.Context, Envelope) error { return nil })
+	if health := c.Health(); health.State != ConsumerStateIdle || health.Healthy || health.StartedAt != nil {
+		t.Errorf("до запуска: %+v", health)
+	}
+
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := c.Start(context.Background()); !errors.Is(err, ErrConsumerStarted) {
+		t.Errorf("повторный Start: %v, ожидалась ErrConsumerStarted", err)
+	}
+
+	c.publish(t)
+	waitFor(t, 5*time.Second, "фиксация записи", func() bool { return c.committed() == 1 })
+	health := c.Health()
+	if health.State != ConsumerStateRunning || !health.Healthy || health.StartedAt == nil || health.LastRecordAt == nil {
+		t.Errorf("после запуска: %+v", health)
+	}
+	for _, topic := range []string{"orders", "orders.high", "orders.low", "orders.retry.50ms", "orders.high.retry.50ms", "orders.low.retry.50ms"} {
+		if !slices.Contains(health.Topics, topic) {
+			t.Errorf("топик %s отсутствует в %v", topic, health.Topics)
+		}
+	}
+
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if health := c.Health(); health.State != ConsumerStateStopped || health.Healthy {
+		t.Errorf("после остановки: %+v", health)
+	}
+	if err := c.Stop(context.Background()); err != nil {
+		t.Errorf("повторный Stop: %v", err)
+	}
+}
+
+func TestConsumerStopDrainsInFlightRecord(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := newTestConsumer(t, func(context.Context, Envelope) error {
+		close(started)
+		<-release
+		return nil
+	})
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	c.publish(t)
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- c.Stop(context.Background()) }()
+
+	// Stop ждет обработки прочитанной записи
+	waitFor(t, time.Second, "состояние stopping", func() bool { return c.Health().State == ConsumerStateStopping })
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop завершился до обработки записи: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if offset := c.committed(); offset != 1 {
+		t.Errorf("зафиксированное смещение: %d, ожидалось 1", offset)
+	}
+}
+
+func TestConsumerStopAbortsAfterDeadline(t *testing.T) {
+	started := make(chan struct{})
+	c := newTestConsumer(t, func(ctx context.Context, _ Envelope) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	c.publish(t)
+	<-started
+
+	// Прерванная запись не фиксируется и не перенаправляется в retry топик
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop: %v, ожидалась context.DeadlineExceeded", err)
+	}
+	if offset := c.committed(); offset != 0 {
+		t.Errorf("зафиксированное смещение: %d, ожидалось 0", offset)
+	}
+	if n := len(c.broker.Messages("orders.retry.50ms")); n != 0 {
+		t.Errorf("записей в retry топике: %d", n)
+	}
+}
+
+func TestConsumerPauseAndResume(t *testing.T) {
+	handled := make(chan struct{}, 1)
+	c := newTestConsumer(t, func(context.Context, Envelope) error {
+		handled <- struct{}{}
+		return nil
+	})
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer c.Stop(context.Background())
+
+	if err := c.PauseController().Pause(context.Background()); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if health := c.Health(); health.State != ConsumerStatePaused || !c.PauseController().Paused() {
+		t.Errorf("после паузы: %+v", health)
+	}
+
+	// Записи, опубликованные во время паузы, читаются только после возобновления
+	c.publish(t)
+	select {
+	case <-handled:
+		t.Fatal("запись обработана во время паузы")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	c.PauseController().Resume()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("запись не обработана после возобновления")
+	}
+	waitFor(t, 5*time.Second, "фиксация записи", func() bool { return c.committed() == 1 })
+	if health := c.Health(); health.State != ConsumerStateRunning {
+		t.Errorf("после возобновления: %+v", health)
+	}
+}
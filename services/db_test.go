@@ -0,0 +1,69 @@
+// Package services db_test.go
+package services
+
+import (
+	"fmt"
+	"github.com/glebarez/sqlite"
+	"go_microsvc/database"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testDatabase возвращает базу данных с примененными миграциями: Postgres из TEST_POSTGRES_DSN, а без
+// переменной окружения — SQLite в памяти, общую для подключений одного теста. Например,
+// TEST_POSTGRES_DSN="host=localhost user=postgres password=postgres dbname=test sslmode=disable"
+func testDatabase(t *testing.T) *database.Database {
+	t.Helper()
+	if os.Getenv("TEST_POSTGRES_DSN") != "" {
+		return testPostgres(t)
+	}
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	return openTestDatabase(t, sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", name)))
+}
+
+// testPostgres подключается к Postgres из TEST_POSTGRES_DSN для тестов, которым нужны возможности Postgres
+// (например, SKIP LOCKED в очереди). Без переменной окружения тест пропускается.
+func testPostgres(t *testing.T) *database.Database {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN не задан")
+	}
+	return openTestDatabase(t, postgres.Open(dsn))
+}
+
+// openTestDatabase подключается к базе данных, применяет миграции и закрывает подключение после теста
+func openTestDatabase(t *testing.T, dialector gorm.Dialector) *database.Database {
+	t.Helper()
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("database.Migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return &database.Database{DB: db}
+}
+
+// offlineDatabase возвращает подключение к недоступной базе данных: каждый запрос завершается ошибкой.
+// Подходит для тестов транспорта, где ошибки записи в базу данных только логируются.
+func offlineDatabase(t *testing.T) *database.Database {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1"), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("gorm.Open: %v", err)
+	}
+	return &database.Database{DB: db}
+}
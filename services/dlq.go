@@ -0,0 +1,156 @@
+// Package services dlq.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"gorm.io/gorm"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Заголовки, добавляемые к записи при отправке в dead-letter топик
+const (
+	HeaderDLQReason            = "dlq-reason"             // Причина отправки в DLQ
+	HeaderDLQError             = "dlq-error"              // Текст последней ошибки
+	HeaderDLQAttempts          = "dlq-attempts"           // Количество попыток обработки
+	HeaderDLQOriginalTopic     = "dlq-original-topic"     // Исходный топик
+	HeaderDLQOriginalPartition = "dlq-original-partition" // Исходная партиция
+	HeaderDLQOriginalOffset    = "dlq-original-offset"    // Исходное смещение
+	HeaderDLQFailedAt          = "dlq-failed-at"          // Время отправки в DLQ (RFC 3339)
+	HeaderRedriveCount         = "redrive-count"          // Сколько раз запись возвращалась из DLQ
+)
+
+// Причины отправки записи в dead-letter топик
+const (
+	DLQReasonDecode      = "decode_error"      // Запись не удалось разобрать
+	DLQReasonProcessing  = "processing_failed" // Исчерпаны попытки обработки
+	DLQReasonUnknownType = "unknown_type"      // Нет обработчика для типа сообщения
+)
+
+// ErrDeadLetterNotFound возвращается, если запись DLQ не найдена
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetterQueue отправляет необрабатываемые записи в dead-letter топик и позволяет
+// просматривать их и возвращать в исходный топик
+type DeadLetterQueue struct {
+	db       *database.Database
+	producer Publisher
+	topic    string
+}
+
+// NewDeadLetterQueue создает DLQ, публикующую записи в указанный топик
+func NewDeadLetterQueue(db *database.Database, producer Publisher, topic string) *DeadLetterQueue {
+	return &DeadLetterQueue{db: db, producer: producer, topic: topic}
+}
+
+// Send публикует исходную запись с заголовками об ошибке в dead-letter топик и сохраняет ее в базе данных.
+// Ошибка возвращается, только если запись не удалось опубликовать: в этом случае смещение фиксировать нельзя.
+func (q *DeadLetterQueue) Send(ctx context.Context, m kafka.Message, reason string, cause error, attempts int) error {
+	now := time.Now().UTC()
+	errText := ""
+	if cause != nil {
+		errText = cause.Error()
+	}
+
+	// Записи из retry топиков возвращаются в исходный топик, а не в retry топик
+	originalTopic, originalPartition, originalOffset := RecordOrigin(m)
+
+	headers := HeadersFromKafka(m.Headers)
+	headers[HeaderDLQReason] = reason
+	headers[HeaderDLQError] = errText
+	headers[HeaderDLQAttempts] = strconv.Itoa(attempts)
+	headers[HeaderDLQOriginalTopic] = originalTopic
+	headers[HeaderDLQOriginalPartition] = strconv.Itoa(originalPartition)
+	headers[HeaderDLQOriginalOffset] = strconv.FormatInt(originalOffset, 10)
+	headers[HeaderDLQFailedAt] = now.Format(time.RFC3339Nano)
+
+	err := q.producer.PublishSync(ctx, kafka.Message{
+		Topic:   q.topic,
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: HeadersToKafka(headers),
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Запись %s/%d/%d отправлена в DLQ %s: %s: %s", m.Topic, m.Partition, m.Offset, q.topic, reason, errText)
+	recordMessageStatus(ctx, q.db.DB, m, models.MessageStatusDeadLettered, cause)
+
+	letter := models.DeadLetter{
+		Topic:             q.topic,
+		OriginalTopic:     originalTopic,
+		OriginalPartition: originalPartition,
+		OriginalOffset:    originalOffset,
+		Key:               string(m.Key),
+		Value:             m.Value,
+		Headers:           HeadersFromKafka(m.Headers),
+		Reason:            reason,
+		Error:             errText,
+		Attempts:          attempts,
+	}
+	// Топик DLQ является источником истины, поэтому ошибка сохранения в базу данных только логируется
+	if err := q.db.WithContext(ctx).Create(&letter).Error; err != nil {
+		log.Printf("Ошибка сохранения записи DLQ в базу данных: %v", err)
+	}
+	return nil
+}
+
+// List возвращает записи DLQ, начиная с последних
+func (q *DeadLetterQueue) List(ctx context.Context, offset, limit int) ([]models.DeadLetter, error) {
+	var letters []models.DeadLetter
+	err := q.db.WithContext(ctx).Order("id DESC").Offset(offset).Limit(limit).Find(&letters).Error
+	return letters, err
+}
+
+// Get возвращает запись DLQ по ID
+func (q *DeadLetterQueue) Get(ctx context.Context, id uint) (models.DeadLetter, error) {
+	var letter models.DeadLetter
+	err := q.db.WithContext(ctx).First(&letter, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return letter, ErrDeadLetterNotFound
+	}
+	return letter, err
+}
+
+// Redrive возвращает запись DLQ в исходный топик с исходными заголовками
+func (q *DeadLetterQueue) Redrive(ctx context.Context, id uint) (models.DeadLetter, error) {
+	letter, err := q.Get(ctx, id)
+	if err != nil {
+		return letter, err
+	}
+
+	// Служебные заголовки DLQ и retry топиков сбрасываются, чтобы запись получила полный набор попыток
+	headers := make(map[string]string, len(letter.Headers)+1)
+	for key, value := range letter.Headers {
+		if !strings.HasPrefix(key, "dlq-") && !strings.HasPrefix(key, "retry-") {
+			headers[key] = value
+		}
+	}
+	headers[HeaderRedriveCount] = strconv.Itoa(letter.RedriveCount + 1)
+
+	err = q.producer.PublishSync(ctx, kafka.Message{
+		Topic:   letter.OriginalTopic,
+		Key:     []byte(letter.Key),
+		Value:   letter.Value,
+		Headers: HeadersToKafka(headers),
+	})
+	if err != nil {
+		return letter, err
+	}
+	recordMessageStatus(ctx, q.db.DB, kafka.Message{Headers: HeadersToKafka(headers)}, models.MessageStatusPublished, nil)
+
+	now := time.Now()
+	letter.RedriveCount++
+	letter.RedrivenAt = &now
+	err = q.db.WithContext(ctx).Model(&letter).Updates(map[string]interface{}{
+		"redrive_count": letter.RedriveCount,
+		"redriven_at":   now,
+	}).Error
+	return letter, err
+}
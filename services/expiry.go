@@ -0,0 +1,101 @@
+// Package services expiry.go
+package services
+
+import (
+	"context"
+	"errors"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+// ExpiryJobConfig описывает параметры фоновой пометки просроченных сообщений
+type ExpiryJobConfig struct {
+	Interval  time.Duration // Интервал поиска просроченных сообщений
+	BatchSize int           // Количество сообщений, помечаемых за одну транзакцию
+}
+
+// ExpiryJob переводит в статус expired сообщения, срок действия которых истек до обработки, и снимает
+// с публикации их ожидающие записи outbox. Consumer пропускает просроченные записи и сам, задание
+// нужно для сообщений, которые до consumer не дошли: отложенных, неопубликованных или ожидающих повтора.
+type ExpiryJob struct {
+	db  *database.Database
+	cfg ExpiryJobConfig
+}
+
+// NewExpiryJob создает задание пометки просроченных сообщений
+func NewExpiryJob(db *database.Database, cfg ExpiryJobConfig) *ExpiryJob {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 30 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	return &ExpiryJob{db: db, cfg: cfg}
+}
+
+// Run запускает цикл задания и блокируется до отмены контекста
+func (j *ExpiryJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	log.Println("Задание пометки просроченных сообщений запущено")
+	for {
+		// Пока сообщения выбираются полными пачками, продолжаем без ожидания следующего тика
+		for {
+			n, err := j.expireBatch(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Ошибка пометки просроченных сообщений: %v", err)
+			}
+			if n > 0 {
+				log.Printf("Помечено просроченных сообщений: %d", n)
+			}
+			if err != nil || n < j.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Завершение работы задания пометки просроченных сообщений по запросу контекста")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireBatch помечает очередную пачку просроченных сообщений и возвращает ее размер.
+// Сообщения и записи outbox блокируются с SKIP LOCKED: запись, которую в этот момент публикует relay,
+// будет опубликована, но сообщение уже в статусе expired, и consumer пропустит запись по заголовку expires-at.
+func (j *ExpiryJob) expireBatch(ctx context.Context) (int, error) {
+	var count int
+	err := j.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var ids []uint
+		err := tx.Model(&models.Message{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("expires_at <= ? AND status IN ?", now, messageTransitions[models.MessageStatusExpired]).
+			Order("id").
+			Limit(j.cfg.BatchSize).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		count = len(ids)
+
+		if _, err := transitionMessages(tx, ids, models.MessageStatusExpired, now); err != nil {
+			return err
+		}
+		pending := tx.Model(&models.OutboxRecord{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Select("id").
+			Where("message_id IN ? AND status = ?", ids, models.OutboxStatusPending)
+		return tx.Model(&models.OutboxRecord{}).
+			Where("id IN (?)", pending).
+			Updates(map[string]interface{}{"status": models.OutboxStatusExpired, "updated_at": now}).Error
+	})
+	return count, err
+}
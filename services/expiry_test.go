@@ -0,0 +1,64 @@
+// Package services expiry_test.go
+package services
+
+import (
+	"context"
+	"go_microsvc/models"
+	"testing"
+	"time"
+)
+
+func TestExpiryJobExpiresMessagesAndOutbox(t *testing.T) {
+	db := testDatabase(t)
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	expired := models.Message{Content: "expired", Status: models.MessageStatusPending, ExpiresAt: &past}
+	live := models.Message{Content: "live", Status: models.MessageStatusPending, ExpiresAt: &future}
+	var records []models.OutboxRecord
+	for _, msg := range []*models.Message{&expired, &live} {
+		if err := db.Create(msg).Error; err != nil {
+			t.Fatalf("создание сообщения: %v", err)
+		}
+		record := models.OutboxRecord{MessageID: msg.ID, Topic: "orders", Payload: []byte("{}"), Status: models.OutboxStatusPending, NextAttemptAt: future}
+		if err := db.Create(&record).Error; err != nil {
+			t.Fatalf("создание записи outbox: %v", err)
+		}
+		records = append(records, record)
+	}
+	t.Cleanup(func() {
+		db.Delete(&models.OutboxRecord{}, []uint{records[0].ID, records[1].ID})
+		db.Unscoped().Delete(&models.Message{}, []uint{expired.ID, live.ID})
+	})
+
+	job := NewExpiryJob(db, ExpiryJobConfig{BatchSize: 1000})
+	n, err := job.expireBatch(context.Background())
+	if err != nil {
+		t.Fatalf("expireBatch: %v", err)
+	}
+	if n < 1 {
+		t.Fatalf("помечено сообщений: %d", n)
+	}
+
+	statuses := map[uint]string{}
+	for _, id := range []uint{expired.ID, live.ID} {
+		var msg models.Message
+		if err := db.First(&msg, id).Error; err != nil {
+			t.Fatalf("чтение сообщения: %v", err)
+		}
+		statuses[id] = msg.Status
+	}
+	if statuses[expired.ID] != models.MessageStatusExpired || statuses[live.ID] != models.MessageStatusPending {
+		t.Errorf("статусы сообщений: %v", statuses)
+	}
+
+	for i, want := range []string{models.OutboxStatusExpired, models.OutboxStatusPending} {
+		var record models.OutboxRecord
+		if err := db.First(&record, records[i].ID).Error; err != nil {
+			t.Fatalf("чтение записи outbox: %v", err)
+		}
+		if record.Status != want {
+			t.Errorf("статус записи outbox сообщения %d: %s, ожидался %s", record.MessageID, record.Status, want)
+		}
+	}
+}
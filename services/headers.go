@@ -0,0 +1,168 @@
+// Package services headers.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/models"
+	"strconv"
+	"time"
+)
+
+// Стандартные заголовки записей Kafka. Позволяют фильтровать и маршрутизировать сообщения без разбора тела.
+const (
+	HeaderMessageID     = "message-id"     // ID сообщения в базе данных
+	HeaderCorrelationID = "correlation-id" // ID HTTP запроса, породившего сообщение
+	HeaderContentType   = "content-type"   // Формат тела сообщения
+	HeaderSchemaVersion = "schema-version" // Версия схемы тела сообщения
+	HeaderProducedAt    = "produced-at"    // Время публикации в Kafka (RFC 3339)
+	HeaderSourceService = "source-service" // Сервис, опубликовавший сообщение
+	HeaderMessageType   = "message-type"   // Тип сообщения, по которому выбирается обработчик
+	HeaderExpiresAt     = "expires-at"     // Время, после которого сообщение не обрабатывается (RFC 3339)
+)
+
+const (
+	// ContentTypeJSON формат тела сообщений, публикуемых сервисом
+	ContentTypeJSON = "application/json"
+	// SchemaVersion текущая версия схемы models.Message в теле сообщения
+	SchemaVersion = "1"
+	// MessageTypeDefault тип сообщений models.Message, публикуемых сервисом
+	MessageTypeDefault = "message"
+)
+
+// Envelope представляет прочитанную из Kafka запись с разобранными заголовками и телом
+type Envelope struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       string
+	Time      time.Time
+
+	Type          string // Тип сообщения из заголовка message-type или поля type тела
+	MessageID     uint
+	CorrelationID string
+	ContentType   string
+	SchemaVersion string
+	SourceService string
+	ProducedAt    time.Time
+	ExpiresAt     time.Time         // Срок действия из заголовка expires-at; нулевое значение — без ограничения
+	Headers       map[string]string // Все заголовки записи, включая нестандартные
+
+	Value   []byte         // Тело записи без разбора
+	Message models.Message // Разобранное тело для сообщений типа MessageTypeDefault
+}
+
+// Decode разбирает JSON тело записи в v. Используется обработчиками сообщений других типов.
+func (e Envelope) Decode(v interface{}) error {
+	return json.Unmarshal(e.Value, v)
+}
+
+// DecodeEnvelope разбирает заголовки и JSON тело записи Kafka. Тип сообщения берется из заголовка
+// message-type, затем из поля type тела, иначе считается MessageTypeDefault. Тело сообщений
+// типа MessageTypeDefault разбирается в models.Message; поля, отсутствующие в теле, заполняются из заголовков.
+func DecodeEnvelope(m kafka.Message) (Envelope, error) {
+	headers := HeadersFromKafka(m.Headers)
+	env := Envelope{
+		Topic:         m.Topic,
+		Partition:     m.Partition,
+		Offset:        m.Offset,
+		Key:           string(m.Key),
+		Time:          m.Time,
+		CorrelationID: headers[HeaderCorrelationID],
+		ContentType:   headers[HeaderContentType],
+		SchemaVersion: headers[HeaderSchemaVersion],
+		SourceService: headers[HeaderSourceService],
+		Headers:       headers,
+		Type:          headers[HeaderMessageType],
+		Value:         m.Value,
+	}
+
+	if id, err := strconv.ParseUint(headers[HeaderMessageID], 10, 64); err == nil {
+		env.MessageID = uint(id)
+	}
+	if producedAt, err := time.Parse(time.RFC3339Nano, headers[HeaderProducedAt]); err == nil {
+		env.ProducedAt = producedAt
+	}
+	if expiresAt, err := time.Parse(time.RFC3339Nano, headers[HeaderExpiresAt]); err == nil {
+		env.ExpiresAt = expiresAt
+	}
+
+	if env.ContentType != "" && env.ContentType != ContentTypeJSON {
+		return env, fmt.Errorf("неподдерживаемый content-type: %q", env.ContentType)
+	}
+	if env.Type == "" {
+		var body struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(m.Value, &body); err != nil {
+			return env, err
+		}
+		env.Type = body.Type
+	}
+	if env.Type == "" {
+		env.Type = MessageTypeDefault
+	}
+	if env.Type != MessageTypeDefault {
+		return env, nil
+	}
+
+	if err := json.Unmarshal(m.Value, &env.Message); err != nil {
+		return env, err
+	}
+
+	if env.Message.ID == 0 {
+		env.Message.ID = env.MessageID
+	}
+	if env.Message.CorrelationID == "" {
+		env.Message.CorrelationID = env.CorrelationID
+	}
+	if env.Message.SourceService == "" {
+		env.Message.SourceService = env.SourceService
+	}
+	if env.Message.SchemaVersion == "" {
+		env.Message.SchemaVersion = env.SchemaVersion
+	}
+	if env.ExpiresAt.IsZero() && env.Message.ExpiresAt != nil {
+		env.ExpiresAt = *env.Message.ExpiresAt
+	}
+	return env, nil
+}
+
+// Expired сообщает, истек ли срок действия записи к моменту now
+func (e Envelope) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// HeadersToKafka преобразует заголовки в формат kafka-go
+func HeadersToKafka(headers map[string]string) []kafka.Header {
+	result := make([]kafka.Header, 0, len(headers))
+	for key, value := range headers {
+		result = append(result, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	return result
+}
+
+// HeadersFromKafka преобразует заголовки kafka-go в map. При повторе ключа побеждает последнее значение.
+func HeadersFromKafka(headers []kafka.Header) map[string]string {
+	result := make(map[string]string, len(headers))
+	for _, h := range headers {
+		result[h.Key] = string(h.Value)
+	}
+	return result
+}
+
+// correlationIDKey ключ контекста для ID корреляции
+type correlationIDKey struct{}
+
+// WithCorrelationID возвращает контекст с ID корреляции, который попадет в заголовки сообщений
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext возвращает ID корреляции из контекста
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
@@ -10,205 +10,289 @@ import (
 	_ "go_microsvc/docs" // Сгенерированные Swagger-документы
 	"go_microsvc/models"
 	"log" // Для логирования
+	"strings"
+	"sync"
 	"time"
 )
 
-// KafkaProducer представляет собой структуру для работы с Kafka producer
+// DeliveryCallback вызывается по завершении доставки пачки сообщений в Kafka.
+// err == nil означает, что все сообщения пачки подтверждены брокером.
+type DeliveryCallback func(messages []kafka.Message, err error)
+
+// ProducerConfig описывает параметры Kafka producer
+type ProducerConfig struct {
+	Brokers      string           // Адреса брокеров Kafka через запятую
+	Topic        string           // Топик по умолчанию
+	Balancer     kafka.Balancer   // Выбор партиции по ключу, по умолчанию kafka.Hash
+	Async        bool             // Publish не ждет подтверждения доставки
+	MaxAttempts  int              // Максимальное количество попыток отправки
+	BatchSize    int              // Максимальный размер пачки сообщений
+	BatchTimeout time.Duration    // Максимальное время накопления пачки
+	RequiredAcks int              // Количество подтверждений от брокеров (-1, 0, 1)
+	OnDelivery   DeliveryCallback // Необязательный колбэк завершения доставки
+}
+
+// KafkaProducer представляет собой долгоживущий Kafka producer, общий для всех обработчиков.
+// Создается один раз при старте приложения и закрывается при завершении работы.
 type KafkaProducer struct {
-	writer *kafka.Writer
+	writer     *kafka.Writer
+	topic      string
+	async      bool
+	onDelivery DeliveryCallback
+
+	mu      sync.RWMutex
+	closed  bool
+	pending sync.WaitGroup // Незавершенные асинхронные отправки
 }
 
-// NewKafkaProducer инициализирует новый Kafka producer и управляет его жизненным циклом
-func NewKafkaProducer(ctx context.Context, brokers string, topic string) error {
-	producer := &KafkaProducer{
+// ErrProducerClosed возвращается при попытке отправки через закрытый producer
+var ErrProducerClosed = errors.New("kafka producer закрыт")
+
+// NewKafkaProducer инициализирует новый Kafka producer
+func NewKafkaProducer(cfg ProducerConfig) *KafkaProducer {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.Balancer == nil {
+		cfg.Balancer = &kafka.Hash{}
+	}
+
+	return &KafkaProducer{
+		// Топик не задается на уровне writer, чтобы можно было отправлять сообщения в разные топики
 		writer: &kafka.Writer{
-			Addr:        kafka.TCP(brokers),
-			Topic:       topic,
-			Balancer:    &kafka.LeastBytes{},
-			MaxAttempts: 3,
-			Async:       true, // Асинхронный режим для повышения производительности
+			Addr:         kafka.TCP(strings.Split(cfg.Brokers, ",")...),
+			Balancer:     cfg.Balancer,
+			MaxAttempts:  cfg.MaxAttempts,
+			BatchSize:    cfg.BatchSize,
+			BatchTimeout: cfg.BatchTimeout,
+			RequiredAcks: kafka.RequiredAcks(cfg.RequiredAcks),
+			Async:        false, // Асинхронность реализована на уровне KafkaProducer
 		},
+		topic:      cfg.Topic,
+		async:      cfg.Async,
+		onDelivery: cfg.OnDelivery,
+	}
+}
+
+// Publish отправляет сообщения в Kafka в режиме, заданном конфигурацией.
+// В асинхронном режиме ошибки доставки передаются в OnDelivery.
+func (p *KafkaProducer) Publish(ctx context.Context, messages ...kafka.Message) error {
+	if p.async {
+		return p.PublishAsync(nil, messages...)
+	}
+	return p.PublishSync(ctx, messages...)
+}
+
+// PublishSync отправляет сообщения и ждет подтверждения от брокера
+func (p *KafkaProducer) PublishSync(ctx context.Context, messages ...kafka.Message) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrProducerClosed
+	}
+
+	messages = p.withDefaultTopic(messages)
+	err := p.writer.WriteMessages(ctx, messages...)
+	if p.onDelivery != nil {
+		p.onDelivery(messages, err)
+	}
+	if err != nil {
+		log.Printf("Ошибка отправки сообщения в Kafka: %v", err)
+		return err
 	}
+	return nil
+}
 
-	// Используем defer для корректного завершения работы writer при завершении контекста
-	defer func() {
-		<-ctx.Done()
-		if err := producer.writer.Close(); err != nil {
-			log.Printf("Ошибка при закрытии Kafka producer: %v", err)
+// PublishAsync отправляет сообщения в фоне и вызывает callback (и OnDelivery) по завершении.
+// Незавершенные отправки дожидаются в Flush и Close.
+func (p *KafkaProducer) PublishAsync(callback DeliveryCallback, messages ...kafka.Message) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrProducerClosed
+	}
+
+	messages = p.withDefaultTopic(messages)
+	p.pending.Add(1)
+	go func() {
+		defer p.pending.Done()
+		// Контекст запроса не используется: доставка должна пережить завершение HTTP запроса
+		err := p.writer.WriteMessages(context.Background(), messages...)
+		if err != nil {
+			log.Printf("Ошибка асинхронной отправки сообщения в Kafka: %v", err)
+		}
+		if callback != nil {
+			callback(messages, err)
+		}
+		if p.onDelivery != nil {
+			p.onDelivery(messages, err)
 		}
-		log.Println("Kafka producer завершил работу.")
 	}()
+	return nil
+}
 
-	// Запуск основного цикла работы producer в горутине
+// Flush ждет завершения всех асинхронных отправок или отмены контекста
+func (p *KafkaProducer) Flush(ctx context.Context) error {
+	done := make(chan struct{})
 	go func() {
-		<-ctx.Done()
-		log.Println("Завершение работы Kafka producer по запросу контекста")
+		p.pending.Wait()
+		close(done)
 	}()
 
-	// Пока контекст не завершен, производим работу
-	<-ctx.Done() // Блокируем до завершения контекста
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close запрещает новые отправки, дожидается незавершенных и закрывает writer
+func (p *KafkaProducer) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	if err := p.Flush(ctx); err != nil {
+		log.Printf("Не все сообщения доставлены до закрытия Kafka producer: %v", err)
+	}
+	if err := p.writer.Close(); err != nil {
+		log.Printf("Ошибка при закрытии Kafka producer: %v", err)
+		return err
+	}
+	log.Println("Kafka producer завершил работу.")
 	return nil
 }
 
-func SendMessage(brokers string, topic string, message interface{}) error {
-	// Создать топик вручную
-	// kafka-topics.sh --bootstrap-server localhost:9092 --create --topic messages_topic --partitions 1 --replication-factor 1
-	// Проверить список топиков
-	// kafka-topics.sh --bootstrap-server localhost:9092 --list
-
-	// Создаем нового писателя Kafka напрямую
-	writer := kafka.Writer{
-		Addr:        kafka.TCP(brokers),  // Адреса брокеров Kafka
-		Topic:       topic,               // Название топика
-		Balancer:    &kafka.LeastBytes{}, // Балансировщик, используемый для выбора брокеров
-		MaxAttempts: 3,                   // Максимальное количество попыток отправки
-		Async:       false,               // Синхронный режим
-	}
-	// Используем defer для закрытия writer с обработкой ошибок
-	defer func() {
-		if err := writer.Close(); err != nil {
-			log.Printf("Ошибка при закрытии writer: %v", err)
+// withDefaultTopic проставляет топик по умолчанию сообщениям без явно указанного топика
+func (p *KafkaProducer) withDefaultTopic(messages []kafka.Message) []kafka.Message {
+	for i := range messages {
+		if messages[i].Topic == "" {
+			messages[i].Topic = p.topic
 		}
-	}()
+	}
+	return messages
+}
 
-	// Преобразуем сообщение в JSON
-	msg, err := json.Marshal(message)
+// NewJSONMessage сериализует значение в JSON и формирует сообщение Kafka
+func NewJSONMessage(key string, value interface{}) (kafka.Message, error) {
+	data, err := json.Marshal(value)
 	if err != nil {
 		log.Printf("Ошибка сериализации сообщения: %v", err)
-		return err
+		return kafka.Message{}, err
 	}
+	return kafka.Message{
+		Key:   []byte(key),
+		Value: data, // Содержимое сообщения в формате JSON
+	}, nil
+}
 
-	// Отправляем сообщение в Kafka
-	err = writer.WriteMessages(context.Background(), kafka.Message{
-		Key:   []byte("key"), // Ключ сообщения, можно сделать уникальным
-		Value: msg,           // Содержимое сообщения в формате JSON
-	})
-
-	// Проверяем на наличие ошибок при отправке сообщения
+// handleRecord передает запись обработчику ее типа. Записи, которые не удалось разобрать, и записи
+// неизвестного типа отправляются в DLQ, а записи с ошибкой обработки — в retry топик следующего уровня.
+// Записи с истекшим сроком действия пропускаются.
+// Возвращает ошибку, только если контекст завершен до того, как запись была обработана или перенаправлена.
+func handleRecord(ctx context.Context, handlers *HandlerRegistry, cfg ConsumerConfig, retries *RetryRouter, m kafka.Message) error {
+	env, err := DecodeEnvelope(m)
 	if err != nil {
-		log.Printf("Ошибка отправки сообщения в Kafka: %v", err)
-		return err
+		log.Printf("Ошибка при десериализации сообщения: %v", err)
+		return deadLetter(ctx, cfg, retries, m, DLQReasonDecode, err)
+	}
+	if env.Expired(time.Now()) {
+		handlers.expire(ctx, env)
+		return nil
 	}
 
-	log.Println("Сообщение успешно отправлено в Kafka")
+	if err := handlers.Handle(ctx, env); err != nil {
+		if errors.Is(err, ErrUnknownMessageType) {
+			log.Printf("Сообщение %s/%d/%d не обработано: %v", m.Topic, m.Partition, m.Offset, err)
+			return deadLetter(ctx, cfg, retries, m, DLQReasonUnknownType, err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err := publishWithRetry(ctx, cfg.RetryBackoff, func() error {
+			return retries.Retry(ctx, m, err)
+		})
+		if err == nil {
+			metrics.recordsRetried.Add(1)
+		}
+		return err
+	}
+	metrics.recordsProcessed.Add(1)
 	return nil
 }
 
-func StartKafkaConsumer(ctx context.Context, db *database.Database, brokers, topic string) {
-
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		//StartOffset: kafka.FirstOffset,
-		Brokers:  []string{brokers},
-		Topic:    topic,
-		GroupID:  "my_consumer_group",
-		MinBytes: 10e3,
-		MaxBytes: 10e6,
+// deadLetter отправляет запись в DLQ, повторяя публикацию до успеха или завершения контекста
+func deadLetter(ctx context.Context, cfg ConsumerConfig, retries *RetryRouter, m kafka.Message, reason string, cause error) error {
+	err := publishWithRetry(ctx, cfg.RetryBackoff, func() error {
+		return retries.DeadLetter(ctx, m, reason, cause)
 	})
-
-	defer func() {
-		if err := reader.Close(); err != nil {
-			log.Printf("Ошибка при закрытии reader: %v", err)
-		}
-	}()
-
-	// Запускаем цикл чтения сообщений
-	func() {
-		for {
-			select {
-			case <-ctx.Done():
-				// Если контекст завершен, выходим из цикла и завершаем работу консьюмера
-				log.Println("Завершение работы Kafka consumer по запросу контекста")
-				return
-			default:
-				// Чтение сообщения из Kafka
-				m, err := reader.ReadMessage(ctx)
-				if err != nil {
-					if errors.Is(err, context.Canceled) {
-						log.Println("Завершение работы Kafka consumer по запросу контекста")
-						return
-					}
-					log.Printf("Не удалось прочитать сообщение: %v", err)
-					// Задержка на 5 секунд перед следующей итерацией
-					time.Sleep(5 * time.Second)
-					continue
-				}
-
-				log.Printf("Сообщение успешно прочитано из Kafka: Partition: %d, Offset: %d, Key: %s, Value: %s",
-					m.Partition, m.Offset, string(m.Key), string(m.Value))
-
-				var msg models.Message
-				if err := json.Unmarshal(m.Value, &msg); err != nil {
-					log.Printf("Ошибка при десериализации сообщения: %v", err)
-					continue
-				}
-
-				// Проверяем, было ли сообщение уже обработано
-				//var existingMsg models.Message
-				//if err := db.First(&existingMsg, "id = ?", msg.ID).Error; err == nil {
-				//	log.Printf("Сообщение с ID %s уже обработано, пропускаем...", msg.ID)
-				//	continue // Пропускаем, если сообщение уже существует
-				//}
-
-				// Сохранение сообщения в базу данных
-				msg.Processed = true
-				if err := db.Save(&msg).Error; err != nil {
-					log.Printf("Ошибка при сохранении сообщения в базу данных: %v", err)
-					// Если ошибка, не фиксируем смещение и возвращаемся к следующему сообщению
-					continue
-				} else {
-					if err := reader.CommitMessages(ctx, m); err != nil {
-						log.Printf("Ошибка при коммите смещения: %v", err)
-						continue
-					}
-				}
-
-			}
-		}
-	}()
+	if err == nil {
+		metrics.recordsDeadLettered.Add(1)
+	}
+	return err
 }
 
-// ReadMessages2 читает сообщения из Kafka и обновляет статус сообщения в базе данных
-func ReadMessages2(ctx context.Context, db *database.Database, brokers, topic string) error {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  []string{brokers},
-		Topic:    topic,
-		MinBytes: 10e3,
-		MaxBytes: 10e6,
-	})
+// processMessage переводит сообщение в статус processing, а затем processed.
+// Повторно доставленное обработанное сообщение пропускается. Сообщение, опубликованное в обход API
+// и отсутствующее в базе данных, сохраняется сразу в статусе processed.
+func processMessage(ctx context.Context, db *database.Database, env Envelope) error {
+	msg := env.Message
 
-	defer func() {
-		if err := reader.Close(); err != nil {
-			log.Printf("Ошибка при закрытии reader: %v", err)
-		}
-	}()
+	err := ErrMessageNotFound
+	if msg.ID != 0 {
+		err = TransitionMessage(ctx, db.DB, msg.ID, models.MessageStatusProcessing, nil)
+	}
+	switch {
+	case errors.Is(err, ErrMessageNotFound):
+		now := time.Now()
+		msg.Status = models.MessageStatusProcessed
+		msg.Attempts = 1
+		msg.ProcessedAt = &now
+		return db.WithContext(ctx).Create(&msg).Error
+	case errors.Is(err, ErrInvalidTransition):
+		log.Printf("Сообщение %d пропущено: %v", msg.ID, err)
+		return nil
+	case err != nil:
+		return err
+	}
 
+	return TransitionMessage(ctx, db.DB, msg.ID, models.MessageStatusProcessed, nil)
+}
+
+// publishWithRetry повторяет публикацию до успеха или завершения контекста,
+// чтобы не зафиксировать смещение записи, которая никуда не перенаправлена
+func publishWithRetry(ctx context.Context, backoff time.Duration, publish func() error) error {
+	if backoff <= 0 {
+		backoff = time.Second
+	}
 	for {
-		// Читаем сообщение из Kafka с учетом контекста
-		m, err := reader.ReadMessage(ctx)
-		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				log.Println("Завершение работы Kafka consumer по запросу")
-				return nil
-			}
-			log.Printf("Не удалось прочитать сообщение: %v", err)
-			return err
+		err := publish()
+		if err == nil {
+			return nil
 		}
-
-		// Декодируем сообщение в структуру модели Message
-		var msg models.Message
-		if err := json.Unmarshal(m.Value, &msg); err != nil {
-			log.Printf("Ошибка при десериализации сообщения: %v", err)
-			continue
+		log.Printf("Ошибка перенаправления сообщения: %v", err)
+		if err := sleepContext(ctx, backoff); err != nil {
+			return err
 		}
+	}
+}
 
-		// Логируем полученное сообщение
-		log.Printf("Получено сообщение: %s", msg.Content)
-
-		// Обновляем статус сообщения в базе данных как "обработанное"
-		msg.Processed = true
-		if err := db.Save(&msg).Error; err != nil {
-			log.Printf("Ошибка при сохранении сообщения в базу данных: %v", err)
-			continue
-		}
+// sleepContext ждет указанное время или завершения контекста
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
 }
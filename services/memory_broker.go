@@ -0,0 +1,351 @@
+// Package services memory_broker.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoConsumerGroup возвращается при фиксации смещений читателем без группы
+var ErrNoConsumerGroup = errors.New("фиксация смещений недоступна без группы потребителей")
+
+// MemoryBrokerConfig описывает параметры брокера в памяти
+type MemoryBrokerConfig struct {
+	Partitions int // Количество партиций топиков, создаваемых при первой публикации или подписке
+}
+
+// MemoryBroker брокер сообщений в памяти процесса с семантикой Kafka: топики из партиций,
+// смещения, группы потребителей с распределением партиций между участниками и фиксацией смещений.
+// Записи не сохраняются между перезапусками; предназначен для локального запуска и тестов без Kafka.
+type MemoryBroker struct {
+	mu     sync.Mutex
+	cfg    MemoryBrokerConfig
+	topics map[string][][]kafka.Message // Топик -> партиции -> записи
+	groups map[string]*memoryGroup      // Ключ: group/topic
+	notify chan struct{}                // Закрывается при появлении записей или перебалансировке
+}
+
+// memoryGroup состояние группы потребителей одного топика
+type memoryGroup struct {
+	topic      string
+	committed  map[int]int64 // Партиция -> смещение следующей записи
+	members    []*memoryReader
+	generation int
+}
+
+// NewMemoryBroker создает брокер в памяти
+func NewMemoryBroker(cfg MemoryBrokerConfig) *MemoryBroker {
+	if cfg.Partitions <= 0 {
+		cfg.Partitions = 1
+	}
+	return &MemoryBroker{
+		cfg:    cfg,
+		topics: make(map[string][][]kafka.Message),
+		groups: make(map[string]*memoryGroup),
+		notify: make(chan struct{}),
+	}
+}
+
+// CreateTopic создает топик или увеличивает количество его партиций
+func (b *MemoryBroker) CreateTopic(name string, partitions int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	current := b.topicLocked(name)
+	if partitions <= len(current) {
+		return
+	}
+	for len(current) < partitions {
+		current = append(current, nil)
+	}
+	b.topics[name] = current
+	// Участники групп топика получают новые партиции при следующем чтении
+	for _, g := range b.groups {
+		if g.topic == name {
+			g.generation++
+		}
+	}
+	b.broadcastLocked()
+}
+
+// Messages возвращает копию записей топика по всем партициям в порядке партиций и смещений
+func (b *MemoryBroker) Messages(topic string) []kafka.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var result []kafka.Message
+	for _, partition := range b.topics[topic] {
+		result = append(result, partition...)
+	}
+	return result
+}
+
+// Committed возвращает зафиксированные смещения группы по партициям топика
+func (b *MemoryBroker) Committed(groupID, topic string) map[int]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := make(map[int]int64)
+	if g, ok := b.groups[groupID+"/"+topic]; ok {
+		for partition, offset := range g.committed {
+			result[partition] = offset
+		}
+	}
+	return result
+}
+
+// Publisher создает публикатора. Топик по умолчанию и балансировщик берутся из cfg.
+func (b *MemoryBroker) Publisher(cfg ProducerConfig) Publisher {
+	if cfg.Balancer == nil {
+		cfg.Balancer = &kafka.Hash{}
+	}
+	return &memoryPublisher{broker: b, topic: cfg.Topic, balancer: cfg.Balancer}
+}
+
+// Subscriber создает подписчика. Для групп без зафиксированных смещений используется cfg.StartOffset.
+func (b *MemoryBroker) Subscriber(cfg ConsumerConfig) Subscriber {
+	return &memorySubscriber{broker: b, startLast: cfg.StartOffset == StartOffsetLast}
+}
+
+// topicLocked возвращает партиции топика, создавая топик при необходимости
+func (b *MemoryBroker) topicLocked(name string) [][]kafka.Message {
+	partitions, ok := b.topics[name]
+	if !ok {
+		partitions = make([][]kafka.Message, b.cfg.Partitions)
+		b.topics[name] = partitions
+	}
+	return partitions
+}
+
+// broadcastLocked будит читателей, ожидающих записи
+func (b *MemoryBroker) broadcastLocked() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// memoryPublisher публикует записи в MemoryBroker
+type memoryPublisher struct {
+	broker   *MemoryBroker
+	topic    string
+	balancer kafka.Balancer
+	closed   bool
+}
+
+// PublishSync добавляет записи в партиции, выбранные балансировщиком
+func (p *memoryPublisher) PublishSync(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b := p.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p.closed {
+		return ErrProducerClosed
+	}
+
+	now := time.Now()
+	for _, m := range msgs {
+		if m.Topic == "" {
+			m.Topic = p.topic
+		}
+		partitions := b.topicLocked(m.Topic)
+		ids := make([]int, len(partitions))
+		for i := range ids {
+			ids[i] = i
+		}
+		m.Partition = p.balancer.Balance(m, ids...)
+		m.Offset = int64(len(partitions[m.Partition]))
+		if m.Time.IsZero() {
+			m.Time = now
+		}
+		partitions[m.Partition] = append(partitions[m.Partition], m)
+	}
+	b.broadcastLocked()
+	return nil
+}
+
+// Close запрещает новые публикации
+func (p *memoryPublisher) Close(context.Context) error {
+	p.broker.mu.Lock()
+	defer p.broker.mu.Unlock()
+	p.closed = true
+	return nil
+}
+
+// memorySubscriber создает читателей MemoryBroker
+type memorySubscriber struct {
+	broker    *MemoryBroker
+	startLast bool
+}
+
+// Subscribe создает читателя топика. Читатели одной группы делят партиции топика.
+func (s *memorySubscriber) Subscribe(topic, groupID string) MessageReader {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r := &memoryReader{broker: b, topic: topic, groupID: groupID, startLast: s.startLast, generation: -1}
+	b.topicLocked(topic)
+	if groupID != "" {
+		key := groupID + "/" + topic
+		g, ok := b.groups[key]
+		if !ok {
+			g = &memoryGroup{topic: topic, committed: make(map[int]int64)}
+			b.groups[key] = g
+		}
+		g.members = append(g.members, r)
+		g.generation++
+		r.group = g
+		b.broadcastLocked()
+	}
+	return r
+}
+
+// memoryReader читатель топика MemoryBroker
+type memoryReader struct {
+	broker    *MemoryBroker
+	topic     string
+	groupID   string
+	group     *memoryGroup
+	startLast bool
+
+	generation int
+	assigned   []int
+	positions  map[int]int64
+	next       int // Партиция, с которой начинается следующий поиск записи
+	closed     bool
+}
+
+// FetchMessage возвращает следующую запись назначенных партиций, ожидая ее появления
+func (r *memoryReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	b := r.broker
+	for {
+		b.mu.Lock()
+		if r.closed {
+			b.mu.Unlock()
+			return kafka.Message{}, io.EOF
+		}
+		partitions := b.topicLocked(r.topic)
+		r.assignLocked(partitions)
+
+		for i := range r.assigned {
+			index := (r.next + i) % len(r.assigned)
+			partition := r.assigned[index]
+			if position := r.positions[partition]; position < int64(len(partitions[partition])) {
+				m := partitions[partition][position]
+				r.positions[partition] = position + 1
+				r.next = (index + 1) % len(r.assigned)
+				b.mu.Unlock()
+				return m, nil
+			}
+		}
+		notify := b.notify
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// assignLocked пересчитывает назначенные партиции после перебалансировки группы. Позиции чтения
+// новых партиций начинаются с зафиксированных смещений группы.
+func (r *memoryReader) assignLocked(partitions [][]kafka.Message) {
+	if r.group == nil {
+		// Без группы читаются все партиции, включая добавленные
+		for p := len(r.assigned); p < len(partitions); p++ {
+			if r.positions == nil {
+				r.positions = make(map[int]int64)
+			}
+			r.assigned = append(r.assigned, p)
+			r.positions[p] = r.startPosition(partitions[p])
+		}
+		return
+	}
+	if r.generation == r.group.generation {
+		return
+	}
+	r.generation = r.group.generation
+
+	// Партиции распределяются между участниками по кругу в порядке подключения
+	member := 0
+	for i, m := range r.group.members {
+		if m == r {
+			member = i
+		}
+	}
+	r.assigned = r.assigned[:0]
+	r.positions = make(map[int]int64)
+	for p := range partitions {
+		if p%len(r.group.members) != member {
+			continue
+		}
+		r.assigned = append(r.assigned, p)
+		if offset, ok := r.group.committed[p]; ok {
+			r.positions[p] = offset
+		} else {
+			r.positions[p] = r.startPosition(partitions[p])
+		}
+	}
+	sort.Ints(r.assigned)
+	r.next = 0
+}
+
+// startPosition возвращает начальную позицию партиции без зафиксированного смещения
+func (r *memoryReader) startPosition(partition []kafka.Message) int64 {
+	if r.startLast {
+		return int64(len(partition))
+	}
+	return 0
+}
+
+// CommitMessages фиксирует смещения записей за группой. Смещения не уменьшаются.
+func (r *memoryReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	if r.group == nil {
+		return ErrNoConsumerGroup
+	}
+	r.broker.mu.Lock()
+	defer r.broker.mu.Unlock()
+	for _, m := range msgs {
+		if next := m.Offset + 1; next > r.group.committed[m.Partition] {
+			r.group.committed[m.Partition] = next
+		}
+	}
+	return nil
+}
+
+// Close покидает группу; партиции читателя распределяются между оставшимися участниками
+func (r *memoryReader) Close() error {
+	b := r.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.group != nil {
+		for i, m := range r.group.members {
+			if m == r {
+				r.group.members = append(r.group.members[:i], r.group.members[i+1:]...)
+				break
+			}
+		}
+		r.group.generation++
+	}
+	b.broadcastLocked()
+	return nil
+}
+
+// Topic возвращает читаемый топик
+func (r *memoryReader) Topic() string {
+	return r.topic
+}
+
+// GroupID возвращает группу потребителей
+func (r *memoryReader) GroupID() string {
+	return r.groupID
+}
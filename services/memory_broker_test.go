@@ -0,0 +1,303 @@
+// Package services memory_broker_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/config"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fetchN читает n записей, завершая тест, если они не появились за секунду
+func fetchN(t *testing.T, r MessageReader, n int) []kafka.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msgs := make([]kafka.Message, 0, n)
+	for len(msgs) < n {
+		m, err := r.FetchMessage(ctx)
+		if err != nil {
+			t.Fatalf("FetchMessage после %d из %d записей: %v", len(msgs), n, err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+// expectNoMessage проверяет, что у читателя нет новых записей
+func expectNoMessage(t *testing.T, r MessageReader) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if m, err := r.FetchMessage(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ожидалось отсутствие записей, получено %s/%d/%d (err=%v)", m.Topic, m.Partition, m.Offset, err)
+	}
+}
+
+// waitFor ждет выполнения условия не дольше timeout
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("не дождались: %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMemoryBrokerPublishConsumeCommit(t *testing.T) {
+	broker := NewMemoryBroker(MemoryBrokerConfig{Partitions: 3})
+	publisher := broker.Publisher(ProducerConfig{Topic: "orders"})
+	subscriber := broker.Subscriber(ConsumerConfig{})
+
+	var msgs []kafka.Message
+	for i := 0; i < 9; i++ {
+		msgs = append(msgs, kafka.Message{Key: []byte("key-" + strconv.Itoa(i%3)), Value: []byte(strconv.Itoa(i))})
+	}
+	if err := publisher.PublishSync(context.Background(), msgs...); err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	reader := subscriber.Subscribe("orders", "group")
+	fetched := fetchN(t, reader, len(msgs))
+
+	// Записи одного ключа попадают в одну партицию и читаются по порядку
+	partitions := make(map[string]int)
+	last := make(map[string]int)
+	for _, m := range fetched {
+		key := string(m.Key)
+		if p, ok := partitions[key]; ok && p != m.Partition {
+			t.Errorf("ключ %s прочитан из партиций %d и %d", key, p, m.Partition)
+		}
+		partitions[key] = m.Partition
+		value, _ := strconv.Atoi(string(m.Value))
+		if prev, ok := last[key]; ok && value < prev {
+			t.Errorf("ключ %s: запись %d прочитана после %d", key, value, prev)
+		}
+		last[key] = value
+	}
+
+	if err := reader.CommitMessages(context.Background(), fetched...); err != nil {
+		t.Fatalf("CommitMessages: %v", err)
+	}
+	var committed int64
+	for _, offset := range broker.Committed("group", "orders") {
+		committed += offset
+	}
+	if committed != int64(len(msgs)) {
+		t.Errorf("зафиксировано записей: %d, ожидалось %d", committed, len(msgs))
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Новый участник группы продолжает с зафиксированных смещений
+	next := subscriber.Subscribe("orders", "group")
+	defer next.Close()
+	expectNoMessage(t, next)
+
+	// Читатель без группы читает все записи и не может фиксировать смещения
+	standalone := subscriber.Subscribe("orders", "")
+	defer standalone.Close()
+	fetchN(t, standalone, len(msgs))
+	if err := standalone.CommitMessages(context.Background(), msgs[0]); !errors.Is(err, ErrNoConsumerGroup) {
+		t.Errorf("CommitMessages без группы: %v, ожидалось ErrNoConsumerGroup", err)
+	}
+}
+
+func TestMemoryBrokerRedeliversUncommitted(t *testing.T) {
+	broker := NewMemoryBroker(MemoryBrokerConfig{Partitions: 1})
+	publisher := broker.Publisher(ProducerConfig{Topic: "orders"})
+	subscriber := broker.Subscriber(ConsumerConfig{})
+	if err := publisher.PublishSync(context.Background(),
+		kafka.Message{Value: []byte("0")}, kafka.Message{Value: []byte("1")}, kafka.Message{Value: []byte("2")},
+	); err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	first := subscriber.Subscribe("orders", "group")
+	fetched := fetchN(t, first, 3)
+	if err := first.CommitMessages(context.Background(), fetched[0]); err != nil {
+		t.Fatalf("CommitMessages: %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Незафиксированные записи получает следующий участник группы
+	second := subscriber.Subscribe("orders", "group")
+	defer second.Close()
+	redelivered := fetchN(t, second, 2)
+	if redelivered[0].Offset != 1 || redelivered[1].Offset != 2 {
+		t.Errorf("повторно доставлены смещения %d и %d, ожидались 1 и 2", redelivered[0].Offset, redelivered[1].Offset)
+	}
+
+	// Другая группа читает топик независимо
+	other := subscriber.Subscribe("orders", "other")
+	defer other.Close()
+	fetchN(t, other, 3)
+}
+
+func TestMemoryBrokerGroupSharesPartitions(t *testing.T) {
+	broker := NewMemoryBroker(MemoryBrokerConfig{Partitions: 2})
+	subscriber := broker.Subscriber(ConsumerConfig{})
+	a := subscriber.Subscribe("orders", "group")
+	defer a.Close()
+	b := subscriber.Subscribe("orders", "group")
+
+	publisher := broker.Publisher(ProducerConfig{Topic: "orders", Balancer: &kafka.RoundRobin{}})
+	if err := publisher.PublishSync(context.Background(), kafka.Message{Value: []byte("0")}, kafka.Message{Value: []byte("1")}); err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	ma, mb := fetchN(t, a, 1)[0], fetchN(t, b, 1)[0]
+	if ma.Partition == mb.Partition {
+		t.Fatalf("оба участника получили партицию %d", ma.Partition)
+	}
+	expectNoMessage(t, a)
+
+	// После ухода участника его незафиксированная партиция переходит к оставшемуся
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if m := fetchN(t, a, 2); m[0].Partition == m[1].Partition {
+		t.Errorf("после перебалансировки прочитана только партиция %d", m[0].Partition)
+	}
+}
+
+func TestConsumerRoutesFailuresThroughRetryToDLQ(t *testing.T) {
+	db := offlineDatabase(t)
+	broker := NewMemoryBroker(MemoryBrokerConfig{Partitions: 1})
+	publisher := broker.Publisher(ProducerConfig{Topic: "orders"})
+
+	cfg := ConsumerConfig{Topic: "orders", GroupID: "group", RetryBackoff: 10 * time.Millisecond}
+	dlq := NewDeadLetterQueue(db, publisher, "orders.dlq")
+	retries := NewRetryRouter(publisher, dlq, config.RetryPolicy{Tiers: []time.Duration{50 * time.Millisecond}, MaxAttempts: 2}, nil)
+	retries.PriorityTopics(cfg.Topic)
+
+	var calls atomic.Int32
+	handlers := NewHandlerRegistry(db, cfg)
+	handlers.Register("test.fail", HandlerFunc(func(context.Context, Envelope) error {
+		calls.Add(1)
+		return errors.New("обработка не удалась")
+	}))
+	handlers.Register("test.ok", HandlerFunc(func(context.Context, Envelope) error {
+		return nil
+	}))
+
+	consumer := NewConsumer(db, broker.Subscriber(cfg), handlers, cfg, retries)
+	if err := consumer.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer consumer.Stop(context.Background())
+
+	err := publisher.PublishSync(context.Background(),
+		kafka.Message{Key: []byte("b"), Value: []byte(`{}`), Headers: HeadersToKafka(map[string]string{HeaderMessageType: "test.ok"})},
+		kafka.Message{Key: []byte("c"), Value: []byte(`not json`)},
+		kafka.Message{Key: []byte("a"), Value: []byte(`{}`), Headers: HeadersToKafka(map[string]string{HeaderMessageType: "test.fail"})},
+	)
+	if err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	waitFor(t, 5*time.Second, "две записи в DLQ", func() bool { return len(broker.Messages("orders.dlq")) == 2 })
+	waitFor(t, 5*time.Second, "фиксация retry топика", func() bool {
+		return broker.Committed("group.retry.50ms", "orders.retry.50ms")[0] == 1
+	})
+	if err := consumer.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// Ошибка обработки: retry топик, затем DLQ после исчерпания попыток
+	if n := calls.Load(); n != 2 {
+		t.Errorf("вызовов обработчика: %d, ожидалось 2", n)
+	}
+	retried := broker.Messages("orders.retry.50ms")
+	if len(retried) != 1 {
+		t.Fatalf("записей в retry топике: %d, ожидалась 1", len(retried))
+	}
+	if headers := HeadersFromKafka(retried[0].Headers); headers[HeaderRetryAttempt] != "1" || headers[HeaderRetryOriginalTopic] != "orders" ||
+		headers[HeaderRetryOriginalOffset] != "2" {
+		t.Errorf("заголовки retry записи: %v", headers)
+	}
+
+	reasons := make(map[string]map[string]string)
+	for _, m := range broker.Messages("orders.dlq") {
+		headers := HeadersFromKafka(m.Headers)
+		reasons[string(m.Key)] = headers
+	}
+	// Исходные топик, партиция и смещение берутся из основного топика, а не из retry топика
+	if headers := reasons["a"]; headers[HeaderDLQReason] != DLQReasonProcessing || headers[HeaderDLQAttempts] != "2" ||
+		headers[HeaderDLQOriginalTopic] != "orders" || headers[HeaderDLQOriginalPartition] != "0" || headers[HeaderDLQOriginalOffset] != "2" {
+		t.Errorf("заголовки DLQ записи после повторов: %v", headers)
+	}
+	if headers := reasons["c"]; headers[HeaderDLQReason] != DLQReasonDecode || headers[HeaderDLQOriginalTopic] != "orders" ||
+		headers[HeaderDLQOriginalOffset] != "1" {
+		t.Errorf("заголовки DLQ записи, которую не удалось разобрать: %v", headers)
+	}
+	if _, ok := reasons["b"]; ok {
+		t.Error("успешно обработанная запись попала в DLQ")
+	}
+
+	// Смещения всех записей основного топика зафиксированы, включая перенаправленные
+	if offset := broker.Committed("group", "orders")[0]; offset != 3 {
+		t.Errorf("зафиксированное смещение основного топика: %d, ожидалось 3", offset)
+	}
+}
+
+func TestConsumerRetriesPriorityRecordsThroughTheirRetryTopics(t *testing.T) {
+	db := offlineDatabase(t)
+	broker := NewMemoryBroker(MemoryBrokerConfig{Partitions: 1})
+	publisher := broker.Publisher(ProducerConfig{Topic: "orders"})
+
+	cfg := ConsumerConfig{Topic: "orders", GroupID: "group", RetryBackoff: 10 * time.Millisecond}
+	dlq := NewDeadLetterQueue(db, publisher, "orders.dlq")
+	retries := NewRetryRouter(publisher, dlq, config.RetryPolicy{Tiers: []time.Duration{50 * time.Millisecond}, MaxAttempts: 2}, nil)
+	retries.PriorityTopics(cfg.Topic)
+
+	handlers := NewHandlerRegistry(db, cfg)
+	handlers.Register("test.fail", HandlerFunc(func(context.Context, Envelope) error {
+		return errors.New("обработка не удалась")
+	}))
+	consumer := NewConsumer(db, broker.Subscriber(cfg), handlers, cfg, retries)
+	if err := consumer.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer consumer.Stop(context.Background())
+
+	err := publisher.PublishSync(context.Background(), kafka.Message{
+		Topic:   "orders.high",
+		Key:     []byte("a"),
+		Value:   []byte(`{}`),
+		Headers: HeadersToKafka(map[string]string{HeaderMessageType: "test.fail", HeaderPriority: "high"}),
+	})
+	if err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	waitFor(t, 5*time.Second, "запись в DLQ", func() bool { return len(broker.Messages("orders.dlq")) == 1 })
+	waitFor(t, 5*time.Second, "фиксация retry топика high", func() bool {
+		return broker.Committed("group.high.retry.50ms", "orders.high.retry.50ms")[0] == 1
+	})
+
+	// Запись высокого приоритета повторяется через retry топик своего приоритета по политике основного топика
+	if n := len(broker.Messages("orders.retry.50ms")); n != 0 {
+		t.Errorf("записей в retry топике основного топика: %d, ожидалось 0", n)
+	}
+	retried := broker.Messages("orders.high.retry.50ms")
+	if len(retried) != 1 {
+		t.Fatalf("записей в retry топике high: %d, ожидалась 1", len(retried))
+	}
+	if headers := HeadersFromKafka(retried[0].Headers); headers[HeaderPriority] != "high" || headers[HeaderRetryOriginalTopic] != "orders.high" {
+		t.Errorf("заголовки retry записи: %v", headers)
+	}
+	if headers := HeadersFromKafka(broker.Messages("orders.dlq")[0].Headers); headers[HeaderDLQOriginalTopic] != "orders.high" ||
+		headers[HeaderDLQAttempts] != "2" {
+		t.Errorf("заголовки DLQ записи: %v", headers)
+	}
+}
@@ -0,0 +1,367 @@
+// Package services messages.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"strconv"
+	"time"
+)
+
+// batchInsertSize количество строк в одном INSERT при пакетной вставке
+const batchInsertSize = 500
+
+// MessageService создает сообщения и события для их публикации в одной транзакции
+type MessageService struct {
+	db  *database.Database
+	cfg MessageServiceConfig
+}
+
+// MessageServiceConfig описывает параметры публикации сообщений
+type MessageServiceConfig struct {
+	Topic         string       // Основной топик; сообщения приоритетов high и low публикуются в топики <Topic>.<priority>
+	Keys          KeyStrategy  // Стратегия формирования ключа сообщения
+	SourceService string       // Имя сервиса для заголовка source-service
+	Relay         *OutboxRelay // Необязательный relay для немедленной публикации пакетов
+	MaxBatchItems int          // Максимальное количество элементов в пакете
+}
+
+// NewMessageService создает сервис сообщений
+func NewMessageService(db *database.Database, cfg MessageServiceConfig) *MessageService {
+	if cfg.Keys == nil {
+		cfg.Keys = KeyStrategyFunc(messageIDKey)
+	}
+	if cfg.MaxBatchItems <= 0 {
+		cfg.MaxBatchItems = 1000
+	}
+	return &MessageService{db: db, cfg: cfg}
+}
+
+// Create сохраняет сообщение и запись outbox в одной транзакции.
+// Публикацию в Kafka выполняет OutboxRelay, поэтому недоступность брокера не приводит к ошибке.
+// ID корреляции берется из контекста (см. WithCorrelationID).
+func (s *MessageService) Create(ctx context.Context, request models.CreateMessageRequest) (models.Message, error) {
+	var msg models.Message
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		msg, err = s.create(ctx, tx, request)
+		return err
+	})
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	return msg, nil
+}
+
+// create сохраняет сообщение и запись outbox в транзакции tx
+func (s *MessageService) create(ctx context.Context, tx *gorm.DB, request models.CreateMessageRequest) (models.Message, error) {
+	msg := s.newMessage(ctx, request)
+	if err := tx.Create(&msg).Error; err != nil {
+		return models.Message{}, err
+	}
+
+	record, err := s.newOutboxRecord(msg)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return models.Message{}, err
+	}
+	return msg, nil
+}
+
+// ErrInvalidMessage возвращается при нарушении правил валидации сообщения
+var ErrInvalidMessage = errors.New("content is required")
+
+// ErrBatchTooLarge возвращается, если пакет превышает допустимый размер
+var ErrBatchTooLarge = errors.New("batch is too large")
+
+// ErrInvalidSchedule возвращается при некорректном времени отложенной доставки
+var ErrInvalidSchedule = errors.New("invalid delivery schedule")
+
+// ErrInvalidExpiration возвращается при некорректном сроке действия сообщения
+var ErrInvalidExpiration = errors.New("invalid message expiration")
+
+// ValidateRequest проверяет запрос на создание сообщения
+func ValidateRequest(request models.CreateMessageRequest) error {
+	if len(request.Content) == 0 {
+		return ErrInvalidMessage
+	}
+	if err := validatePriority(request.Priority); err != nil {
+		return err
+	}
+	now := time.Now()
+	deliverAt, err := deliveryTime(request.DeliverAt, request.Delay, now)
+	if err != nil {
+		return err
+	}
+	expiresAt, err := expirationTime(request.ExpiresAt, request.TTL, now)
+	if err != nil {
+		return err
+	}
+	if expiresAt != nil && deliverAt != nil && !expiresAt.After(*deliverAt) {
+		return fmt.Errorf("%w: message expires before delivery", ErrInvalidExpiration)
+	}
+	return nil
+}
+
+// CreateBatch валидирует элементы пакета, сохраняет корректные сообщения и записи outbox
+// одной транзакцией (bulk insert) и сразу публикует их в Kafka одним вызовом WriteMessages.
+// Некорректные элементы не прерывают пакет и возвращаются с ошибкой в результатах.
+func (s *MessageService) CreateBatch(ctx context.Context, requests []models.CreateMessageRequest) (models.BatchCreateResponse, error) {
+	if len(requests) > s.cfg.MaxBatchItems {
+		return models.BatchCreateResponse{}, fmt.Errorf("%w: %d items, max %d", ErrBatchTooLarge, len(requests), s.cfg.MaxBatchItems)
+	}
+
+	response := models.BatchCreateResponse{
+		Total: len(requests),
+		Items: make([]models.BatchItemResult, len(requests)),
+	}
+
+	var msgs []models.Message
+	var indexes []int
+	for i, request := range requests {
+		response.Items[i] = models.BatchItemResult{Index: i}
+		if err := ValidateRequest(request); err != nil {
+			response.Items[i].Status = models.BatchItemFailed
+			response.Items[i].Error = err.Error()
+			response.Failed++
+			continue
+		}
+		msgs = append(msgs, s.newMessage(ctx, request))
+		indexes = append(indexes, i)
+	}
+	if len(msgs) == 0 {
+		return response, nil
+	}
+
+	var recordIDs []uint
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&msgs, batchInsertSize).Error; err != nil {
+			return err
+		}
+
+		records := make([]models.OutboxRecord, len(msgs))
+		for i, msg := range msgs {
+			record, err := s.newOutboxRecord(msg)
+			if err != nil {
+				return err
+			}
+			records[i] = record
+		}
+		if err := tx.CreateInBatches(&records, batchInsertSize).Error; err != nil {
+			return err
+		}
+
+		recordIDs = make([]uint, len(records))
+		for i, record := range records {
+			recordIDs[i] = record.ID
+		}
+		return nil
+	})
+	if err != nil {
+		return models.BatchCreateResponse{}, err
+	}
+
+	for i, msg := range msgs {
+		item := &response.Items[indexes[i]]
+		item.ID = msg.ID
+		item.Status = models.BatchItemCreated
+		response.Created++
+	}
+
+	// Немедленная публикация пакета; при ошибке записи останутся в outbox и будут опубликованы позже
+	if s.cfg.Relay != nil {
+		published, err := s.cfg.Relay.PublishRecords(ctx, recordIDs)
+		if err != nil {
+			log.Printf("Пакет сообщений будет опубликован outbox relay позже: %v", err)
+		}
+		response.Published = published
+	}
+
+	return response, nil
+}
+
+// newMessage создает модель сообщения из запроса с метаданными из контекста.
+// Сообщение с временем доставки в будущем создается в статусе scheduled.
+func (s *MessageService) newMessage(ctx context.Context, request models.CreateMessageRequest) models.Message {
+	status := models.MessageStatusPending // Событие публикуется outbox relay
+	now := time.Now()
+	deliverAt, _ := deliveryTime(request.DeliverAt, request.Delay, now)
+	expiresAt, _ := expirationTime(request.ExpiresAt, request.TTL, now)
+	if deliverAt != nil {
+		status = models.MessageStatusScheduled
+	}
+	priority := request.Priority
+	if priority == "" {
+		priority = models.PriorityNormal
+	}
+	return models.Message{
+		Content:       request.Content,
+		Status:        status,
+		DeliverAt:     deliverAt,
+		ExpiresAt:     expiresAt,
+		Priority:      priority,
+		PartitionKey:  request.PartitionKey,
+		TenantID:      request.TenantID,
+		CorrelationID: CorrelationIDFromContext(ctx),
+		SourceService: s.cfg.SourceService,
+		SchemaVersion: SchemaVersion,
+	}
+}
+
+// newOutboxRecord формирует запись outbox для публикации сообщения
+func (s *MessageService) newOutboxRecord(msg models.Message) (models.OutboxRecord, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return models.OutboxRecord{}, err
+	}
+
+	headers := models.Headers{
+		HeaderMessageID:     strconv.FormatUint(uint64(msg.ID), 10),
+		HeaderMessageType:   MessageTypeDefault,
+		HeaderContentType:   ContentTypeJSON,
+		HeaderSchemaVersion: msg.SchemaVersion,
+		HeaderSourceService: msg.SourceService,
+		HeaderPriority:      msg.Priority,
+	}
+	if msg.CorrelationID != "" {
+		headers[HeaderCorrelationID] = msg.CorrelationID
+	}
+	if msg.ExpiresAt != nil {
+		headers[HeaderExpiresAt] = msg.ExpiresAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	// Отложенное сообщение публикуется relay, когда наступает время доставки
+	nextAttemptAt := time.Now()
+	if msg.DeliverAt != nil {
+		nextAttemptAt = *msg.DeliverAt
+	}
+	return models.OutboxRecord{
+		MessageID:     msg.ID,
+		Topic:         PriorityTopic(s.cfg.Topic, msg.Priority),
+		Key:           s.cfg.Keys.Key(msg),
+		Payload:       payload,
+		Headers:       headers,
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: nextAttemptAt,
+	}, nil
+}
+
+// Cancel отменяет доставку отложенного сообщения. Возвращает ErrMessageNotFound или ErrInvalidTransition,
+// если сообщение уже опубликовано или отменено.
+func (s *MessageService) Cancel(ctx context.Context, id uint) (models.Message, error) {
+	return s.updateScheduled(ctx, id, func(tx *gorm.DB, record *models.OutboxRecord) error {
+		if err := TransitionMessage(ctx, tx, id, models.MessageStatusCancelled, nil); err != nil {
+			return err
+		}
+		return tx.Model(record).Update("status", models.OutboxStatusCancelled).Error
+	})
+}
+
+// Reschedule переносит доставку отложенного сообщения. Время в прошлом означает доставку при ближайшем
+// опросе outbox. Доставка не переносится на время истечения срока действия сообщения или позже.
+// Возвращает ErrInvalidSchedule, ErrInvalidExpiration, ErrMessageNotFound или ErrInvalidTransition.
+func (s *MessageService) Reschedule(ctx context.Context, id uint, request models.ScheduleMessageRequest) (models.Message, error) {
+	now := time.Now()
+	deliverAt, err := deliveryTime(request.DeliverAt, request.Delay, now)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if deliverAt == nil {
+		deliverAt = &now
+	}
+	return s.updateScheduled(ctx, id, func(tx *gorm.DB, record *models.OutboxRecord) error {
+		var msg models.Message
+		if err := tx.Select("id", "expires_at").First(&msg, id).Error; err != nil {
+			return err
+		}
+		if msg.ExpiresAt != nil && !msg.ExpiresAt.After(*deliverAt) {
+			return fmt.Errorf("%w: message expires before delivery", ErrInvalidExpiration)
+		}
+
+		result := tx.Model(&models.Message{}).
+			Where("id = ? AND status = ?", id, models.MessageStatusScheduled).
+			Update("deliver_at", *deliverAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return transitionError(ctx, tx, id, models.MessageStatusScheduled)
+		}
+		return tx.Model(record).Update("next_attempt_at", *deliverAt).Error
+	})
+}
+
+// updateScheduled блокирует ожидающую запись outbox отложенного сообщения и выполняет update в транзакции.
+// Relay пропускает заблокированную запись, а если публикует ее в этот момент, блокировка дожидается
+// завершения публикации, после чего статус сообщения уже не scheduled.
+func (s *MessageService) updateScheduled(ctx context.Context, id uint, update func(tx *gorm.DB, record *models.OutboxRecord) error) (models.Message, error) {
+	var msg models.Message
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var record models.OutboxRecord
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("message_id = ? AND status = ?", id, models.OutboxStatusPending).
+			Order("id").
+			First(&record).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return transitionError(ctx, tx, id, models.MessageStatusScheduled)
+		}
+		if err != nil {
+			return err
+		}
+		if err := update(tx, &record); err != nil {
+			return err
+		}
+		return tx.First(&msg, id).Error
+	})
+	return msg, err
+}
+
+// deliveryTime возвращает время отложенной доставки из deliver_at или delay относительно now.
+// Для немедленной доставки, в том числе при времени в прошлом, возвращает nil.
+func deliveryTime(deliverAt *time.Time, delay string, now time.Time) (*time.Time, error) {
+	if deliverAt != nil && delay != "" {
+		return nil, fmt.Errorf("%w: deliver_at and delay are mutually exclusive", ErrInvalidSchedule)
+	}
+	if delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%w: invalid delay %q", ErrInvalidSchedule, delay)
+		}
+		at := now.Add(d)
+		deliverAt = &at
+	}
+	if deliverAt == nil || !deliverAt.After(now) {
+		return nil, nil
+	}
+	return deliverAt, nil
+}
+
+// expirationTime возвращает срок действия сообщения из expires_at или ttl относительно now.
+// Без срока действия возвращает nil; срок в прошлом считается ошибкой.
+func expirationTime(expiresAt *time.Time, ttl string, now time.Time) (*time.Time, error) {
+	if expiresAt != nil && ttl != "" {
+		return nil, fmt.Errorf("%w: expires_at and ttl are mutually exclusive", ErrInvalidExpiration)
+	}
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: invalid ttl %q", ErrInvalidExpiration, ttl)
+		}
+		at := now.Add(d)
+		expiresAt = &at
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, fmt.Errorf("%w: expires_at is in the past", ErrInvalidExpiration)
+	}
+	return expiresAt, nil
+}
@@ -0,0 +1,38 @@
+// Package services messages_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"go_microsvc/models"
+	"testing"
+	"time"
+)
+
+func TestRescheduleRejectsDeliveryAfterExpiration(t *testing.T) {
+	db := testDatabase(t)
+	messages := NewMessageService(db, MessageServiceConfig{Topic: "orders"})
+	ctx := context.Background()
+
+	msg, err := messages.Create(ctx, models.CreateMessageRequest{Content: "delayed", Delay: "1h", TTL: "2h"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Where("message_id = ?", msg.ID).Delete(&models.OutboxRecord{})
+		db.Unscoped().Delete(&models.Message{}, msg.ID)
+	})
+
+	late := msg.ExpiresAt.Add(time.Minute)
+	if _, err := messages.Reschedule(ctx, msg.ID, models.ScheduleMessageRequest{DeliverAt: &late}); !errors.Is(err, ErrInvalidExpiration) {
+		t.Fatalf("перенос доставки после истечения срока: %v, ожидалась ErrInvalidExpiration", err)
+	}
+
+	rescheduled, err := messages.Reschedule(ctx, msg.ID, models.ScheduleMessageRequest{Delay: "90m"})
+	if err != nil {
+		t.Fatalf("Reschedule: %v", err)
+	}
+	if !rescheduled.DeliverAt.Before(*msg.ExpiresAt) {
+		t.Errorf("deliver_at %s не раньше expires_at %s", rescheduled.DeliverAt, msg.ExpiresAt)
+	}
+}
@@ -0,0 +1,148 @@
+// Package services metrics.go
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// consumerMetrics счетчики работы consumer, общие для всех топиков процесса
+type consumerMetrics struct {
+	recordsConsumed     atomic.Int64
+	recordsProcessed    atomic.Int64
+	recordsRetried      atomic.Int64
+	recordsDeadLettered atomic.Int64
+	recordsExpired      atomic.Int64
+
+	batchesProcessed  atomic.Int64
+	batchRecords      atomic.Int64
+	batchFallbacks    atomic.Int64
+	lastBatchSize     atomic.Int64
+	lastBatchDuration atomic.Int64 // В наносекундах
+
+	batchSize   atomic.Int64 // Настроенный размер пачки
+	batchLinger atomic.Int64 // Настроенное время ожидания пачки в наносекундах
+
+	handlers sync.Map // Тип сообщения -> *handlerCounters
+
+	fetchMu sync.Mutex
+	fetch   fetchStatus
+}
+
+// fetchStatus время последнего успешного чтения и последняя ошибка чтения
+type fetchStatus struct {
+	recordAt time.Time
+	err      string
+	errorAt  time.Time
+}
+
+// handlerCounters счетчики обработчика одного типа сообщений
+type handlerCounters struct {
+	handled  atomic.Int64
+	failed   atomic.Int64
+	duration atomic.Int64 // Суммарная длительность в наносекундах
+}
+
+// metrics метрики consumer текущего процесса
+var metrics = &consumerMetrics{}
+
+// Metrics снимок метрик consumer
+// swagger:model Metrics
+type Metrics struct {
+	RecordsConsumed     int64 `json:"records_consumed"`      // Прочитано записей
+	RecordsProcessed    int64 `json:"records_processed"`     // Успешно обработано записей
+	RecordsRetried      int64 `json:"records_retried"`       // Отправлено в retry топики
+	RecordsDeadLettered int64 `json:"records_dead_lettered"` // Отправлено в DLQ
+	RecordsExpired      int64 `json:"records_expired"`       // Пропущено после истечения срока действия
+
+	BatchSize           int     `json:"batch_size"`             // Настроенный размер пачки
+	BatchLingerMs       int64   `json:"batch_linger_ms"`        // Настроенное время ожидания пачки
+	BatchesProcessed    int64   `json:"batches_processed"`      // Обработано пачек
+	BatchFallbacks      int64   `json:"batch_fallbacks"`        // Пачек, обработанных по одной записи после ошибки bulk upsert
+	AvgBatchSize        float64 `json:"avg_batch_size"`         // Средний размер пачки
+	LastBatchSize       int64   `json:"last_batch_size"`        // Размер последней пачки
+	LastBatchDurationMs float64 `json:"last_batch_duration_ms"` // Длительность обработки последней пачки
+
+	Handlers map[string]HandlerMetrics `json:"handlers"` // Метрики обработчиков по типам сообщений
+}
+
+// HandlerMetrics метрики обработчика одного типа сообщений
+// swagger:model HandlerMetrics
+type HandlerMetrics struct {
+	Handled       int64   `json:"handled"`         // Успешно обработано
+	Failed        int64   `json:"failed"`          // Завершилось ошибкой
+	AvgDurationMs float64 `json:"avg_duration_ms"` // Средняя длительность обработки
+}
+
+// GetMetrics возвращает снимок метрик consumer
+func GetMetrics() Metrics {
+	snapshot := Metrics{
+		RecordsConsumed:     metrics.recordsConsumed.Load(),
+		RecordsProcessed:    metrics.recordsProcessed.Load(),
+		RecordsRetried:      metrics.recordsRetried.Load(),
+		RecordsDeadLettered: metrics.recordsDeadLettered.Load(),
+		RecordsExpired:      metrics.recordsExpired.Load(),
+		BatchSize:           int(metrics.batchSize.Load()),
+		BatchLingerMs:       time.Duration(metrics.batchLinger.Load()).Milliseconds(),
+		BatchesProcessed:    metrics.batchesProcessed.Load(),
+		BatchFallbacks:      metrics.batchFallbacks.Load(),
+		LastBatchSize:       metrics.lastBatchSize.Load(),
+		LastBatchDurationMs: float64(metrics.lastBatchDuration.Load()) / float64(time.Millisecond),
+	}
+	snapshot.Handlers = make(map[string]HandlerMetrics)
+	metrics.handlers.Range(func(key, value interface{}) bool {
+		counters := value.(*handlerCounters)
+		h := HandlerMetrics{Handled: counters.handled.Load(), Failed: counters.failed.Load()}
+		if total := h.Handled + h.Failed; total > 0 {
+			h.AvgDurationMs = float64(counters.duration.Load()) / float64(total) / float64(time.Millisecond)
+		}
+		snapshot.Handlers[key.(string)] = h
+		return true
+	})
+	if snapshot.BatchesProcessed > 0 {
+		snapshot.AvgBatchSize = float64(metrics.batchRecords.Load()) / float64(snapshot.BatchesProcessed)
+	}
+	return snapshot
+}
+
+// observeBatch учитывает обработанную пачку
+func (m *consumerMetrics) observeBatch(size int, duration time.Duration) {
+	m.batchesProcessed.Add(1)
+	m.batchRecords.Add(int64(size))
+	m.lastBatchSize.Store(int64(size))
+	m.lastBatchDuration.Store(int64(duration))
+}
+
+// observeHandler учитывает вызов обработчика типа сообщений
+func (m *consumerMetrics) observeHandler(messageType string, duration time.Duration, err error) {
+	value, _ := m.handlers.LoadOrStore(messageType, &handlerCounters{})
+	counters := value.(*handlerCounters)
+	if err != nil {
+		counters.failed.Add(1)
+	} else {
+		counters.handled.Add(1)
+	}
+	counters.duration.Add(int64(duration))
+}
+
+// observeFetch учитывает результат чтения n записей из Kafka
+func (m *consumerMetrics) observeFetch(n int, err error) {
+	m.recordsConsumed.Add(int64(n))
+	m.fetchMu.Lock()
+	defer m.fetchMu.Unlock()
+	if n > 0 {
+		m.fetch.recordAt = time.Now()
+	}
+	if err != nil {
+		m.fetch.err = err.Error()
+		m.fetch.errorAt = time.Now()
+	}
+}
+
+// lastFetch возвращает время последнего успешного чтения и последнюю ошибку чтения
+func (m *consumerMetrics) lastFetch() fetchStatus {
+	m.fetchMu.Lock()
+	defer m.fetchMu.Unlock()
+	return m.fetch
+}
@@ -0,0 +1,95 @@
+// Package services middleware.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// LoggingMiddleware логирует заголовки, длительность и результат обработки сообщения
+func LoggingMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, env Envelope) error {
+			start := time.Now()
+			err := next.Handle(ctx, env)
+			if err != nil {
+				log.Printf("Ошибка обработки сообщения типа %s (%s/%d/%d, message-id=%d, correlation-id=%s) за %s: %v",
+					env.Type, env.Topic, env.Partition, env.Offset, env.MessageID, env.CorrelationID, time.Since(start), err)
+				return err
+			}
+			log.Printf("Сообщение типа %s обработано (%s/%d/%d, message-id=%d, correlation-id=%s, source=%s, schema=%s) за %s",
+				env.Type, env.Topic, env.Partition, env.Offset, env.MessageID, env.CorrelationID, env.SourceService, env.SchemaVersion, time.Since(start))
+			return nil
+		})
+	}
+}
+
+// MetricsMiddleware учитывает количество и длительность обработки сообщений по типам
+func MetricsMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, env Envelope) error {
+			start := time.Now()
+			err := next.Handle(ctx, env)
+			metrics.observeHandler(env.Type, time.Since(start), err)
+			return err
+		})
+	}
+}
+
+// RetryMiddleware повторяет обработку до retries раз с задержкой backoff, прежде чем вернуть ошибку.
+// Короткие повторы на месте снимают кратковременные сбои без отправки в retry топик.
+func RetryMiddleware(retries int, backoff time.Duration) Middleware {
+	return func(next Handler) Handler {
+		if retries <= 0 {
+			return next
+		}
+		return HandlerFunc(func(ctx context.Context, env Envelope) error {
+			err := next.Handle(ctx, env)
+			for attempt := 1; err != nil && attempt <= retries; attempt++ {
+				if err := sleepContext(ctx, backoff); err != nil {
+					return err
+				}
+				log.Printf("Повтор обработки сообщения типа %s (%s/%d/%d), попытка %d из %d: %v",
+					env.Type, env.Topic, env.Partition, env.Offset, attempt, retries, err)
+				err = next.Handle(ctx, env)
+			}
+			return err
+		})
+	}
+}
+
+// RecoverMiddleware превращает панику обработчика в ошибку, чтобы сообщение ушло в retry топик,
+// а consumer продолжил работу
+func RecoverMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, env Envelope) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					log.Printf("Паника в обработчике сообщения типа %s: %v\n%s", env.Type, p, debug.Stack())
+					err = fmt.Errorf("паника в обработчике: %v", p)
+				}
+			}()
+			return next.Handle(ctx, env)
+		})
+	}
+}
+
+// AttemptsMiddleware сохраняет каждый вызов обработчика в истории попыток сообщения.
+// Записи без ID сообщения в историю не попадают.
+func AttemptsMiddleware(attempts *AttemptLog) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, env Envelope) error {
+			if env.MessageID == 0 {
+				return next.Handle(ctx, env)
+			}
+			start := time.Now()
+			err := next.Handle(ctx, env)
+			// Попытка сохраняется и при прерывании обработки остановкой consumer
+			attempts.Record(context.WithoutCancel(ctx), newAttempt(env, start, err))
+			return err
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Package services monitor.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// partitionProgress время и смещение последней зафиксированной записи партиции в этом процессе
+type partitionProgress struct {
+	Offset      int64
+	ProcessedAt time.Time
+}
+
+// progressTracker хранит прогресс обработки партиций по группам
+type progressTracker struct {
+	mu         sync.RWMutex
+	partitions map[string]partitionProgress // ключ: group/topic/partition
+}
+
+// progress прогресс обработки партиций текущего процесса
+var progress = &progressTracker{partitions: make(map[string]partitionProgress)}
+
+// record запоминает зафиксированное смещение партиции
+func (t *progressTracker) record(groupID, topic string, partition int, offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partitions[progressKey(groupID, topic, partition)] = partitionProgress{Offset: offset, ProcessedAt: time.Now()}
+}
+
+// get возвращает прогресс партиции
+func (t *progressTracker) get(groupID, topic string, partition int) (partitionProgress, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	p, ok := t.partitions[progressKey(groupID, topic, partition)]
+	return p, ok
+}
+
+// progressKey формирует ключ прогресса партиции
+func progressKey(groupID, topic string, partition int) string {
+	return fmt.Sprintf("%s/%s/%d", groupID, topic, partition)
+}
+
+// PartitionStatus состояние чтения партиции группой
+// swagger:model PartitionStatus
+type PartitionStatus struct {
+	Partition       int        `json:"partition"`
+	CommittedOffset int64      `json:"committed_offset"`            // -1, если группа еще не фиксировала смещение
+	LogEndOffset    int64      `json:"log_end_offset"`              // Смещение следующей записи партиции
+	Lag             int64      `json:"lag"`                         // Количество необработанных записей
+	LastProcessedAt *time.Time `json:"last_processed_at,omitempty"` // Последняя фиксация смещения этим экземпляром
+	MemberID        string     `json:"member_id,omitempty"`         // Участник группы, которому назначена партиция
+	ClientID        string     `json:"client_id,omitempty"`
+	ClientHost      string     `json:"client_host,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// GroupStatus состояние группы потребителей по одному топику
+// swagger:model GroupStatus
+type GroupStatus struct {
+	GroupID    string            `json:"group_id"`
+	Topic      string            `json:"topic"`
+	State      string            `json:"state"`   // Состояние группы: Stable, PreparingRebalance, Empty, Dead
+	Members    int               `json:"members"` // Количество участников группы
+	TotalLag   int64             `json:"total_lag"`
+	Partitions []PartitionStatus `json:"partitions"`
+	Error      string            `json:"error,omitempty"` // Ошибка получения состояния; остальные поля могут быть неполными
+}
+
+// ConsumerMonitor вычисляет отставание групп потребителей через admin клиент Kafka
+type ConsumerMonitor struct {
+	client        *kafka.Client
+	subscriptions []Subscription
+}
+
+// NewConsumerMonitor создает монитор групп, читающих основной топик и его retry топики
+func NewConsumerMonitor(cfg ConsumerConfig, retries *RetryRouter) *ConsumerMonitor {
+	return &ConsumerMonitor{
+		client:        NewKafkaClient(cfg.Brokers),
+		subscriptions: Subscriptions(cfg, retries),
+	}
+}
+
+// NewKafkaClient создает admin клиент Kafka
+func NewKafkaClient(brokers string) *kafka.Client {
+	return &kafka.Client{
+		Addr:    kafka.TCP(strings.Split(brokers, ",")...),
+		Timeout: 10 * time.Second,
+	}
+}
+
+// Status возвращает состояние всех групп и их партиций. Ошибка получения состояния группы не прерывает
+// обход остальных групп и возвращается в поле Error ее состояния. Ошибка возвращается, только если
+// состояние не удалось получить ни для одной группы.
+func (m *ConsumerMonitor) Status(ctx context.Context) ([]GroupStatus, error) {
+	result := make([]GroupStatus, 0, len(m.subscriptions))
+	var firstErr error
+	failed := 0
+	for _, sub := range m.subscriptions {
+		status, err := GroupLag(ctx, m.client, sub.GroupID, sub.Topic)
+		if err != nil {
+			log.Printf("Состояние группы %s по топику %s не получено: %v", sub.GroupID, sub.Topic, err)
+			status.Error = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+		}
+		result = append(result, status)
+	}
+	if failed > 0 && failed == len(result) {
+		return result, firstErr
+	}
+	return result, nil
+}
+
+// GroupLag вычисляет зафиксированные смещения, смещения конца лога и отставание группы по партициям топика
+func GroupLag(ctx context.Context, client *kafka.Client, groupID, topic string) (GroupStatus, error) {
+	status := GroupStatus{GroupID: groupID, Topic: topic}
+
+	partitions, err := topicPartitions(ctx, client, topic)
+	if err != nil {
+		return status, err
+	}
+
+	// Участники группы и назначенные им партиции
+	assignments := make(map[int]kafka.DescribeGroupsResponseMember)
+	groups, err := client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{groupID}})
+	if err != nil {
+		return status, fmt.Errorf("ошибка получения описания группы %s: %w", groupID, err)
+	}
+	for _, group := range groups.Groups {
+		if group.Error != nil {
+			return status, fmt.Errorf("ошибка получения описания группы %s: %w", groupID, group.Error)
+		}
+		status.State = group.GroupState
+		status.Members = len(group.Members)
+		for _, member := range group.Members {
+			for _, assigned := range member.MemberAssignments.Topics {
+				if assigned.Topic != topic {
+					continue
+				}
+				for _, partition := range assigned.Partitions {
+					assignments[partition] = member
+				}
+			}
+		}
+	}
+
+	committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: groupID,
+		Topics:  map[string][]int{topic: partitions},
+	})
+	if err != nil {
+		return status, fmt.Errorf("ошибка получения смещений группы %s: %w", groupID, err)
+	}
+	if committed.Error != nil {
+		return status, fmt.Errorf("ошибка получения смещений группы %s: %w", groupID, committed.Error)
+	}
+	committedByPartition := make(map[int]kafka.OffsetFetchPartition)
+	for _, p := range committed.Topics[topic] {
+		committedByPartition[p.Partition] = p
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, 2*len(partitions))
+	for _, partition := range partitions {
+		requests = append(requests, kafka.FirstOffsetOf(partition), kafka.LastOffsetOf(partition))
+	}
+	offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+	if err != nil {
+		return status, fmt.Errorf("ошибка получения смещений топика %s: %w", topic, err)
+	}
+	offsetsByPartition := make(map[int]kafka.PartitionOffsets)
+	for _, p := range offsets.Topics[topic] {
+		offsetsByPartition[p.Partition] = p
+	}
+
+	for _, partition := range partitions {
+		ps := PartitionStatus{Partition: partition, CommittedOffset: -1}
+
+		if c, ok := committedByPartition[partition]; ok {
+			ps.CommittedOffset = c.CommittedOffset
+			if c.Error != nil {
+				ps.Error = c.Error.Error()
+			}
+		}
+		if o, ok := offsetsByPartition[partition]; ok {
+			ps.LogEndOffset = o.LastOffset
+			if o.Error != nil {
+				ps.Error = o.Error.Error()
+			}
+			// Без зафиксированного смещения отставание считается от начала лога
+			start := ps.CommittedOffset
+			if start < o.FirstOffset {
+				start = o.FirstOffset
+			}
+			if ps.LogEndOffset > start {
+				ps.Lag = ps.LogEndOffset - start
+			}
+		}
+		if member, ok := assignments[partition]; ok {
+			ps.MemberID = member.MemberID
+			ps.ClientID = member.ClientID
+			ps.ClientHost = member.ClientHost
+		}
+		if p, ok := progress.get(groupID, topic, partition); ok {
+			processedAt := p.ProcessedAt
+			ps.LastProcessedAt = &processedAt
+		}
+
+		status.TotalLag += ps.Lag
+		status.Partitions = append(status.Partitions, ps)
+	}
+	return status, nil
+}
+
+// topicPartitions возвращает отсортированные номера партиций топика
+func topicPartitions(ctx context.Context, client *kafka.Client, topic string) ([]int, error) {
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения метаданных топика %s: %w", topic, err)
+	}
+	var partitions []int
+	for _, t := range meta.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("ошибка получения метаданных топика %s: %w", topic, t.Error)
+		}
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+	}
+	sort.Ints(partitions)
+	return partitions, nil
+}
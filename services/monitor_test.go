@@ -0,0 +1,31 @@
+// Package services monitor_test.go
+package services
+
+import (
+	"context"
+	"go_microsvc/config"
+	"testing"
+	"time"
+)
+
+func TestConsumerMonitorReportsErrorPerGroup(t *testing.T) {
+	retries := NewRetryRouter(nil, nil, config.RetryPolicy{Tiers: []time.Duration{time.Minute}, MaxAttempts: 2}, nil)
+	cfg := ConsumerConfig{Brokers: "127.0.0.1:1", Topic: "orders", GroupID: "group"}
+	monitor := NewConsumerMonitor(cfg, retries)
+	monitor.client.Timeout = time.Second
+
+	// Каждая группа возвращается со своей ошибкой; ошибка всего ответа — только если недоступны все группы
+	status, err := monitor.Status(context.Background())
+	if err == nil {
+		t.Error("Status без доступных групп завершился без ошибки")
+	}
+	subs := Subscriptions(cfg, retries)
+	if len(status) != len(subs) {
+		t.Fatalf("состояний групп: %d, ожидалось %d", len(status), len(subs))
+	}
+	for i, group := range status {
+		if group.GroupID != subs[i].GroupID || group.Topic != subs[i].Topic || group.Error == "" {
+			t.Errorf("состояние группы %d: %+v", i, group)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+// Package services nats.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderNATSKey заголовок NATS с ключом записи: у сообщений NATS нет отдельного ключа
+const HeaderNATSKey = "message-key"
+
+// NATSConfig описывает параметры транспорта NATS JetStream
+type NATSConfig struct {
+	URL           string        // Адрес сервера NATS
+	Stream        string        // Поток JetStream, хранящий записи всех топиков
+	SubjectPrefix string        // Префикс subject: запись топика t публикуется в <SubjectPrefix>.t
+	AckWait       time.Duration // Время на подтверждение записи до повторной доставки
+	MaxDeliver    int           // Максимальное количество доставок записи durable consumer
+	FetchWait     time.Duration // Максимальное ожидание записей одним запросом Fetch
+	BatchSize     int           // Количество записей, запрашиваемых одним Fetch
+}
+
+// EmbeddedNATSConfig описывает параметры встроенного сервера NATS
+type EmbeddedNATSConfig struct {
+	Host     string // Адрес прослушивания
+	Port     int    // Порт клиентов; -1 — случайный порт
+	StoreDir string // Каталог хранения JetStream
+}
+
+// StartEmbeddedNATS запускает сервер NATS с JetStream внутри процесса и ждет готовности к подключениям
+func StartEmbeddedNATS(cfg EmbeddedNATSConfig) (*server.Server, error) {
+	ns, err := server.NewServer(&server.Options{
+		Host:      cfg.Host,
+		Port:      cfg.Port,
+		JetStream: true,
+		StoreDir:  cfg.StoreDir,
+		NoSigs:    true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания встроенного сервера NATS: %w", err)
+	}
+	ns.ConfigureLogger()
+	go ns.Start()
+	if !ns.ReadyForConnections(10 * time.Second) {
+		ns.Shutdown()
+		return nil, errors.New("встроенный сервер NATS не готов к подключениям")
+	}
+	log.Printf("Встроенный сервер NATS запущен: %s", ns.ClientURL())
+	return ns, nil
+}
+
+// NATSBroker транспорт сообщений на NATS JetStream.
+//
+// Записи всех топиков хранятся в одном потоке: топик отображается в subject <SubjectPrefix>.<topic>.
+// Группа потребителей отображается в durable consumer с явным подтверждением: неподтвержденная за
+// AckWait запись доставляется повторно, но не более MaxDeliver раз. Поток хранит записи независимо
+// от подтверждений, поэтому разные группы читают топик независимо, как в Kafka.
+//
+// Запись retry топика, срок которой из заголовка retry-due-at еще не наступил, не выдается читателю:
+// она возвращается в JetStream с задержкой до срока (NakWithDelay), поэтому ожидание не превышает AckWait.
+// Такой возврат расходует одну доставку из MaxDeliver.
+type NATSBroker struct {
+	conn *nats.Conn
+	js   jetstream.JetStream
+	cfg  NATSConfig
+	// Стартовая позиция новых durable consumer
+	deliverPolicy jetstream.DeliverPolicy
+}
+
+// NewNATSBroker подключается к серверу NATS и создает или обновляет поток JetStream
+func NewNATSBroker(ctx context.Context, cfg NATSConfig, consumerCfg ConsumerConfig) (*NATSBroker, error) {
+	if cfg.URL == "" {
+		cfg.URL = nats.DefaultURL
+	}
+	if cfg.Stream == "" {
+		cfg.Stream = "MESSAGES"
+	}
+	if cfg.SubjectPrefix == "" {
+		cfg.SubjectPrefix = "topics"
+	}
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = 30 * time.Second
+	}
+	if cfg.FetchWait <= 0 {
+		cfg.FetchWait = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+
+	conn, err := nats.Connect(cfg.URL, nats.Name("go_microsvc"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения к NATS %s: %w", cfg.URL, err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ошибка инициализации JetStream: %w", err)
+	}
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     cfg.Stream,
+		Subjects: []string{cfg.SubjectPrefix + ".>"},
+		Storage:  jetstream.FileStorage,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ошибка создания потока JetStream %s: %w", cfg.Stream, err)
+	}
+
+	deliverPolicy := jetstream.DeliverAllPolicy
+	if consumerCfg.StartOffset == StartOffsetLast {
+		deliverPolicy = jetstream.DeliverNewPolicy
+	}
+	return &NATSBroker{conn: conn, js: js, cfg: cfg, deliverPolicy: deliverPolicy}, nil
+}
+
+// Publisher создает публикатора. Записи без Topic публикуются в топик по умолчанию.
+// Close публикатора закрывает подключение к NATS, поэтому вызывается после остановки читателей.
+func (b *NATSBroker) Publisher(topic string) Publisher {
+	return &natsPublisher{broker: b, topic: topic}
+}
+
+// Subscriber создает подписчика
+func (b *NATSBroker) Subscriber() Subscriber {
+	return natsSubscriber{broker: b}
+}
+
+// subject возвращает subject топика
+func (b *NATSBroker) subject(topic string) string {
+	return b.cfg.SubjectPrefix + "." + topic
+}
+
+// natsPublisher публикует записи в поток JetStream
+type natsPublisher struct {
+	broker *NATSBroker
+	topic  string
+}
+
+// PublishSync публикует записи и ждет подтверждения потока для каждой.
+// При ошибке части записей возвращается kafka.WriteErrors.
+func (p *natsPublisher) PublishSync(ctx context.Context, msgs ...kafka.Message) error {
+	if p.broker.conn.IsClosed() {
+		return ErrProducerClosed
+	}
+	errs := make(kafka.WriteErrors, len(msgs))
+	failed := false
+	for i, m := range msgs {
+		topic := m.Topic
+		if topic == "" {
+			topic = p.topic
+		}
+		msg := nats.NewMsg(p.broker.subject(topic))
+		msg.Data = m.Value
+		for _, h := range m.Headers {
+			msg.Header.Add(h.Key, string(h.Value))
+		}
+		if len(m.Key) > 0 {
+			msg.Header.Set(HeaderNATSKey, string(m.Key))
+		}
+		if _, err := p.broker.js.PublishMsg(ctx, msg); err != nil {
+			errs[i] = err
+			failed = true
+		}
+	}
+	if failed {
+		return errs
+	}
+	return nil
+}
+
+// Close дожидается отправки буферизованных данных и закрывает подключение к NATS
+func (p *natsPublisher) Close(context.Context) error {
+	if p.broker.conn.IsClosed() {
+		return nil
+	}
+	if err := p.broker.conn.Flush(); err != nil {
+		p.broker.conn.Close()
+		return err
+	}
+	p.broker.conn.Close()
+	return nil
+}
+
+// natsSubscriber создает читателей durable consumer
+type natsSubscriber struct {
+	broker *NATSBroker
+}
+
+// Subscribe создает читателя топика. Durable consumer создается при первом чтении.
+func (s natsSubscriber) Subscribe(topic, groupID string) MessageReader {
+	return &natsReader{broker: s.broker, topic: topic, groupID: groupID, inflight: make(map[int64]jetstream.Msg)}
+}
+
+// natsReader читает записи durable consumer пачками и выдает их по одному
+type natsReader struct {
+	broker  *NATSBroker
+	topic   string
+	groupID string
+
+	mu       sync.Mutex
+	consumer jetstream.Consumer
+	pending  []jetstream.Msg
+	inflight map[int64]jetstream.Msg // Выданные, но не подтвержденные записи по номеру в потоке
+	closed   bool
+}
+
+// FetchMessage возвращает следующую запись, ожидая ее появления в потоке
+func (r *natsReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return kafka.Message{}, io.EOF
+		}
+		if len(r.pending) > 0 {
+			msg := r.pending[0]
+			r.pending = r.pending[1:]
+			m, err := r.toKafka(msg)
+			if err != nil {
+				r.mu.Unlock()
+				// Запись возвращается для повторной доставки, не дожидаясь истечения AckWait
+				if nakErr := msg.Nak(); nakErr != nil {
+					log.Printf("Ошибка возврата записи топика %s: %v", r.topic, nakErr)
+				}
+				return kafka.Message{}, err
+			}
+			if delay := time.Until(RetryDueAt(m)); delay > 0 {
+				r.mu.Unlock()
+				if err := msg.NakWithDelay(delay); err != nil {
+					log.Printf("Ошибка отложенного возврата записи %s/%d: %v", r.topic, m.Offset, err)
+				}
+				continue
+			}
+			r.inflight[m.Offset] = msg
+			r.mu.Unlock()
+			return m, nil
+		}
+		r.mu.Unlock()
+
+		if err := ctx.Err(); err != nil {
+			return kafka.Message{}, err
+		}
+		msgs, err := r.fetch(ctx)
+		if err != nil {
+			return kafka.Message{}, err
+		}
+		r.mu.Lock()
+		r.pending = append(r.pending, msgs...)
+		r.mu.Unlock()
+	}
+}
+
+// fetch запрашивает пачку записей, ожидая не дольше FetchWait
+func (r *natsReader) fetch(ctx context.Context) ([]jetstream.Msg, error) {
+	consumer, err := r.durable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := consumer.Fetch(r.broker.cfg.BatchSize, jetstream.FetchMaxWait(r.broker.cfg.FetchWait))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения JetStream: %w", err)
+	}
+	var msgs []jetstream.Msg
+	for msg := range batch.Messages() {
+		msgs = append(msgs, msg)
+	}
+	if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) {
+		return msgs, fmt.Errorf("ошибка чтения JetStream: %w", err)
+	}
+	return msgs, nil
+}
+
+// durable создает или обновляет durable consumer группы для топика
+func (r *natsReader) durable(ctx context.Context) (jetstream.Consumer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.consumer != nil {
+		return r.consumer, nil
+	}
+	cfg := r.broker.cfg
+	consumer, err := r.broker.js.CreateOrUpdateConsumer(ctx, cfg.Stream, jetstream.ConsumerConfig{
+		Durable:       durableName(r.groupID, r.topic),
+		FilterSubject: r.broker.subject(r.topic),
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+		MaxDeliver:    cfg.MaxDeliver,
+		DeliverPolicy: r.broker.deliverPolicy,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания durable consumer для топика %s: %w", r.topic, err)
+	}
+	r.consumer = consumer
+	return consumer, nil
+}
+
+// toKafka преобразует запись JetStream в kafka.Message. Offset — номер записи в потоке.
+func (r *natsReader) toKafka(msg jetstream.Msg) (kafka.Message, error) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("ошибка чтения метаданных JetStream: %w", err)
+	}
+	m := kafka.Message{
+		Topic:  r.topic,
+		Offset: int64(meta.Sequence.Stream),
+		Value:  msg.Data(),
+		Time:   meta.Timestamp,
+	}
+	for name, values := range msg.Headers() {
+		if name == HeaderNATSKey {
+			m.Key = []byte(values[0])
+			continue
+		}
+		for _, value := range values {
+			m.Headers = append(m.Headers, kafka.Header{Key: name, Value: []byte(value)})
+		}
+	}
+	return m, nil
+}
+
+// CommitMessages подтверждает записи; подтвержденные записи не доставляются группе повторно
+func (r *natsReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	for _, m := range msgs {
+		r.mu.Lock()
+		msg, ok := r.inflight[m.Offset]
+		delete(r.inflight, m.Offset)
+		r.mu.Unlock()
+		if !ok {
+			continue
+		}
+		if err := msg.DoubleAck(ctx); err != nil {
+			return fmt.Errorf("ошибка подтверждения записи %d: %w", m.Offset, err)
+		}
+	}
+	return nil
+}
+
+// acksRecords сообщает, что записи подтверждаются по одному: подтверждение записи не распространяется
+// на предыдущие записи потока, а повторно доставленные записи приходят с прежними номерами
+func (r *natsReader) acksRecords() bool {
+	return true
+}
+
+// Close возвращает прочитанные, но не подтвержденные записи для немедленной повторной доставки
+func (r *natsReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	for _, msg := range r.pending {
+		_ = msg.Nak()
+	}
+	for _, msg := range r.inflight {
+		_ = msg.Nak()
+	}
+	r.pending = nil
+	r.inflight = nil
+	return nil
+}
+
+// Topic возвращает читаемый топик
+func (r *natsReader) Topic() string {
+	return r.topic
+}
+
+// GroupID возвращает группу потребителей
+func (r *natsReader) GroupID() string {
+	return r.groupID
+}
+
+// durableName формирует имя durable consumer: точки и пробелы в именах недопустимы
+func durableName(groupID, topic string) string {
+	return strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_").Replace(groupID + "__" + topic)
+}
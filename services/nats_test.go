@@ -0,0 +1,136 @@
+// Package services nats_test.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testNATSBroker запускает встроенный сервер NATS и подключает к нему брокер с параметрами cfg
+func testNATSBroker(t *testing.T, cfg NATSConfig) *NATSBroker {
+	t.Helper()
+	ns, err := StartEmbeddedNATS(EmbeddedNATSConfig{Host: "127.0.0.1", Port: -1, StoreDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("StartEmbeddedNATS: %v", err)
+	}
+	t.Cleanup(ns.Shutdown)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cfg.URL = ns.ClientURL()
+	if cfg.FetchWait == 0 {
+		cfg.FetchWait = 100 * time.Millisecond
+	}
+	broker, err := NewNATSBroker(ctx, cfg, ConsumerConfig{})
+	if err != nil {
+		t.Fatalf("NewNATSBroker: %v", err)
+	}
+	t.Cleanup(broker.conn.Close)
+	return broker
+}
+
+func TestNATSWorkerPoolAcksEveryRecord(t *testing.T) {
+	broker := testNATSBroker(t, NATSConfig{})
+
+	const total = 40
+	var msgs []kafka.Message
+	for i := 0; i < total; i++ {
+		msgs = append(msgs, kafka.Message{Key: []byte(fmt.Sprintf("key-%d", i%4)), Value: []byte(fmt.Sprint(i))})
+	}
+	if err := broker.Publisher("orders").PublishSync(context.Background(), msgs...); err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	var mu sync.Mutex
+	processed := make(map[int64]bool)
+	ctx, cancel := context.WithCancel(context.Background())
+	handle := func(_ context.Context, m kafka.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed[m.Offset] = true
+		if len(processed) == total {
+			cancel()
+		}
+		return nil
+	}
+
+	reader := broker.Subscriber().Subscribe("orders", "group")
+	defer reader.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		newWorkerPool(reader, handle, 4, 10).run(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		cancel()
+		<-done
+		t.Fatalf("обработано записей: %d из %d", len(processed), total)
+	}
+
+	// Каждая обработанная запись подтверждена и не ожидает повторной доставки
+	if n := len(reader.(*natsReader).inflight); n != 0 {
+		t.Errorf("неподтвержденных записей у читателя: %d", n)
+	}
+	consumer, err := broker.js.Consumer(context.Background(), broker.cfg.Stream, durableName("group", "orders"))
+	if err != nil {
+		t.Fatalf("Consumer: %v", err)
+	}
+	info, err := consumer.Info(context.Background())
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.NumAckPending != 0 || info.NumPending != 0 {
+		t.Errorf("ожидают подтверждения: %d, не доставлено: %d", info.NumAckPending, info.NumPending)
+	}
+}
+
+func TestNATSDelaysRetryRecordsUntilDue(t *testing.T) {
+	broker := testNATSBroker(t, NATSConfig{AckWait: 200 * time.Millisecond, MaxDeliver: 3})
+	due := time.Now().Add(time.Second)
+	retry := kafka.Message{
+		Value:   []byte("retry"),
+		Headers: HeadersToKafka(map[string]string{HeaderRetryDueAt: due.UTC().Format(time.RFC3339Nano)}),
+	}
+	if err := broker.Publisher("orders.retry.1m").PublishSync(context.Background(), retry); err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	// Задержка повтора в несколько раз больше AckWait: запись не выдается до срока, поэтому не доставляется
+	// повторно по истечении AckWait и не расходует MaxDeliver
+	readers := []MessageReader{
+		broker.Subscriber().Subscribe("orders.retry.1m", "group"),
+		broker.Subscriber().Subscribe("orders.retry.1m", "group"),
+	}
+	fetched := make(chan MessageReader, len(readers))
+	records := make(chan kafka.Message, len(readers))
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for _, reader := range readers {
+		defer reader.Close()
+		go func(reader MessageReader) {
+			if m, err := reader.FetchMessage(ctx); err == nil {
+				fetched <- reader
+				records <- m
+			}
+		}(reader)
+	}
+
+	owner, m := <-fetched, <-records
+	if time.Now().Before(due) {
+		t.Fatalf("запись retry топика выдана до срока %s", due)
+	}
+	if err := owner.CommitMessages(ctx, m); err != nil {
+		t.Fatalf("CommitMessages: %v", err)
+	}
+	select {
+	case <-records:
+		t.Fatal("подтвержденная запись доставлена повторно")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -0,0 +1,327 @@
+// Package services offsets.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"log"
+	"strings"
+	"time"
+)
+
+// Режимы сброса смещений группы
+const (
+	ResetToEarliest  = "earliest"  // На начало лога
+	ResetToLatest    = "latest"    // На конец лога, необработанные записи пропускаются
+	ResetToTimestamp = "timestamp" // На первую запись не раньше указанного времени
+	ResetToOffset    = "offset"    // На указанные смещения по партициям
+)
+
+// replaySampleSize количество записей, показываемых в dry-run для каждой партиции
+const replaySampleSize = 5
+
+// ErrGroupActive возвращается при попытке изменить смещения группы, у которой есть активные участники
+var ErrGroupActive = errors.New("группа потребителей активна, приостановите ее перед сбросом смещений")
+
+// ErrInvalidOffsetReset возвращается при некорректных параметрах сброса смещений
+var ErrInvalidOffsetReset = errors.New("некорректные параметры сброса смещений")
+
+// OffsetResetRequest параметры сброса смещений
+// swagger:model OffsetResetRequest
+type OffsetResetRequest struct {
+	GroupID    string        `json:"group_id,omitempty"`   // По умолчанию группа основного топика
+	Topic      string        `json:"topic,omitempty"`      // По умолчанию основной топик
+	Mode       string        `json:"mode"`                 // earliest, latest, timestamp, offset
+	Timestamp  time.Time     `json:"timestamp,omitempty"`  // Для режима timestamp
+	Offsets    map[int]int64 `json:"offsets,omitempty"`    // Для режима offset: партиция -> смещение
+	Partitions []int         `json:"partitions,omitempty"` // Ограничить сброс партициями; по умолчанию все
+	DryRun     bool          `json:"dry_run"`              // Только показать, что будет перечитано
+}
+
+// ReplayRecord запись, которая будет перечитана после сброса
+// swagger:model ReplayRecord
+type ReplayRecord struct {
+	Offset  int64             `json:"offset"`
+	Time    time.Time         `json:"time"`
+	Key     string            `json:"key"`
+	Value   string            `json:"value"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// PartitionReset результат сброса смещения партиции
+// swagger:model PartitionReset
+type PartitionReset struct {
+	Partition     int            `json:"partition"`
+	CurrentOffset int64          `json:"current_offset"` // -1, если группа еще не фиксировала смещение
+	TargetOffset  int64          `json:"target_offset"`
+	Replay        int64          `json:"replay"`           // Сколько записей будет перечитано (отрицательное — пропущено)
+	Sample        []ReplayRecord `json:"sample,omitempty"` // Первые перечитываемые записи (только dry-run)
+	Error         string         `json:"error,omitempty"`
+}
+
+// OffsetResetResult результат сброса смещений группы
+// swagger:model OffsetResetResult
+type OffsetResetResult struct {
+	GroupID    string           `json:"group_id"`
+	Topic      string           `json:"topic"`
+	Mode       string           `json:"mode"`
+	DryRun     bool             `json:"dry_run"`
+	Applied    bool             `json:"applied"`
+	Partitions []PartitionReset `json:"partitions"`
+}
+
+// OffsetAdmin сбрасывает смещения групп потребителей с той же конфигурацией, что и consumer
+type OffsetAdmin struct {
+	client *kafka.Client
+	cfg    ConsumerConfig
+	pause  *PauseController
+}
+
+// NewOffsetAdmin создает администратор смещений. pause может быть nil (например, в CLI):
+// тогда группа должна быть остановлена заранее.
+func NewOffsetAdmin(cfg ConsumerConfig, pause *PauseController) *OffsetAdmin {
+	return &OffsetAdmin{client: NewKafkaClient(cfg.Brokers), cfg: cfg, pause: pause}
+}
+
+// Pause приостанавливает consumer этого процесса
+func (a *OffsetAdmin) Pause(ctx context.Context) error {
+	if a.pause == nil {
+		return errors.New("consumer не управляется этим процессом")
+	}
+	return a.pause.Pause(ctx)
+}
+
+// Resume возобновляет consumer этого процесса
+func (a *OffsetAdmin) Resume() error {
+	if a.pause == nil {
+		return errors.New("consumer не управляется этим процессом")
+	}
+	a.pause.Resume()
+	return nil
+}
+
+// Paused сообщает, приостановлен ли consumer этого процесса
+func (a *OffsetAdmin) Paused() bool {
+	return a.pause != nil && a.pause.Paused()
+}
+
+// Reset вычисляет целевые смещения и, если это не dry-run, фиксирует их за группу.
+// Группа не должна иметь активных участников.
+func (a *OffsetAdmin) Reset(ctx context.Context, req OffsetResetRequest) (OffsetResetResult, error) {
+	if req.GroupID == "" {
+		req.GroupID = a.cfg.GroupID
+	}
+	if req.Topic == "" {
+		req.Topic = a.cfg.Topic
+	}
+	result := OffsetResetResult{GroupID: req.GroupID, Topic: req.Topic, Mode: req.Mode, DryRun: req.DryRun}
+
+	partitions, err := a.resetPartitions(ctx, req)
+	if err != nil {
+		return result, err
+	}
+	targets, err := a.targetOffsets(ctx, req, partitions)
+	if err != nil {
+		return result, err
+	}
+
+	status, err := GroupLag(ctx, a.client, req.GroupID, req.Topic)
+	if err != nil {
+		return result, err
+	}
+	current := make(map[int]int64, len(status.Partitions))
+	for _, p := range status.Partitions {
+		current[p.Partition] = p.CommittedOffset
+	}
+
+	for _, partition := range partitions {
+		reset := PartitionReset{Partition: partition, CurrentOffset: current[partition], TargetOffset: targets[partition]}
+		if reset.CurrentOffset >= 0 {
+			reset.Replay = reset.CurrentOffset - reset.TargetOffset
+		}
+		if req.DryRun && (reset.CurrentOffset < 0 || reset.Replay > 0) {
+			sample, err := a.sample(ctx, req.Topic, partition, reset.TargetOffset)
+			if err != nil {
+				reset.Error = err.Error()
+			}
+			reset.Sample = sample
+		}
+		result.Partitions = append(result.Partitions, reset)
+	}
+
+	if req.DryRun {
+		return result, nil
+	}
+
+	// Фиксировать смещения за группу можно, только если в ней нет участников
+	if status.Members > 0 {
+		return result, ErrGroupActive
+	}
+
+	commits := make([]kafka.OffsetCommit, len(partitions))
+	for i, partition := range partitions {
+		commits[i] = kafka.OffsetCommit{Partition: partition, Offset: targets[partition]}
+	}
+	resp, err := a.client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      req.GroupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{req.Topic: commits},
+	})
+	if err != nil {
+		return result, fmt.Errorf("ошибка фиксации смещений группы %s: %w", req.GroupID, err)
+	}
+	for _, p := range resp.Topics[req.Topic] {
+		if p.Error != nil {
+			return result, fmt.Errorf("ошибка фиксации смещения партиции %d: %w", p.Partition, p.Error)
+		}
+	}
+
+	result.Applied = true
+	log.Printf("Смещения группы %s топика %s сброшены (%s): %v", req.GroupID, req.Topic, req.Mode, targets)
+	return result, nil
+}
+
+// resetPartitions возвращает партиции, смещения которых сбрасываются
+func (a *OffsetAdmin) resetPartitions(ctx context.Context, req OffsetResetRequest) ([]int, error) {
+	all, err := topicPartitions(ctx, a.client, req.Topic)
+	if err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("%w: топик %s не найден", ErrInvalidOffsetReset, req.Topic)
+	}
+
+	selected := req.Partitions
+	if req.Mode == ResetToOffset && len(selected) == 0 {
+		for partition := range req.Offsets {
+			selected = append(selected, partition)
+		}
+	}
+	if len(selected) == 0 {
+		return all, nil
+	}
+
+	exists := make(map[int]bool, len(all))
+	for _, p := range all {
+		exists[p] = true
+	}
+	for _, p := range selected {
+		if !exists[p] {
+			return nil, fmt.Errorf("%w: партиция %d топика %s не существует", ErrInvalidOffsetReset, p, req.Topic)
+		}
+	}
+	return selected, nil
+}
+
+// targetOffsets вычисляет целевые смещения партиций для режима сброса
+func (a *OffsetAdmin) targetOffsets(ctx context.Context, req OffsetResetRequest, partitions []int) (map[int]int64, error) {
+	targets := make(map[int]int64, len(partitions))
+
+	requests := make([]kafka.OffsetRequest, 0, len(partitions))
+	for _, partition := range partitions {
+		switch req.Mode {
+		case ResetToEarliest:
+			requests = append(requests, kafka.FirstOffsetOf(partition))
+		case ResetToLatest:
+			requests = append(requests, kafka.LastOffsetOf(partition))
+		case ResetToTimestamp:
+			if req.Timestamp.IsZero() {
+				return nil, fmt.Errorf("%w: для режима timestamp требуется timestamp", ErrInvalidOffsetReset)
+			}
+			requests = append(requests, kafka.TimeOffsetOf(partition, req.Timestamp))
+		case ResetToOffset:
+			offset, ok := req.Offsets[partition]
+			if !ok || offset < 0 {
+				return nil, fmt.Errorf("%w: не задано смещение для партиции %d", ErrInvalidOffsetReset, partition)
+			}
+			targets[partition] = offset
+		default:
+			return nil, fmt.Errorf("%w: неизвестный режим %q (ожидается %s)",
+				ErrInvalidOffsetReset, req.Mode, strings.Join([]string{ResetToEarliest, ResetToLatest, ResetToTimestamp, ResetToOffset}, ", "))
+		}
+	}
+	if len(requests) == 0 {
+		return targets, nil
+	}
+
+	isolation, _ := parseIsolationLevel(a.cfg.IsolationLevel)
+	resp, err := a.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics:         map[string][]kafka.OffsetRequest{req.Topic: requests},
+		IsolationLevel: isolation,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения смещений топика %s: %w", req.Topic, err)
+	}
+	for _, p := range resp.Topics[req.Topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("ошибка получения смещения партиции %d: %w", p.Partition, p.Error)
+		}
+		switch req.Mode {
+		case ResetToEarliest:
+			targets[p.Partition] = p.FirstOffset
+		case ResetToLatest:
+			targets[p.Partition] = p.LastOffset
+		case ResetToTimestamp:
+			// Если записей после указанного времени нет, брокер возвращает -1: используем конец лога
+			targets[p.Partition] = -1
+			for offset := range p.Offsets {
+				targets[p.Partition] = offset
+			}
+		}
+	}
+	for _, partition := range partitions {
+		if targets[partition] < 0 {
+			last, err := a.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+				Topics: map[string][]kafka.OffsetRequest{req.Topic: {kafka.LastOffsetOf(partition)}},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("ошибка получения смещений топика %s: %w", req.Topic, err)
+			}
+			for _, p := range last.Topics[req.Topic] {
+				targets[partition] = p.LastOffset
+			}
+		}
+	}
+	return targets, nil
+}
+
+// sample читает первые записи партиции начиная с offset без участия в группе
+func (a *OffsetAdmin) sample(ctx context.Context, topic string, partition int, offset int64) ([]ReplayRecord, error) {
+	readerCfg := a.cfg.ReaderConfig(topic, "")
+	readerCfg.Partition = partition
+	readerCfg.MinBytes = 1
+	readerCfg.MaxWait = time.Second
+	reader := kafka.NewReader(readerCfg)
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Printf("Ошибка при закрытии reader: %v", err)
+		}
+	}()
+	if err := reader.SetOffset(offset); err != nil {
+		return nil, err
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var records []ReplayRecord
+	for len(records) < replaySampleSize {
+		m, err := reader.ReadMessage(readCtx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			return records, err
+		}
+		records = append(records, ReplayRecord{
+			Offset:  m.Offset,
+			Time:    m.Time,
+			Key:     string(m.Key),
+			Value:   string(m.Value),
+			Headers: HeadersFromKafka(m.Headers),
+		})
+	}
+	return records, nil
+}
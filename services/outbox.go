@@ -0,0 +1,237 @@
+// Package services outbox.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+// OutboxRelayConfig описывает параметры фоновой публикации записей outbox
+type OutboxRelayConfig struct {
+	PollInterval time.Duration // Интервал опроса таблицы outbox
+	BatchSize    int           // Максимальное количество записей за одну итерацию
+	BaseBackoff  time.Duration // Начальная задержка перед повторной попыткой
+	MaxBackoff   time.Duration // Максимальная задержка перед повторной попыткой
+	ClaimTimeout time.Duration // Время, на которое выбранные записи скрываются от других relay до подтверждения публикации
+}
+
+// OutboxRelay публикует ожидающие записи outbox в Kafka и помечает их отправленными.
+// Гарантирует доставку "как минимум один раз": запись помечается отправленной только после подтверждения брокера.
+type OutboxRelay struct {
+	db       *database.Database
+	producer Publisher
+	cfg      OutboxRelayConfig
+}
+
+// NewOutboxRelay создает relay для публикации записей outbox
+func NewOutboxRelay(db *database.Database, producer Publisher, cfg OutboxRelayConfig) *OutboxRelay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 500 * time.Millisecond
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = time.Second
+	}
+	if cfg.MaxBackoff < cfg.BaseBackoff {
+		cfg.MaxBackoff = cfg.BaseBackoff
+	}
+	if cfg.ClaimTimeout <= 0 {
+		cfg.ClaimTimeout = 30 * time.Second
+	}
+	return &OutboxRelay{db: db, producer: producer, cfg: cfg}
+}
+
+// Run запускает цикл публикации и блокируется до отмены контекста
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	log.Println("Outbox relay запущен")
+	for {
+		// Пока записи выбираются полными пачками, публикуем без ожидания следующего тика
+		for {
+			n, err := r.relayBatch(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Ошибка публикации записей outbox: %v", err)
+			}
+			if err != nil || n < r.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Завершение работы outbox relay по запросу контекста")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishRecords немедленно публикует указанные записи outbox одним вызовом WriteMessages,
+// не дожидаясь очередного опроса. Записи, которые не удалось опубликовать, остаются для фонового цикла.
+// Возвращает количество опубликованных записей.
+func (r *OutboxRelay) PublishRecords(ctx context.Context, ids []uint) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	_, sent, err := r.relay(ctx, ids)
+	return sent, err
+}
+
+// relayBatch публикует очередную пачку готовых к отправке записей и возвращает размер пачки
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	count, _, err := r.relay(ctx, nil)
+	return count, err
+}
+
+// relay выбирает записи, публикует их и фиксирует результат. Если ids не пусто, выбираются
+// только указанные записи без ограничения размера пачки. Возвращает количество выбранных и опубликованных записей.
+// Транзакции выбора и подтверждения короткие: публикация, включая повторы продюсера, выполняется вне транзакции.
+func (r *OutboxRelay) relay(ctx context.Context, ids []uint) (int, int, error) {
+	records, err := r.claim(ctx, ids)
+	if err != nil || len(records) == 0 {
+		return 0, 0, err
+	}
+
+	producedAt := time.Now().UTC().Format(time.RFC3339Nano)
+	messages := make([]kafka.Message, len(records))
+	for i, record := range records {
+		headers := HeadersToKafka(record.Headers)
+		headers = append(headers, kafka.Header{Key: HeaderProducedAt, Value: []byte(producedAt)})
+		messages[i] = kafka.Message{
+			Topic:   record.Topic,
+			Key:     []byte(record.Key),
+			Value:   record.Payload,
+			Headers: headers,
+		}
+	}
+
+	// Публикуем всю пачку одним вызовом WriteMessages
+	publishErr := r.producer.PublishSync(ctx, messages...)
+
+	var writeErrs kafka.WriteErrors
+	if publishErr != nil && !errors.As(publishErr, &writeErrs) {
+		writeErrs = make(kafka.WriteErrors, len(records))
+		for i := range writeErrs {
+			writeErrs[i] = publishErr
+		}
+	}
+
+	// Результат публикации фиксируется и при отмене контекста, иначе опубликованные записи отправятся повторно
+	sent, err := r.confirm(context.WithoutCancel(ctx), records, writeErrs)
+	return len(records), sent, err
+}
+
+// claim блокирует готовые к отправке записи и откладывает их next_attempt_at на ClaimTimeout, чтобы другие
+// экземпляры не выбрали их, пока идет публикация. Если relay завершится до подтверждения, записи
+// снова станут доступны по истечении ClaimTimeout. SKIP LOCKED позволяет нескольким экземплярам API
+// работать с одной таблицей без двойной отправки.
+func (r *OutboxRelay) claim(ctx context.Context, ids []uint) ([]models.OutboxRecord, error) {
+	var records []models.OutboxRecord
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, now).
+			Order("id")
+		if len(ids) > 0 {
+			query = query.Where("id IN ?", ids)
+		} else {
+			query = query.Limit(r.cfg.BatchSize)
+		}
+		if err := query.Find(&records).Error; err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		claimed := make([]uint, len(records))
+		for i, record := range records {
+			claimed[i] = record.ID
+		}
+		return tx.Model(&models.OutboxRecord{}).
+			Where("id IN ?", claimed).
+			Update("next_attempt_at", now.Add(r.cfg.ClaimTimeout)).Error
+	})
+	return records, err
+}
+
+// confirm помечает опубликованные записи отправленными, а для остальных откладывает следующую попытку.
+// writeErrs содержит ошибку для каждой записи records или равен nil, если опубликована вся пачка.
+// Возвращает количество опубликованных записей.
+func (r *OutboxRelay) confirm(ctx context.Context, records []models.OutboxRecord, writeErrs kafka.WriteErrors) (int, error) {
+	var sentIDs, messageIDs []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for i, record := range records {
+			if writeErrs == nil || writeErrs[i] == nil {
+				sentIDs = append(sentIDs, record.ID)
+				if record.MessageID != 0 {
+					messageIDs = append(messageIDs, record.MessageID)
+				}
+				continue
+			}
+			if err := r.markFailed(tx, record, writeErrs[i], now); err != nil {
+				return err
+			}
+		}
+
+		if len(sentIDs) > 0 {
+			// Запись, помеченная просроченной во время публикации, сохраняет статус expired
+			err := tx.Model(&models.OutboxRecord{}).
+				Where("id IN ? AND status = ?", sentIDs, models.OutboxStatusPending).
+				Updates(map[string]interface{}{
+					"status":   models.OutboxStatusSent,
+					"sent_at":  now,
+					"attempts": gorm.Expr("attempts + 1"),
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		// Сообщения, которые consumer уже начал обрабатывать, остаются в своем статусе
+		_, err := transitionMessages(tx, messageIDs, models.MessageStatusPublished, now)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(sentIDs), nil
+}
+
+// markFailed увеличивает счетчик попыток и откладывает следующую попытку с экспоненциальной задержкой
+func (r *OutboxRelay) markFailed(tx *gorm.DB, record models.OutboxRecord, cause error, now time.Time) error {
+	attempts := record.Attempts + 1
+	delay := r.backoff(attempts)
+	log.Printf("Запись outbox %d не опубликована (попытка %d), повтор через %s: %v", record.ID, attempts, delay, cause)
+
+	return tx.Model(&models.OutboxRecord{}).
+		Where("id = ?", record.ID).
+		Updates(map[string]interface{}{
+			"attempts":        attempts,
+			"next_attempt_at": now.Add(delay),
+			"last_error":      cause.Error(),
+		}).Error
+}
+
+// backoff вычисляет задержку перед повторной попыткой: BaseBackoff * 2^(attempts-1), но не более MaxBackoff
+func (r *OutboxRelay) backoff(attempts int) time.Duration {
+	delay := r.cfg.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= r.cfg.MaxBackoff {
+			return r.cfg.MaxBackoff
+		}
+	}
+	return delay
+}
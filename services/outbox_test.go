@@ -0,0 +1,164 @@
+// Package services outbox_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"testing"
+	"time"
+)
+
+// publisherFunc Publisher, публикация которого выполняется функцией
+type publisherFunc func(ctx context.Context, msgs ...kafka.Message) error
+
+func (f publisherFunc) PublishSync(ctx context.Context, msgs ...kafka.Message) error {
+	return f(ctx, msgs...)
+}
+
+func (f publisherFunc) Close(context.Context) error { return nil }
+
+// createOutboxMessages создает n сообщений с записями outbox и возвращает их в порядке создания
+func createOutboxMessages(t *testing.T, db *database.Database, n int) []models.Message {
+	t.Helper()
+	messages := NewMessageService(db, MessageServiceConfig{Topic: "orders"})
+	created := make([]models.Message, n)
+	for i := range created {
+		msg, err := messages.Create(context.Background(), models.CreateMessageRequest{Content: "order"})
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		created[i] = msg
+	}
+	return created
+}
+
+// outboxRecord возвращает запись outbox сообщения
+func outboxRecord(t *testing.T, db *database.Database, messageID uint) models.OutboxRecord {
+	t.Helper()
+	var record models.OutboxRecord
+	if err := db.Where("message_id = ?", messageID).First(&record).Error; err != nil {
+		t.Fatalf("запись outbox сообщения %d: %v", messageID, err)
+	}
+	return record
+}
+
+func TestOutboxRelayMapsWriteErrorsPerRecord(t *testing.T) {
+	db := testDatabase(t)
+	created := createOutboxMessages(t, db, 3)
+
+	var published []kafka.Message
+	publisher := publisherFunc(func(ctx context.Context, msgs ...kafka.Message) error {
+		published = msgs
+		// Транзакция выбора записей завершена до публикации: записи не заблокированы и скрыты от других relay
+		lockCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		err := db.WithContext(lockCtx).Model(&models.OutboxRecord{}).Where("status = ?", models.OutboxStatusPending).
+			Update("updated_at", time.Now()).Error
+		if err != nil {
+			t.Errorf("записи outbox заблокированы во время публикации: %v", err)
+		}
+		var claimed []models.OutboxRecord
+		db.Where("status = ?", models.OutboxStatusPending).Find(&claimed)
+		for _, record := range claimed {
+			if !record.NextAttemptAt.After(time.Now().Add(time.Minute)) {
+				t.Errorf("запись outbox %d не отложена на время публикации: next_attempt_at=%s", record.ID, record.NextAttemptAt)
+			}
+		}
+		return kafka.WriteErrors{nil, errors.New("broker unavailable"), nil}
+	})
+	relay := NewOutboxRelay(db, publisher, OutboxRelayConfig{BaseBackoff: time.Minute, ClaimTimeout: time.Hour})
+
+	count, err := relay.relayBatch(context.Background())
+	if err != nil || count != 3 || len(published) != 3 {
+		t.Fatalf("relayBatch: count=%d, опубликовано %d, err=%v", count, len(published), err)
+	}
+
+	for i, msg := range created {
+		record := outboxRecord(t, db, msg.ID)
+		var stored models.Message
+		db.First(&stored, msg.ID)
+		if i == 1 {
+			if record.Status != models.OutboxStatusPending || record.Attempts != 1 || record.LastError != "broker unavailable" {
+				t.Errorf("неопубликованная запись outbox: %+v", record)
+			}
+			if delay := time.Until(record.NextAttemptAt); delay <= 0 || delay > time.Minute {
+				t.Errorf("следующая попытка через %s, ожидалась задержка BaseBackoff", delay)
+			}
+			if stored.Status != models.MessageStatusPending {
+				t.Errorf("статус неопубликованного сообщения: %s, ожидался pending", stored.Status)
+			}
+			continue
+		}
+		if record.Status != models.OutboxStatusSent || record.Attempts != 1 || record.SentAt == nil {
+			t.Errorf("опубликованная запись outbox %d: %+v", i, record)
+		}
+		if stored.Status != models.MessageStatusPublished {
+			t.Errorf("статус опубликованного сообщения %d: %s, ожидался published", i, stored.Status)
+		}
+	}
+
+	// Неопубликованная запись ждет следующей попытки
+	if count, err := relay.relayBatch(context.Background()); err != nil || count != 0 {
+		t.Errorf("повторный relayBatch: count=%d, err=%v, ожидалось 0 записей", count, err)
+	}
+}
+
+func TestOutboxRelayFailsWholeBatchOnPublishError(t *testing.T) {
+	db := testDatabase(t)
+	created := createOutboxMessages(t, db, 2)
+
+	relay := NewOutboxRelay(db, publisherFunc(func(context.Context, ...kafka.Message) error {
+		return errors.New("connection refused")
+	}), OutboxRelayConfig{})
+	if _, err := relay.relayBatch(context.Background()); err != nil {
+		t.Fatalf("relayBatch: %v", err)
+	}
+
+	for _, msg := range created {
+		if record := outboxRecord(t, db, msg.ID); record.Status != models.OutboxStatusPending || record.Attempts != 1 || record.LastError != "connection refused" {
+			t.Errorf("запись outbox: %+v", record)
+		}
+	}
+}
+
+func TestOutboxRelaySkipsRecordsClaimedByAnotherRelay(t *testing.T) {
+	db := testDatabase(t)
+	created := createOutboxMessages(t, db, 2)
+
+	publishing := make(chan struct{})
+	release := make(chan struct{})
+	slow := NewOutboxRelay(db, publisherFunc(func(context.Context, ...kafka.Message) error {
+		close(publishing)
+		<-release
+		return nil
+	}), OutboxRelayConfig{})
+	done := make(chan error, 1)
+	go func() {
+		_, err := slow.relayBatch(context.Background())
+		done <- err
+	}()
+	<-publishing
+
+	// Пока первый relay публикует пачку, второй не выбирает ее записи
+	var duplicates int
+	other := NewOutboxRelay(db, publisherFunc(func(_ context.Context, msgs ...kafka.Message) error {
+		duplicates += len(msgs)
+		return nil
+	}), OutboxRelayConfig{})
+	if count, err := other.relayBatch(context.Background()); err != nil || count != 0 || duplicates != 0 {
+		t.Errorf("второй relay: count=%d, опубликовано %d, err=%v", count, duplicates, err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("relayBatch: %v", err)
+	}
+	for _, msg := range created {
+		if record := outboxRecord(t, db, msg.ID); record.Status != models.OutboxStatusSent {
+			t.Errorf("запись outbox %d: статус %s, ожидался sent", record.ID, record.Status)
+		}
+	}
+}
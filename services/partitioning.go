@@ -0,0 +1,94 @@
+// Package services partitioning.go
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/models"
+	"strconv"
+)
+
+// Стратегии формирования ключа сообщения Kafka
+const (
+	KeyStrategyMessageID    = "message_id"    // ID сообщения
+	KeyStrategyPartitionKey = "partition_key" // Поле partition_key из запроса, иначе ID сообщения
+	KeyStrategyTenant       = "tenant"        // Идентификатор арендатора, иначе ID сообщения
+	KeyStrategyContentHash  = "content_hash"  // SHA-256 от содержимого сообщения
+)
+
+// Балансировщики, определяющие партицию по ключу сообщения
+const (
+	BalancerLeastBytes = "least_bytes" // Партиция с наименьшим объемом, ключ игнорируется
+	BalancerRoundRobin = "round_robin" // По кругу, ключ игнорируется
+	BalancerHash       = "hash"        // FNV-1a хеш ключа (по умолчанию в kafka-go)
+	BalancerCRC32      = "crc32"       // CRC32 хеш ключа, совместим с librdkafka
+	BalancerMurmur2    = "murmur2"     // Murmur2 хеш ключа, совместим с Java producer
+)
+
+// KeyStrategy определяет ключ сообщения Kafka, а значит и партицию, в которую оно попадет.
+// Сообщения с одинаковым ключом попадают в одну партицию и обрабатываются по порядку.
+type KeyStrategy interface {
+	Key(msg models.Message) string
+}
+
+// KeyStrategyFunc позволяет использовать функцию в качестве KeyStrategy
+type KeyStrategyFunc func(msg models.Message) string
+
+// Key вызывает функцию стратегии
+func (f KeyStrategyFunc) Key(msg models.Message) string {
+	return f(msg)
+}
+
+// NewKeyStrategy возвращает стратегию формирования ключа по ее имени из конфигурации
+func NewKeyStrategy(name string) (KeyStrategy, error) {
+	switch name {
+	case KeyStrategyMessageID:
+		return KeyStrategyFunc(messageIDKey), nil
+	case "", KeyStrategyPartitionKey:
+		return KeyStrategyFunc(func(msg models.Message) string {
+			if msg.PartitionKey != "" {
+				return msg.PartitionKey
+			}
+			return messageIDKey(msg)
+		}), nil
+	case KeyStrategyTenant:
+		return KeyStrategyFunc(func(msg models.Message) string {
+			if msg.TenantID != "" {
+				return msg.TenantID
+			}
+			return messageIDKey(msg)
+		}), nil
+	case KeyStrategyContentHash:
+		return KeyStrategyFunc(func(msg models.Message) string {
+			sum := sha256.Sum256([]byte(msg.Content))
+			return hex.EncodeToString(sum[:])
+		}), nil
+	default:
+		return nil, fmt.Errorf("неизвестная стратегия ключа сообщения: %q", name)
+	}
+}
+
+// NewBalancer возвращает балансировщик партиций по его имени из конфигурации
+func NewBalancer(name string) (kafka.Balancer, error) {
+	switch name {
+	case BalancerLeastBytes:
+		return &kafka.LeastBytes{}, nil
+	case BalancerRoundRobin:
+		return &kafka.RoundRobin{}, nil
+	case "", BalancerHash:
+		return &kafka.Hash{}, nil
+	case BalancerCRC32:
+		return kafka.CRC32Balancer{}, nil
+	case BalancerMurmur2:
+		return kafka.Murmur2Balancer{}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный балансировщик Kafka: %q", name)
+	}
+}
+
+// messageIDKey использует ID сообщения в качестве ключа
+func messageIDKey(msg models.Message) string {
+	return strconv.FormatUint(uint64(msg.ID), 10)
+}
@@ -0,0 +1,93 @@
+// Package services pause.go
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// PauseController приостанавливает и возобновляет чтение consumer. При паузе readers закрываются
+// и покидают группу, что позволяет изменять смещения группы внешними средствами.
+type PauseController struct {
+	mu      sync.Mutex
+	paused  bool
+	cancel  context.CancelFunc // Отмена текущего цикла чтения
+	stopped chan struct{}      // Закрывается по завершении текущего цикла чтения
+	resumed chan struct{}      // Закрывается при возобновлении
+}
+
+// NewPauseController создает контроллер в состоянии "работает"
+func NewPauseController() *PauseController {
+	return &PauseController{}
+}
+
+// Paused сообщает, приостановлено ли чтение
+func (p *PauseController) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Pause останавливает текущий цикл чтения и ждет, пока readers покинут группу
+func (p *PauseController) Pause(ctx context.Context) error {
+	p.mu.Lock()
+	if p.paused {
+		p.mu.Unlock()
+		return nil
+	}
+	p.paused = true
+	p.resumed = make(chan struct{})
+	cancel, stopped := p.cancel, p.stopped
+	p.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Resume возобновляет чтение
+func (p *PauseController) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		return
+	}
+	p.paused = false
+	close(p.resumed)
+}
+
+// run выполняет цикл чтения run до завершения ctx, перезапуская его после каждой паузы
+func (p *PauseController) run(ctx context.Context, run func(ctx context.Context)) {
+	for {
+		p.mu.Lock()
+		if p.paused {
+			resumed := p.resumed
+			p.mu.Unlock()
+			select {
+			case <-resumed:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		runCtx, cancel := context.WithCancel(ctx)
+		stopped := make(chan struct{})
+		p.cancel, p.stopped = cancel, stopped
+		p.mu.Unlock()
+
+		run(runCtx)
+		cancel()
+		close(stopped)
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Package services postgres_queue.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PostgresQueueConfig описывает параметры очереди в Postgres
+type PostgresQueueConfig struct {
+	PollInterval      time.Duration // Интервал опроса таблицы при пустой очереди
+	BatchSize         int           // Количество заданий, захватываемых за один запрос
+	VisibilityTimeout time.Duration // Время на обработку захваченного задания до повторной выдачи
+	MaxDeliveries     int           // Количество выдач без подтверждения, после которого задание помечается dead
+}
+
+// PostgresQueue транспорт сообщений на таблице queue_jobs для развертываний без Kafka.
+//
+// Публикация добавляет задание в таблицу. Потребители захватывают задания через
+// SELECT ... FOR UPDATE SKIP LOCKED, поэтому каждое задание выдается одному потребителю.
+// Захваченное задание скрыто от остальных на VisibilityTimeout; подтверждение (CommitMessages)
+// удаляет его. Неподтвержденное задание выдается повторно, а после MaxDeliveries выдач помечается dead.
+// Записи retry топиков становятся доступны только ко времени из заголовка retry-due-at, поэтому
+// потребитель не держит захваченное задание, пока ждет его срока.
+//
+// В отличие от Kafka, группа потребителей не влияет на распределение: все читатели топика
+// конкурируют за его задания, а порядок заданий с одним ключом между читателями не гарантируется.
+type PostgresQueue struct {
+	db      *database.Database
+	cfg     PostgresQueueConfig
+	readers atomic.Int64
+}
+
+// NewPostgresQueue создает очередь в Postgres
+func NewPostgresQueue(db *database.Database, cfg PostgresQueueConfig) *PostgresQueue {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 500 * time.Millisecond
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.VisibilityTimeout <= 0 {
+		cfg.VisibilityTimeout = 30 * time.Second
+	}
+	if cfg.MaxDeliveries <= 0 {
+		cfg.MaxDeliveries = 5
+	}
+	return &PostgresQueue{db: db, cfg: cfg}
+}
+
+// Publisher создает публикатора. Записи без Topic публикуются в топик по умолчанию.
+func (q *PostgresQueue) Publisher(topic string) Publisher {
+	return &postgresPublisher{queue: q, topic: topic}
+}
+
+// Subscriber создает подписчика очереди
+func (q *PostgresQueue) Subscriber() Subscriber {
+	return postgresSubscriber{queue: q}
+}
+
+// postgresPublisher добавляет записи в таблицу заданий
+type postgresPublisher struct {
+	queue  *PostgresQueue
+	topic  string
+	closed atomic.Bool
+}
+
+// PublishSync добавляет все записи одной вставкой. Записи становятся доступны потребителям сразу,
+// записи retry топиков — ко времени из заголовка retry-due-at.
+func (p *postgresPublisher) PublishSync(ctx context.Context, msgs ...kafka.Message) error {
+	if p.closed.Load() {
+		return ErrProducerClosed
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	jobs := make([]models.QueueJob, len(msgs))
+	for i, m := range msgs {
+		topic := m.Topic
+		if topic == "" {
+			topic = p.topic
+		}
+		visibleAt := now
+		if dueAt := RetryDueAt(m); dueAt.After(now) {
+			visibleAt = dueAt
+		}
+		jobs[i] = models.QueueJob{
+			Topic:     topic,
+			Key:       string(m.Key),
+			Payload:   m.Value,
+			Headers:   HeadersFromKafka(m.Headers),
+			Status:    models.QueueJobStatusPending,
+			VisibleAt: visibleAt,
+		}
+	}
+	if err := p.queue.db.WithContext(ctx).Create(&jobs).Error; err != nil {
+		return fmt.Errorf("ошибка добавления заданий в очередь: %w", err)
+	}
+	return nil
+}
+
+// Close запрещает новые публикации
+func (p *postgresPublisher) Close(context.Context) error {
+	p.closed.Store(true)
+	return nil
+}
+
+// postgresSubscriber создает читателей очереди
+type postgresSubscriber struct {
+	queue *PostgresQueue
+}
+
+// Subscribe создает читателя топика
+func (s postgresSubscriber) Subscribe(topic, groupID string) MessageReader {
+	id := fmt.Sprintf("%s-%d", instanceID, s.queue.readers.Add(1))
+	return &postgresReader{queue: s.queue, topic: topic, groupID: groupID, id: id}
+}
+
+// postgresReader захватывает задания топика пачками и выдает их по одному
+type postgresReader struct {
+	queue   *PostgresQueue
+	topic   string
+	groupID string
+	id      string // Идентификатор читателя в locked_by
+
+	mu        sync.Mutex
+	pending   []kafka.Message // Захваченные, но еще не выданные задания
+	claimedAt time.Time       // Время захвата или продления захвата pending
+	closed    bool
+}
+
+// FetchMessage возвращает следующее задание, ожидая его появления в таблице
+func (r *postgresReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return kafka.Message{}, io.EOF
+		}
+		if len(r.pending) > 0 && time.Since(r.claimedAt) > r.queue.cfg.VisibilityTimeout/2 {
+			if err := r.extend(ctx); err != nil {
+				r.mu.Unlock()
+				return kafka.Message{}, err
+			}
+		}
+		if len(r.pending) > 0 {
+			m := r.pending[0]
+			r.pending = r.pending[1:]
+			r.mu.Unlock()
+			return m, nil
+		}
+		r.mu.Unlock()
+
+		claimedAt := time.Now()
+		msgs, err := r.claim(ctx)
+		if err != nil {
+			return kafka.Message{}, err
+		}
+		if len(msgs) > 0 {
+			r.mu.Lock()
+			r.pending = append(r.pending, msgs...)
+			r.claimedAt = claimedAt
+			r.mu.Unlock()
+			continue
+		}
+		if err := sleepContext(ctx, r.queue.cfg.PollInterval); err != nil {
+			return kafka.Message{}, err
+		}
+	}
+}
+
+// claim помечает dead задания, превысившие MaxDeliveries, и захватывает очередную пачку доступных заданий
+func (r *postgresReader) claim(ctx context.Context) ([]kafka.Message, error) {
+	cfg := r.queue.cfg
+	db := r.queue.db.WithContext(ctx)
+	now := time.Now()
+
+	expired := db.Model(&models.QueueJob{}).
+		Where("topic = ? AND status = ? AND visible_at <= ? AND attempts >= ?", r.topic, models.QueueJobStatusPending, now, cfg.MaxDeliveries).
+		Updates(map[string]interface{}{
+			"status":     models.QueueJobStatusDead,
+			"last_error": fmt.Sprintf("задание не подтверждено после %d выдач", cfg.MaxDeliveries),
+		})
+	if expired.Error != nil {
+		return nil, fmt.Errorf("ошибка обработки просроченных заданий: %w", expired.Error)
+	}
+	if expired.RowsAffected > 0 {
+		log.Printf("Заданий топика %s помечено dead после %d выдач: %d", r.topic, cfg.MaxDeliveries, expired.RowsAffected)
+	}
+
+	var jobs []models.QueueJob
+	err := db.Raw(`UPDATE queue_jobs SET attempts = attempts + 1, visible_at = ?, locked_by = ?, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM queue_jobs
+			WHERE topic = ? AND status = ? AND visible_at <= ?
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		now.Add(cfg.VisibilityTimeout), r.id, now,
+		r.topic, models.QueueJobStatusPending, now, cfg.BatchSize,
+	).Scan(&jobs).Error
+	if err != nil {
+		return nil, fmt.Errorf("ошибка захвата заданий очереди: %w", err)
+	}
+
+	// RETURNING не сохраняет порядок подзапроса
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	msgs := make([]kafka.Message, len(jobs))
+	for i, job := range jobs {
+		msgs[i] = kafka.Message{
+			Topic:   job.Topic,
+			Offset:  int64(job.ID),
+			Key:     []byte(job.Key),
+			Value:   job.Payload,
+			Headers: HeadersToKafka(job.Headers),
+			Time:    job.CreatedAt,
+		}
+	}
+	return msgs, nil
+}
+
+// extend продлевает захват еще не выданных заданий, пока предыдущие задания пачки обрабатываются.
+// Задания, захват которых истек и которые уже выданы другому читателю, исключаются из pending,
+// чтобы не обработать их дважды. Вызывается под r.mu.
+func (r *postgresReader) extend(ctx context.Context) error {
+	ids := make([]uint64, len(r.pending))
+	for i, m := range r.pending {
+		ids[i] = uint64(m.Offset)
+	}
+	now := time.Now()
+	var locked []uint64
+	err := r.queue.db.WithContext(ctx).Raw(`UPDATE queue_jobs SET visible_at = ?, updated_at = ?
+		WHERE id IN ? AND locked_by = ? AND status = ?
+		RETURNING id`,
+		now.Add(r.queue.cfg.VisibilityTimeout), now, ids, r.id, models.QueueJobStatusPending,
+	).Scan(&locked).Error
+	if err != nil {
+		return fmt.Errorf("ошибка продления захвата заданий очереди: %w", err)
+	}
+
+	owned := make(map[uint64]bool, len(locked))
+	for _, id := range locked {
+		owned[id] = true
+	}
+	pending := r.pending[:0]
+	for _, m := range r.pending {
+		if owned[uint64(m.Offset)] {
+			pending = append(pending, m)
+		}
+	}
+	if lost := len(r.pending) - len(pending); lost > 0 {
+		log.Printf("Заданий топика %s исключено из захваченной пачки: %d, они выданы повторно после истечения времени обработки", r.topic, lost)
+	}
+	r.pending = pending
+	r.claimedAt = now
+	return nil
+}
+
+// CommitMessages подтверждает обработку: задания удаляются из таблицы. Подтверждаются только переданные
+// задания и только если они все еще захвачены этим читателем: задание, выданное повторно другому читателю
+// после VisibilityTimeout, остается в очереди до подтверждения его новым владельцем.
+func (r *postgresReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	ids := make([]uint64, len(msgs))
+	for i, m := range msgs {
+		ids[i] = uint64(m.Offset)
+	}
+	result := r.queue.db.WithContext(ctx).Where("id IN ? AND locked_by = ?", ids, r.id).Delete(&models.QueueJob{})
+	if result.Error != nil {
+		return fmt.Errorf("ошибка подтверждения заданий очереди: %w", result.Error)
+	}
+	if stale := int64(len(ids)) - result.RowsAffected; stale > 0 {
+		log.Printf("Заданий топика %s не подтверждено: %d, они захвачены повторно после истечения времени обработки", r.topic, stale)
+	}
+	return nil
+}
+
+// acksRecords сообщает, что задания подтверждаются по одному, а не смещением партиции
+func (r *postgresReader) acksRecords() bool {
+	return true
+}
+
+// Close возвращает захваченные, но не выданные задания в очередь без учета выдачи
+func (r *postgresReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if len(r.pending) == 0 {
+		return nil
+	}
+	ids := make([]uint64, len(r.pending))
+	for i, m := range r.pending {
+		ids[i] = uint64(m.Offset)
+	}
+	r.pending = nil
+	return r.queue.db.Model(&models.QueueJob{}).
+		Where("id IN ? AND locked_by = ?", ids, r.id).
+		Updates(map[string]interface{}{
+			"visible_at": time.Now(),
+			"attempts":   gorm.Expr("attempts - 1"),
+		}).Error
+}
+
+// Topic возвращает читаемый топик
+func (r *postgresReader) Topic() string {
+	return r.topic
+}
+
+// GroupID возвращает группу потребителей
+func (r *postgresReader) GroupID() string {
+	return r.groupID
+}
@@ -0,0 +1,216 @@
+// Package services postgres_queue_test.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testQueueTopic возвращает уникальный топик теста и удаляет его задания после завершения
+func testQueueTopic(t *testing.T, queue *PostgresQueue) string {
+	topic := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+	t.Cleanup(func() {
+		queue.db.Where("topic = ?", topic).Delete(&models.QueueJob{})
+	})
+	return topic
+}
+
+// queueJobs возвращает оставшиеся задания топика
+func queueJobs(t *testing.T, queue *PostgresQueue, topic string) []models.QueueJob {
+	t.Helper()
+	var jobs []models.QueueJob
+	if err := queue.db.Where("topic = ?", topic).Order("id").Find(&jobs).Error; err != nil {
+		t.Fatalf("чтение заданий: %v", err)
+	}
+	return jobs
+}
+
+func TestPostgresQueueWorkerPoolAcksEveryJob(t *testing.T) {
+	queue := NewPostgresQueue(testPostgres(t), PostgresQueueConfig{PollInterval: 10 * time.Millisecond, BatchSize: 5})
+	topic := testQueueTopic(t, queue)
+
+	const total = 40
+	var msgs []kafka.Message
+	for i := 0; i < total; i++ {
+		msgs = append(msgs, kafka.Message{Key: []byte(fmt.Sprintf("key-%d", i%4)), Value: []byte(fmt.Sprint(i))})
+	}
+	if err := queue.Publisher(topic).PublishSync(context.Background(), msgs...); err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	var mu sync.Mutex
+	processed := make(map[int64]bool)
+	ctx, cancel := context.WithCancel(context.Background())
+	handle := func(_ context.Context, m kafka.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed[m.Offset] = true
+		if len(processed) == total {
+			cancel()
+		}
+		return nil
+	}
+
+	reader := queue.Subscriber().Subscribe(topic, "group")
+	defer reader.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		newWorkerPool(reader, handle, 4, 10).run(ctx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		cancel()
+		<-done
+		t.Fatalf("обработано заданий: %d из %d", len(processed), total)
+	}
+
+	// Каждое обработанное задание подтверждено, а не только последнее в непрерывной последовательности
+	if jobs := queueJobs(t, queue, topic); len(jobs) != 0 {
+		t.Errorf("в очереди осталось заданий: %d", len(jobs))
+	}
+}
+
+func TestPostgresQueueStaleAckKeepsReclaimedJob(t *testing.T) {
+	queue := NewPostgresQueue(testPostgres(t), PostgresQueueConfig{PollInterval: 10 * time.Millisecond, BatchSize: 1})
+	topic := testQueueTopic(t, queue)
+	if err := queue.Publisher(topic).PublishSync(context.Background(), kafka.Message{Value: []byte("job")}); err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stale := queue.Subscriber().Subscribe(topic, "group")
+	defer stale.Close()
+	m, err := stale.FetchMessage(ctx)
+	if err != nil {
+		t.Fatalf("FetchMessage: %v", err)
+	}
+
+	// Время обработки истекло: задание выдается другому читателю
+	if err := queue.db.Model(&models.QueueJob{}).Where("id = ?", m.Offset).Update("visible_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatalf("сдвиг visible_at: %v", err)
+	}
+	owner := queue.Subscriber().Subscribe(topic, "group")
+	defer owner.Close()
+	reclaimed, err := owner.FetchMessage(ctx)
+	if err != nil {
+		t.Fatalf("FetchMessage: %v", err)
+	}
+	if reclaimed.Offset != m.Offset {
+		t.Fatalf("повторно выдано задание %d, ожидалось %d", reclaimed.Offset, m.Offset)
+	}
+
+	if err := stale.CommitMessages(ctx, m); err != nil {
+		t.Fatalf("CommitMessages: %v", err)
+	}
+	if jobs := queueJobs(t, queue, topic); len(jobs) != 1 {
+		t.Fatalf("подтверждение устаревшего читателя удалило задание нового владельца")
+	}
+
+	if err := owner.CommitMessages(ctx, reclaimed); err != nil {
+		t.Fatalf("CommitMessages: %v", err)
+	}
+	if jobs := queueJobs(t, queue, topic); len(jobs) != 0 {
+		t.Errorf("задание не удалено после подтверждения владельцем")
+	}
+}
+
+func TestPostgresQueueDelaysRetryJobsUntilDue(t *testing.T) {
+	queue := NewPostgresQueue(testPostgres(t), PostgresQueueConfig{PollInterval: 10 * time.Millisecond, VisibilityTimeout: 100 * time.Millisecond})
+	topic := testQueueTopic(t, queue)
+	due := time.Now().Add(500 * time.Millisecond)
+	retry := kafka.Message{
+		Value:   []byte("retry"),
+		Headers: HeadersToKafka(map[string]string{HeaderRetryDueAt: due.UTC().Format(time.RFC3339Nano)}),
+	}
+	if err := queue.Publisher(topic).PublishSync(context.Background(), retry); err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	// Задержка повтора в несколько раз больше VisibilityTimeout: задание не захватывается до срока,
+	// поэтому не выдается повторно другим читателям и не расходует выдачи
+	readers := []MessageReader{queue.Subscriber().Subscribe(topic, "group"), queue.Subscriber().Subscribe(topic, "group")}
+	fetched := make(chan kafka.Message, len(readers))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, reader := range readers {
+		defer reader.Close()
+		go func(reader MessageReader) {
+			if m, err := reader.FetchMessage(ctx); err == nil {
+				fetched <- m
+			}
+		}(reader)
+	}
+
+	m := <-fetched
+	if time.Now().Before(due) {
+		t.Fatalf("задание retry топика выдано до срока %s", due)
+	}
+	select {
+	case dup := <-fetched:
+		t.Fatalf("задание %d выдано второму читателю", dup.Offset)
+	case <-time.After(300 * time.Millisecond):
+	}
+	if jobs := queueJobs(t, queue, topic); len(jobs) != 1 || jobs[0].Attempts != 1 {
+		t.Fatalf("задания после выдачи: %+v, ожидалась одна выдача", jobs)
+	}
+	if err := readers[0].CommitMessages(ctx, m); err != nil {
+		t.Fatalf("CommitMessages: %v", err)
+	}
+	if err := readers[1].CommitMessages(ctx, m); err != nil {
+		t.Fatalf("CommitMessages: %v", err)
+	}
+	if jobs := queueJobs(t, queue, topic); len(jobs) != 0 {
+		t.Errorf("задание не удалено после подтверждения")
+	}
+}
+
+func TestPostgresQueueDropsPendingJobsReclaimedByOtherReader(t *testing.T) {
+	queue := NewPostgresQueue(testPostgres(t), PostgresQueueConfig{PollInterval: 10 * time.Millisecond, BatchSize: 3, VisibilityTimeout: 200 * time.Millisecond})
+	topic := testQueueTopic(t, queue)
+	msgs := []kafka.Message{{Value: []byte("0")}, {Value: []byte("1")}, {Value: []byte("2")}}
+	if err := queue.Publisher(topic).PublishSync(context.Background(), msgs...); err != nil {
+		t.Fatalf("PublishSync: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	slow := queue.Subscriber().Subscribe(topic, "group")
+	defer slow.Close()
+	first, err := slow.FetchMessage(ctx)
+	if err != nil {
+		t.Fatalf("FetchMessage: %v", err)
+	}
+
+	// Обработка первого задания заняла больше половины VisibilityTimeout: захват оставшихся продлевается
+	time.Sleep(150 * time.Millisecond)
+	if err := slow.CommitMessages(ctx, first); err != nil {
+		t.Fatalf("CommitMessages: %v", err)
+	}
+	second, err := slow.FetchMessage(ctx)
+	if err != nil {
+		t.Fatalf("FetchMessage: %v", err)
+	}
+	if err := slow.CommitMessages(ctx, second); err != nil {
+		t.Fatalf("CommitMessages: %v", err)
+	}
+	other := queue.Subscriber().Subscribe(topic, "group")
+	defer other.Close()
+	time.Sleep(100 * time.Millisecond)
+	expectNoMessage(t, other)
+
+	// Захват истек: последнее задание пачки выдается другому читателю и исключается у первого
+	time.Sleep(250 * time.Millisecond)
+	reclaimed := fetchN(t, other, 1)[0]
+	if reclaimed.Offset == first.Offset || reclaimed.Offset == second.Offset {
+		t.Fatalf("повторно выдано уже выданное задание %d", reclaimed.Offset)
+	}
+	expectNoMessage(t, slow)
+}
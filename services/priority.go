@@ -0,0 +1,233 @@
+// Package services priority.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/models"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HeaderPriority заголовок записи с приоритетом сообщения
+const HeaderPriority = "priority"
+
+// ErrInvalidPriority возвращается для неизвестного приоритета сообщения
+var ErrInvalidPriority = errors.New("invalid message priority")
+
+// DefaultPriorityWeights веса приоритетов, если они не заданы в конфигурации
+var DefaultPriorityWeights = map[string]int{
+	models.PriorityHigh:   6,
+	models.PriorityNormal: 3,
+	models.PriorityLow:    1,
+}
+
+// PriorityTopic возвращает топик сообщений приоритета: основной топик для normal и
+// <topic>.<priority> для остальных, например "messages_topic.high"
+func PriorityTopic(topic, priority string) string {
+	if priority == "" || priority == models.PriorityNormal {
+		return topic
+	}
+	return topic + "." + priority
+}
+
+// validatePriority проверяет приоритет сообщения; пустой приоритет означает normal
+func validatePriority(priority string) error {
+	if priority != "" && !slices.Contains(models.MessagePriorities, priority) {
+		return fmt.Errorf("%w: %q", ErrInvalidPriority, priority)
+	}
+	return nil
+}
+
+// priorityFetch результат чтения записи из топика приоритета
+type priorityFetch struct {
+	msg kafka.Message
+	err error
+}
+
+// prioritySource топик приоритета в составе priorityReader
+type prioritySource struct {
+	priority string
+	weight   int
+	current  int // Текущий вес алгоритма smooth weighted round-robin
+	reader   MessageReader
+	fetched  chan priorityFetch // Записи, прочитанные заранее, не больше weight
+}
+
+// priorityReader объединяет топики приоритетов в один MessageReader. Каждый топик читается в фоне
+// своей группой, а FetchMessage выбирает запись среди топиков, в которых есть прочитанные записи,
+// по алгоритму smooth weighted round-robin (как в nginx). При очереди во всех топиках записи выдаются
+// пропорционально весам, а если очередь есть только в одном топике, он читается без ограничений.
+//
+// Порядок записей внутри топика сохраняется. Записи, прочитанные заранее и не выданные до Close,
+// не фиксируются и будут прочитаны повторно.
+type priorityReader struct {
+	topic   string
+	groupID string
+	sources []*prioritySource
+	byTopic map[string]*prioritySource
+
+	mu     sync.Mutex // Выбор источника
+	notify chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// newPriorityReader подписывается на топики приоритетов subs и запускает их фоновое чтение.
+// Topic и GroupID объединенного reader берутся из подписки приоритета normal.
+func newPriorityReader(subscriber Subscriber, subs []Subscription) *priorityReader {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &priorityReader{
+		byTopic: make(map[string]*prioritySource, len(subs)),
+		notify:  make(chan struct{}, 1),
+		cancel:  cancel,
+	}
+	for _, sub := range subs {
+		weight := max(sub.Weight, 1)
+		source := &prioritySource{
+			priority: sub.Priority,
+			weight:   weight,
+			reader:   subscriber.Subscribe(sub.Topic, sub.GroupID),
+			fetched:  make(chan priorityFetch, weight),
+		}
+		r.sources = append(r.sources, source)
+		r.byTopic[sub.Topic] = source
+		if r.topic == "" || sub.Priority == models.PriorityNormal {
+			r.topic, r.groupID = sub.Topic, sub.GroupID
+		}
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.prefetch(ctx, source)
+		}()
+	}
+	return r
+}
+
+// prefetch читает записи топика приоритета до завершения ctx
+func (r *priorityReader) prefetch(ctx context.Context, source *prioritySource) {
+	for {
+		m, err := source.reader.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case source.fetched <- priorityFetch{msg: m, err: err}:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case r.notify <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			log.Printf("Не удалось прочитать сообщение топика приоритета %s: %v", source.priority, err)
+			if err := sleepContext(ctx, 5*time.Second); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// FetchMessage возвращает следующую запись, выбранную по весам приоритетов, ожидая ее появления
+func (r *priorityReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		if fetch, ok := r.next(); ok {
+			return fetch.msg, fetch.err
+		}
+		select {
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		case <-r.notify:
+		}
+	}
+}
+
+// next выбирает топик с прочитанной записью с наибольшим текущим весом. Выбранный топик уменьшает
+// текущий вес на сумму весов готовых топиков, остальные увеличивают его на свой вес.
+func (r *priorityReader) next() (priorityFetch, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var best *prioritySource
+	total := 0
+	for _, source := range r.sources {
+		if len(source.fetched) == 0 {
+			continue
+		}
+		source.current += source.weight
+		total += source.weight
+		if best == nil || source.current > best.current {
+			best = source
+		}
+	}
+	if best == nil {
+		return priorityFetch{}, false
+	}
+	best.current -= total
+	return <-best.fetched, true
+}
+
+// CommitMessages фиксирует записи в топиках приоритетов, из которых они прочитаны
+func (r *priorityReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	byReader := make(map[*prioritySource][]kafka.Message)
+	for _, m := range msgs {
+		source, ok := r.byTopic[m.Topic]
+		if !ok {
+			return fmt.Errorf("запись топика %s не относится к топикам приоритетов", m.Topic)
+		}
+		byReader[source] = append(byReader[source], m)
+	}
+	for _, source := range r.sources {
+		if batch := byReader[source]; len(batch) > 0 {
+			if err := source.reader.CommitMessages(ctx, batch...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// acksRecords сообщает, подтверждают ли readers топиков приоритетов каждую запись отдельно
+func (r *priorityReader) acksRecords() bool {
+	return len(r.sources) > 0 && acksRecords(r.sources[0].reader)
+}
+
+// Close останавливает фоновое чтение и закрывает readers топиков приоритетов
+func (r *priorityReader) Close() error {
+	var errs []error
+	r.once.Do(func() {
+		r.cancel()
+		r.wg.Wait()
+		for _, source := range r.sources {
+			if err := source.reader.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// Topic возвращает основной топик
+func (r *priorityReader) Topic() string {
+	return r.topic
+}
+
+// GroupID возвращает группу потребителей основного топика
+func (r *priorityReader) GroupID() string {
+	return r.groupID
+}
+
+// groupFor возвращает группу потребителей топика приоритета
+func (r *priorityReader) groupFor(topic string) string {
+	if source, ok := r.byTopic[topic]; ok {
+		return source.reader.GroupID()
+	}
+	return r.groupID
+}
@@ -0,0 +1,128 @@
+// Package services priority_test.go
+package services
+
+import (
+	"context"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/models"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// prioritySubscriptions топики приоритетов "orders" с весами 6, 3 и 1
+var prioritySubscriptions = []Subscription{
+	{Topic: "orders.high", GroupID: "group.high", Priority: models.PriorityHigh, Weight: 6},
+	{Topic: "orders", GroupID: "group", Priority: models.PriorityNormal, Weight: 3},
+	{Topic: "orders.low", GroupID: "group.low", Priority: models.PriorityLow, Weight: 1},
+}
+
+// publishN публикует в топик n записей со значениями 0..n-1
+func publishN(t *testing.T, broker *MemoryBroker, topic string, n int) {
+	t.Helper()
+	msgs := make([]kafka.Message, n)
+	for i := range msgs {
+		msgs[i] = kafka.Message{Topic: topic, Value: []byte(strconv.Itoa(i))}
+	}
+	if err := broker.Publisher(ProducerConfig{Topic: topic}).PublishSync(context.Background(), msgs...); err != nil {
+		t.Fatalf("PublishSync %s: %v", topic, err)
+	}
+}
+
+// waitPrefetched ждет, пока каждый топик приоритета прочитает заранее столько записей, сколько позволяет его вес
+func waitPrefetched(t *testing.T, r *priorityReader) {
+	t.Helper()
+	waitFor(t, time.Second, "чтение топиков приоритетов", func() bool {
+		for _, source := range r.sources {
+			if len(source.fetched) < cap(source.fetched) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestPriorityReaderInterleavesByWeight(t *testing.T) {
+	broker := NewMemoryBroker(MemoryBrokerConfig{Partitions: 1})
+	for _, sub := range prioritySubscriptions {
+		publishN(t, broker, sub.Topic, 20)
+	}
+	reader := newPriorityReader(broker.Subscriber(ConsumerConfig{}), prioritySubscriptions)
+	defer reader.Close()
+	waitPrefetched(t, reader)
+
+	// При очереди во всех топиках записи выдаются пропорционально весам, порядок внутри топика сохраняется
+	counts := make(map[string]int)
+	next := make(map[string]int64)
+	for _, m := range fetchN(t, reader, 10) {
+		counts[m.Topic]++
+		if m.Offset != next[m.Topic] {
+			t.Errorf("запись %s/%d выдана вместо смещения %d", m.Topic, m.Offset, next[m.Topic])
+		}
+		next[m.Topic] = m.Offset + 1
+	}
+	if counts["orders.high"] != 6 || counts["orders"] != 3 || counts["orders.low"] != 1 {
+		t.Errorf("записей по топикам из 10: %v, ожидалось high=6, normal=3, low=1", counts)
+	}
+
+	if reader.Topic() != "orders" || reader.GroupID() != "group" || reader.groupFor("orders.low") != "group.low" {
+		t.Errorf("топик %s, группа %s, группа low %s", reader.Topic(), reader.GroupID(), reader.groupFor("orders.low"))
+	}
+}
+
+func TestPriorityReaderReadsSingleBacklogWithoutLimit(t *testing.T) {
+	broker := NewMemoryBroker(MemoryBrokerConfig{Partitions: 1})
+	publishN(t, broker, "orders.low", 5)
+	reader := newPriorityReader(broker.Subscriber(ConsumerConfig{}), prioritySubscriptions)
+	defer reader.Close()
+
+	// Пустые топики высокого приоритета не задерживают записи низкого
+	for i, m := range fetchN(t, reader, 5) {
+		if m.Topic != "orders.low" || m.Offset != int64(i) {
+			t.Errorf("запись %d: %s/%d", i, m.Topic, m.Offset)
+		}
+	}
+	expectNoMessage(t, reader)
+}
+
+func TestPriorityReaderCommitsPerTopic(t *testing.T) {
+	broker := NewMemoryBroker(MemoryBrokerConfig{Partitions: 1})
+	for _, sub := range prioritySubscriptions {
+		publishN(t, broker, sub.Topic, 2)
+	}
+	reader := newPriorityReader(broker.Subscriber(ConsumerConfig{}), prioritySubscriptions)
+	defer reader.Close()
+
+	msgs := fetchN(t, reader, 6)
+	var commit []kafka.Message
+	for _, m := range msgs {
+		// Вторая запись low остается незафиксированной
+		if m.Topic != "orders.low" || m.Offset == 0 {
+			commit = append(commit, m)
+		}
+	}
+	if err := reader.CommitMessages(context.Background(), commit...); err != nil {
+		t.Fatalf("CommitMessages: %v", err)
+	}
+
+	// Смещение фиксируется в группе топика, из которого прочитана запись
+	for _, want := range []struct {
+		group, topic string
+		offset       int64
+	}{
+		{"group.high", "orders.high", 2},
+		{"group", "orders", 2},
+		{"group.low", "orders.low", 1},
+	} {
+		if committed := broker.Committed(want.group, want.topic)[0]; committed != want.offset {
+			t.Errorf("%s/%s: зафиксировано %d, ожидалось %d", want.group, want.topic, committed, want.offset)
+		}
+	}
+	if committed := broker.Committed("group", "orders.high"); len(committed) != 0 {
+		t.Errorf("записи high зафиксированы в группе основного топика: %v", committed)
+	}
+
+	if err := reader.CommitMessages(context.Background(), kafka.Message{Topic: "payments"}); err == nil {
+		t.Error("фиксация записи чужого топика завершилась без ошибки")
+	}
+}
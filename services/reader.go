@@ -0,0 +1,198 @@
+// Package services reader.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/config"
+	"go_microsvc/models"
+	"strings"
+	"time"
+)
+
+// Начальное смещение для группы без зафиксированных смещений
+const (
+	StartOffsetFirst = "first" // С начала партиции
+	StartOffsetLast  = "last"  // Только новые записи
+)
+
+// Уровни изоляции чтения
+const (
+	IsolationReadUncommitted = "read_uncommitted"
+	IsolationReadCommitted   = "read_committed"
+)
+
+// ConsumerConfig описывает параметры Kafka consumer
+type ConsumerConfig struct {
+	Brokers      string        // Адреса брокеров Kafka через запятую
+	Topic        string        // Топик для чтения
+	RetryBackoff time.Duration // Задержка между попытками публикации в retry и DLQ топики
+	Workers      int           // Количество параллельных обработчиков основного топика
+	QueueSize    int           // Размер очереди каждого обработчика
+	BatchSize    int           // Размер пачки; больше 1 включает пакетный режим основного топика
+	BatchLinger  time.Duration // Максимальное время ожидания пачки после первой записи
+
+	// Веса приоритетов при чтении топиков приоритетов; не заданные веса берутся из DefaultPriorityWeights
+	PriorityWeights map[string]int
+
+	// Параметры обработчиков сообщений
+	UnknownType         string        // dlq, skip или тип обработчика для сообщений неизвестного типа
+	HandlerRetries      int           // Повторные попытки обработчика до отправки в retry топик
+	HandlerRetryBackoff time.Duration // Задержка между повторными попытками обработчика
+
+	// Параметры kafka.ReaderConfig
+	GroupID           string        // Группа потребителей; retry топики читаются группами <GroupID>[.<priority>].retry.<tier>
+	StartOffset       string        // first или last
+	MinBytes          int           // Минимальный объем ответа fetch
+	MaxBytes          int           // Максимальный объем ответа fetch
+	MaxWait           time.Duration // Максимальное ожидание MinBytes
+	CommitInterval    time.Duration // 0 — синхронная фиксация смещений, иначе периодическая
+	SessionTimeout    time.Duration // Таймаут сессии участника группы
+	HeartbeatInterval time.Duration // Интервал heartbeat
+	RebalanceTimeout  time.Duration // Время на завершение перебалансировки
+	IsolationLevel    string        // read_uncommitted или read_committed
+}
+
+// NewConsumerConfig формирует параметры consumer из конфигурации приложения
+func NewConsumerConfig(cfg config.Config) ConsumerConfig {
+	return ConsumerConfig{
+		Brokers:      cfg.KafkaBootstrapServers,
+		Topic:        cfg.KafkaTopic,
+		RetryBackoff: cfg.ConsumerRetryBackoff,
+		Workers:      cfg.ConsumerWorkers,
+		QueueSize:    cfg.ConsumerQueueSize,
+		BatchSize:    cfg.ConsumerBatchSize,
+		BatchLinger:  cfg.ConsumerBatchLinger,
+
+		PriorityWeights: cfg.ConsumerPriorityWeights,
+
+		UnknownType:         cfg.ConsumerUnknownType,
+		HandlerRetries:      cfg.ConsumerHandlerRetries,
+		HandlerRetryBackoff: cfg.ConsumerHandlerRetryBackoff,
+
+		GroupID:           cfg.ConsumerGroupID,
+		StartOffset:       cfg.ConsumerStartOffset,
+		MinBytes:          cfg.ConsumerMinBytes,
+		MaxBytes:          cfg.ConsumerMaxBytes,
+		MaxWait:           cfg.ConsumerMaxWait,
+		CommitInterval:    cfg.ConsumerCommitInterval,
+		SessionTimeout:    cfg.ConsumerSessionTimeout,
+		HeartbeatInterval: cfg.ConsumerHeartbeatInterval,
+		RebalanceTimeout:  cfg.ConsumerRebalanceTimeout,
+		IsolationLevel:    cfg.ConsumerIsolationLevel,
+	}
+}
+
+// Validate проверяет значения параметров, заданных строками
+func (c ConsumerConfig) Validate() error {
+	if _, err := parseStartOffset(c.StartOffset); err != nil {
+		return err
+	}
+	if _, err := parseIsolationLevel(c.IsolationLevel); err != nil {
+		return err
+	}
+	if c.GroupID == "" {
+		return fmt.Errorf("не задана группа потребителей")
+	}
+	return nil
+}
+
+// ReaderConfig формирует kafka.ReaderConfig для чтения топика в составе группы.
+// Нулевые значения параметров заменяются значениями по умолчанию kafka-go.
+func (c ConsumerConfig) ReaderConfig(topic, groupID string) kafka.ReaderConfig {
+	startOffset, _ := parseStartOffset(c.StartOffset)
+	isolation, _ := parseIsolationLevel(c.IsolationLevel)
+
+	return kafka.ReaderConfig{
+		Brokers:           strings.Split(c.Brokers, ","),
+		Topic:             topic,
+		GroupID:           groupID,
+		StartOffset:       startOffset,
+		MinBytes:          c.MinBytes,
+		MaxBytes:          c.MaxBytes,
+		MaxWait:           c.MaxWait,
+		CommitInterval:    c.CommitInterval,
+		SessionTimeout:    c.SessionTimeout,
+		HeartbeatInterval: c.HeartbeatInterval,
+		RebalanceTimeout:  c.RebalanceTimeout,
+		IsolationLevel:    isolation,
+	}
+}
+
+// parseStartOffset преобразует first/last в значение kafka-go
+func parseStartOffset(value string) (int64, error) {
+	switch value {
+	case "", StartOffsetFirst:
+		return kafka.FirstOffset, nil
+	case StartOffsetLast:
+		return kafka.LastOffset, nil
+	default:
+		return 0, fmt.Errorf("неизвестное начальное смещение: %q", value)
+	}
+}
+
+// parseIsolationLevel преобразует имя уровня изоляции в значение kafka-go
+func parseIsolationLevel(value string) (kafka.IsolationLevel, error) {
+	switch value {
+	case "", IsolationReadUncommitted:
+		return kafka.ReadUncommitted, nil
+	case IsolationReadCommitted:
+		return kafka.ReadCommitted, nil
+	default:
+		return 0, fmt.Errorf("неизвестный уровень изоляции: %q", value)
+	}
+}
+
+// Subscription описывает топик, читаемый consumer в составе группы
+type Subscription struct {
+	Topic    string
+	GroupID  string
+	Delayed  bool   // Retry топик: обработка откладывается до retry-due-at
+	Priority string // Топик приоритета: топики приоритетов читаются вместе с учетом Weight
+	Weight   int
+}
+
+// Subscriptions возвращает топики приоритетов и их retry топики с группами потребителей.
+// Основной топик (приоритет normal) читается группой GroupID, топики остальных приоритетов — группами
+// <GroupID>.<priority>. Каждый топик приоритета повторяется через свои retry топики <topic>.retry.<tier>
+// по политике основного топика, которые читаются группами <группа топика>.retry.<tier>.
+func Subscriptions(cfg ConsumerConfig, retries *RetryRouter) []Subscription {
+	var subs, retrySubs []Subscription
+	tiers := retries.Policy(cfg.Topic).Tiers
+	for _, priority := range models.MessagePriorities {
+		sub := Subscription{Topic: PriorityTopic(cfg.Topic, priority), GroupID: cfg.GroupID, Priority: priority}
+		if priority != models.PriorityNormal {
+			sub.GroupID += "." + priority
+		}
+		sub.Weight = cfg.PriorityWeights[priority]
+		if sub.Weight <= 0 {
+			sub.Weight = DefaultPriorityWeights[priority]
+		}
+		subs = append(subs, sub)
+
+		for _, tier := range tiers {
+			retrySubs = append(retrySubs, Subscription{
+				Topic:   RetryTopicName(sub.Topic, tier),
+				GroupID: sub.GroupID + ".retry." + formatTier(tier),
+				Delayed: true,
+			})
+		}
+	}
+	return append(subs, retrySubs...)
+}
+
+// commitMessages фиксирует смещения записей и запоминает время обработки партиций для мониторинга
+func commitMessages(ctx context.Context, reader MessageReader, msgs ...kafka.Message) error {
+	if err := reader.CommitMessages(ctx, msgs...); err != nil {
+		return err
+	}
+	groupFor := func(string) string { return reader.GroupID() }
+	if r, ok := reader.(*priorityReader); ok {
+		groupFor = r.groupFor
+	}
+	for _, m := range msgs {
+		progress.record(groupFor(m.Topic), m.Topic, m.Partition, m.Offset)
+	}
+	return nil
+}
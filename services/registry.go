@@ -0,0 +1,173 @@
+// Package services registry.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// Обработка сообщений неизвестного типа (ConsumerConfig.UnknownType). Любое другое значение
+// считается типом обработчика, которому передаются такие сообщения.
+const (
+	UnknownTypeDLQ  = "dlq"  // Отправить в DLQ
+	UnknownTypeSkip = "skip" // Пропустить и зафиксировать смещение
+)
+
+// ErrUnknownMessageType возвращается, если для типа сообщения нет обработчика и запасного обработчика
+var ErrUnknownMessageType = errors.New("неизвестный тип сообщения")
+
+// Handler обрабатывает сообщение одного типа. Ошибка означает, что сообщение не обработано
+// и будет повторено через retry топики.
+type Handler interface {
+	Handle(ctx context.Context, env Envelope) error
+}
+
+// HandlerFunc позволяет использовать функцию как Handler
+type HandlerFunc func(ctx context.Context, env Envelope) error
+
+// Handle вызывает f(ctx, env)
+func (f HandlerFunc) Handle(ctx context.Context, env Envelope) error {
+	return f(ctx, env)
+}
+
+// Middleware оборачивает обработчик: логирование, метрики, повторы, перехват паники
+type Middleware func(next Handler) Handler
+
+// HandlerRegistry выбирает обработчик по типу сообщения и оборачивает его middleware.
+// Регистрировать обработчики следует до запуска consumer.
+type HandlerRegistry struct {
+	db          *database.Database
+	mu          sync.RWMutex
+	handlers    map[string]Handler
+	middleware  []Middleware
+	unknownType string
+	builtin     bool // Тип MessageTypeDefault обрабатывается встроенным обработчиком
+}
+
+// NewHandlerRegistry создает реестр со встроенным обработчиком сообщений MessageTypeDefault
+// и стандартными middleware: логирование, метрики, повторы, история попыток и перехват паники
+func NewHandlerRegistry(db *database.Database, cfg ConsumerConfig) *HandlerRegistry {
+	r := &HandlerRegistry{
+		db:          db,
+		handlers:    make(map[string]Handler),
+		unknownType: cfg.UnknownType,
+	}
+	if r.unknownType == "" {
+		r.unknownType = UnknownTypeDLQ
+	}
+	r.handlers[MessageTypeDefault] = MessageHandler(db)
+	r.builtin = true
+
+	// Первый middleware выполняется первым: повторы охватывают перехват паники, история попыток
+	// сохраняет каждый повтор, а логирование и метрики видят итоговый результат всех попыток
+	r.Use(
+		LoggingMiddleware(),
+		MetricsMiddleware(),
+		RetryMiddleware(cfg.HandlerRetries, cfg.HandlerRetryBackoff),
+		AttemptsMiddleware(NewAttemptLog(db)),
+		RecoverMiddleware(),
+	)
+	return r
+}
+
+// Register регистрирует обработчик для типа сообщения, заменяя ранее зарегистрированный
+func (r *HandlerRegistry) Register(messageType string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[messageType] = h
+	if messageType == MessageTypeDefault {
+		r.builtin = false
+	}
+}
+
+// Use добавляет middleware, которые оборачивают все обработчики. Middleware, добавленные раньше,
+// выполняются раньше.
+func (r *HandlerRegistry) Use(middleware ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Types возвращает зарегистрированные типы сообщений
+func (r *HandlerRegistry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.handlers))
+	for messageType := range r.handlers {
+		types = append(types, messageType)
+	}
+	return types
+}
+
+// Handle передает сообщение обработчику его типа. Сообщения неизвестного типа передаются запасному
+// обработчику, пропускаются или завершаются ErrUnknownMessageType в зависимости от UnknownType.
+func (r *HandlerRegistry) Handle(ctx context.Context, env Envelope) error {
+	h, err := r.lookup(env.Type)
+	if err != nil {
+		return err
+	}
+	return h.Handle(ctx, env)
+}
+
+// lookup возвращает обработчик типа, обернутый middleware
+func (r *HandlerRegistry) lookup(messageType string) (Handler, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	h, ok := r.handlers[messageType]
+	if !ok {
+		switch r.unknownType {
+		case UnknownTypeDLQ:
+			return nil, fmt.Errorf("%w: %q", ErrUnknownMessageType, messageType)
+		case UnknownTypeSkip:
+			h = HandlerFunc(func(ctx context.Context, env Envelope) error {
+				log.Printf("Сообщение неизвестного типа %q пропущено: %s/%d/%d", env.Type, env.Topic, env.Partition, env.Offset)
+				return nil
+			})
+		default:
+			if h, ok = r.handlers[r.unknownType]; !ok {
+				return nil, fmt.Errorf("%w: %q, запасной обработчик %q не зарегистрирован", ErrUnknownMessageType, messageType, r.unknownType)
+			}
+		}
+	}
+
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	return h, nil
+}
+
+// expire пропускает запись с истекшим сроком действия: обработчик не вызывается, а сообщение
+// переводится в статус expired. Ошибка перехода только логируется, запись в любом случае не обрабатывается.
+func (r *HandlerRegistry) expire(ctx context.Context, env Envelope) {
+	metrics.recordsExpired.Add(1)
+	log.Printf("Сообщение типа %s (%s/%d/%d, message-id=%d) пропущено: срок действия истек %s",
+		env.Type, env.Topic, env.Partition, env.Offset, env.MessageID, env.ExpiresAt.Format(time.RFC3339))
+	if env.Type != MessageTypeDefault || env.MessageID == 0 {
+		return
+	}
+	if err := TransitionMessage(ctx, r.db.DB, env.MessageID, models.MessageStatusExpired, nil); err != nil {
+		log.Printf("Статус сообщения %d не изменен на %s: %v", env.MessageID, models.MessageStatusExpired, err)
+	}
+}
+
+// bulkUpsert сообщает, можно ли сохранять сообщения типа пачкой вместо вызова обработчика:
+// только для MessageTypeDefault со встроенным обработчиком
+func (r *HandlerRegistry) bulkUpsert(messageType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return messageType == MessageTypeDefault && r.builtin
+}
+
+// MessageHandler встроенный обработчик сообщений MessageTypeDefault: отмечает сообщение обработанным
+func MessageHandler(db *database.Database) Handler {
+	return HandlerFunc(func(ctx context.Context, env Envelope) error {
+		return processMessage(ctx, db, env)
+	})
+}
@@ -0,0 +1,116 @@
+// Package services registry_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"go_microsvc/models"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryRetriesRecoveredPanicsAndRecordsEachAttempt(t *testing.T) {
+	db := testDatabase(t)
+	msg := createMessageWithStatus(t, db, models.MessageStatusPublished)
+	registry := NewHandlerRegistry(db, ConsumerConfig{HandlerRetries: 2, HandlerRetryBackoff: time.Millisecond})
+
+	calls := 0
+	registry.Register("test.panic", HandlerFunc(func(context.Context, Envelope) error {
+		calls++
+		if calls < 3 {
+			panic("сбой обработчика")
+		}
+		return nil
+	}))
+
+	// RetryMiddleware охватывает RecoverMiddleware: паника превращается в ошибку и повторяется на месте,
+	// а AttemptsMiddleware между ними сохраняет каждый вызов
+	env := Envelope{Type: "test.panic", MessageID: msg.ID, Topic: "orders", Offset: 7}
+	if err := registry.Handle(context.Background(), env); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("вызовов обработчика: %d, ожидалось 3", calls)
+	}
+
+	var attempts []models.MessageAttempt
+	db.Where("message_id = ?", msg.ID).Order("id").Find(&attempts)
+	if len(attempts) != 3 {
+		t.Fatalf("попыток в истории: %d, ожидалось 3", len(attempts))
+	}
+	for i, attempt := range attempts[:2] {
+		if attempt.Outcome != models.AttemptFailed || !strings.Contains(attempt.Error, "паника в обработчике: сбой обработчика") {
+			t.Errorf("попытка %d: %s %q", i, attempt.Outcome, attempt.Error)
+		}
+	}
+	if last := attempts[2]; last.Outcome != models.AttemptSucceeded || last.Topic != "orders" || last.Offset != 7 {
+		t.Errorf("последняя попытка: %+v", last)
+	}
+}
+
+func TestRegistryReturnsErrorAfterRetries(t *testing.T) {
+	db := offlineDatabase(t)
+	registry := NewHandlerRegistry(db, ConsumerConfig{HandlerRetries: 1, HandlerRetryBackoff: time.Millisecond})
+	cause := errors.New("сбой обработчика")
+	calls := 0
+	registry.Register("test.fail", HandlerFunc(func(context.Context, Envelope) error {
+		calls++
+		return cause
+	}))
+
+	if err := registry.Handle(context.Background(), Envelope{Type: "test.fail"}); !errors.Is(err, cause) || calls != 2 {
+		t.Errorf("Handle: %v после %d вызовов, ожидалась ошибка обработчика после 2", err, calls)
+	}
+}
+
+func TestRegistryMiddlewareOrder(t *testing.T) {
+	registry := NewHandlerRegistry(offlineDatabase(t), ConsumerConfig{})
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, env Envelope) error {
+				order = append(order, name+":before")
+				err := next.Handle(ctx, env)
+				order = append(order, name+":after")
+				return err
+			})
+		}
+	}
+	registry.Use(trace("first"), trace("second"))
+	registry.Register("test.ok", HandlerFunc(func(context.Context, Envelope) error {
+		order = append(order, "handler")
+		return nil
+	}))
+
+	if err := registry.Handle(context.Background(), Envelope{Type: "test.ok"}); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	want := []string{"first:before", "second:before", "handler", "second:after", "first:after"}
+	if !slices.Equal(order, want) {
+		t.Errorf("порядок вызовов %v, ожидался %v", order, want)
+	}
+}
+
+func TestRegistryUnknownType(t *testing.T) {
+	db := offlineDatabase(t)
+	fallback := func(context.Context, Envelope) error { return errors.New("запасной обработчик") }
+	tests := []struct {
+		unknownType string
+		check       func(err error) bool
+	}{
+		{"", func(err error) bool { return errors.Is(err, ErrUnknownMessageType) }},
+		{UnknownTypeDLQ, func(err error) bool { return errors.Is(err, ErrUnknownMessageType) }},
+		{UnknownTypeSkip, func(err error) bool { return err == nil }},
+		{"test.fallback", func(err error) bool { return err != nil && err.Error() == "запасной обработчик" }},
+		{"test.missing", func(err error) bool { return errors.Is(err, ErrUnknownMessageType) }},
+	}
+	for _, tt := range tests {
+		registry := NewHandlerRegistry(db, ConsumerConfig{UnknownType: tt.unknownType})
+		registry.Register("test.fallback", HandlerFunc(fallback))
+		if err := registry.Handle(context.Background(), Envelope{Type: "test.unknown"}); !tt.check(err) {
+			t.Errorf("UnknownType %q: %v", tt.unknownType, err)
+		}
+	}
+}
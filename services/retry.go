@@ -0,0 +1,176 @@
+// Package services retry.go
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/config"
+	"go_microsvc/models"
+	"log"
+	"strconv"
+	"time"
+)
+
+// Заголовки, добавляемые к записи при отправке в retry топик
+const (
+	HeaderRetryAttempt           = "retry-attempt"            // Количество неудачных попыток обработки
+	HeaderRetryDueAt             = "retry-due-at"             // Время, раньше которого запись не обрабатывается (RFC 3339)
+	HeaderRetryOriginalTopic     = "retry-original-topic"     // Исходный топик записи
+	HeaderRetryOriginalPartition = "retry-original-partition" // Партиция записи в исходном топике
+	HeaderRetryOriginalOffset    = "retry-original-offset"    // Смещение записи в исходном топике
+	HeaderRetryError             = "retry-error"              // Текст последней ошибки
+)
+
+// RetryRouter перенаправляет записи, обработка которых завершилась ошибкой, в retry топик
+// следующего уровня, а после исчерпания попыток — в DLQ. Исходная партиция при этом не блокируется.
+type RetryRouter struct {
+	producer Publisher
+	dlq      *DeadLetterQueue
+	policies map[string]config.RetryPolicy
+	def      config.RetryPolicy
+	bases    map[string]string // Топики приоритетов и основной топик, политика которого к ним применяется
+}
+
+// NewRetryRouter создает маршрутизатор повторной обработки с политикой по умолчанию
+// и политиками для отдельных топиков
+func NewRetryRouter(producer Publisher, dlq *DeadLetterQueue, def config.RetryPolicy, policies map[string]config.RetryPolicy) *RetryRouter {
+	return &RetryRouter{producer: producer, dlq: dlq, policies: policies, def: def}
+}
+
+// NewRetryRouterFromConfig создает маршрутизатор с политиками из конфигурации приложения
+func NewRetryRouterFromConfig(producer Publisher, dlq *DeadLetterQueue, cfg config.Config) *RetryRouter {
+	router := NewRetryRouter(producer, dlq, cfg.RetryDefault, cfg.RetryPolicies)
+	router.PriorityTopics(cfg.KafkaTopic)
+	return router
+}
+
+// PriorityTopics задает повтор записей топиков приоритетов topic по политике topic. Каждый приоритет
+// повторяется через свои retry топики, например "messages_topic.high.retry.1m", поэтому повторы
+// записей низкого приоритета не задерживают повторы высокого. Исходным топиком записи остается
+// топик приоритета, поэтому после возврата из DLQ приоритет также сохраняется.
+func (r *RetryRouter) PriorityTopics(topic string) {
+	if r.bases == nil {
+		r.bases = make(map[string]string)
+	}
+	for _, priority := range models.MessagePriorities {
+		if priorityTopic := PriorityTopic(topic, priority); priorityTopic != topic {
+			r.bases[priorityTopic] = topic
+		}
+	}
+}
+
+// baseTopic возвращает топик, политика которого используется для записей topic
+func (r *RetryRouter) baseTopic(topic string) string {
+	if base, ok := r.bases[topic]; ok {
+		return base
+	}
+	return topic
+}
+
+// Policy возвращает политику повторной обработки для исходного топика
+func (r *RetryRouter) Policy(topic string) config.RetryPolicy {
+	if policy, ok := r.policies[topic]; ok {
+		return policy
+	}
+	return r.def
+}
+
+// DeadLetter отправляет запись непосредственно в DLQ
+func (r *RetryRouter) DeadLetter(ctx context.Context, m kafka.Message, reason string, cause error) error {
+	return r.dlq.Send(ctx, m, reason, cause, RetryAttempt(m)+1)
+}
+
+// Retry отправляет запись в retry топик следующего уровня или в DLQ, если попытки исчерпаны
+func (r *RetryRouter) Retry(ctx context.Context, m kafka.Message, cause error) error {
+	headers := HeadersFromKafka(m.Headers)
+	originalTopic, originalPartition, originalOffset := RecordOrigin(m)
+
+	policy := r.Policy(r.baseTopic(originalTopic))
+	attempts := RetryAttempt(m) + 1
+	if attempts >= policy.MaxAttempts || len(policy.Tiers) == 0 {
+		return r.dlq.Send(ctx, m, DLQReasonProcessing, cause, attempts)
+	}
+
+	// Если попыток больше, чем уровней, повторно используется последний уровень
+	tier := policy.Tiers[len(policy.Tiers)-1]
+	if attempts <= len(policy.Tiers) {
+		tier = policy.Tiers[attempts-1]
+	}
+
+	headers[HeaderRetryAttempt] = strconv.Itoa(attempts)
+	headers[HeaderRetryDueAt] = time.Now().Add(tier).UTC().Format(time.RFC3339Nano)
+	headers[HeaderRetryOriginalTopic] = originalTopic
+	headers[HeaderRetryOriginalPartition] = strconv.Itoa(originalPartition)
+	headers[HeaderRetryOriginalOffset] = strconv.FormatInt(originalOffset, 10)
+	headers[HeaderRetryError] = cause.Error()
+
+	retryTopic := RetryTopicName(originalTopic, tier)
+	err := r.producer.PublishSync(ctx, kafka.Message{
+		Topic:   retryTopic,
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: HeadersToKafka(headers),
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Запись %s/%d/%d отправлена в %s (попытка %d из %d): %v",
+		m.Topic, m.Partition, m.Offset, retryTopic, attempts, policy.MaxAttempts, cause)
+	recordMessageStatus(ctx, r.dlq.db.DB, m, models.MessageStatusFailed, cause)
+	return nil
+}
+
+// RetryAttempt возвращает количество неудачных попыток обработки записи из заголовка retry-attempt
+func RetryAttempt(m kafka.Message) int {
+	for i := len(m.Headers) - 1; i >= 0; i-- {
+		if m.Headers[i].Key == HeaderRetryAttempt {
+			n, _ := strconv.Atoi(string(m.Headers[i].Value))
+			return n
+		}
+	}
+	return 0
+}
+
+// RecordOrigin возвращает топик, партицию и смещение, под которыми запись была прочитана впервые.
+// Для записи из retry топика это значения из заголовков retry-original-*, для остальных — значения самой записи.
+func RecordOrigin(m kafka.Message) (topic string, partition int, offset int64) {
+	headers := HeadersFromKafka(m.Headers)
+	topic = headers[HeaderRetryOriginalTopic]
+	if topic == "" {
+		return m.Topic, m.Partition, m.Offset
+	}
+	partition, _ = strconv.Atoi(headers[HeaderRetryOriginalPartition])
+	offset, _ = strconv.ParseInt(headers[HeaderRetryOriginalOffset], 10, 64)
+	return topic, partition, offset
+}
+
+// RetryDueAt возвращает время, раньше которого запись из retry топика не должна обрабатываться
+func RetryDueAt(m kafka.Message) time.Time {
+	for i := len(m.Headers) - 1; i >= 0; i-- {
+		if m.Headers[i].Key == HeaderRetryDueAt {
+			dueAt, _ := time.Parse(time.RFC3339Nano, string(m.Headers[i].Value))
+			return dueAt
+		}
+	}
+	return time.Time{}
+}
+
+// RetryTopicName возвращает имя retry топика уровня, например "messages_topic.retry.10m"
+func RetryTopicName(topic string, tier time.Duration) string {
+	return topic + ".retry." + formatTier(tier)
+}
+
+// formatTier форматирует задержку уровня в короткий вид: 30s, 10m, 1h
+func formatTier(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d%time.Second == 0:
+		return fmt.Sprintf("%ds", d/time.Second)
+	default:
+		return d.String()
+	}
+}
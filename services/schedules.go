@@ -0,0 +1,365 @@
+// Package services schedules.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ErrScheduleNotFound возвращается, если расписание не найдено
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ErrInvalidScheduleSpec возвращается при некорректных параметрах расписания
+var ErrInvalidScheduleSpec = errors.New("invalid schedule")
+
+// cronParser разбирает стандартные cron выражения из пяти полей и дескрипторы (@daily, @every 1h)
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// SchedulerConfig описывает параметры планировщика расписаний
+type SchedulerConfig struct {
+	PollInterval time.Duration // Интервал проверки наступивших запусков
+	MissedGrace  time.Duration // Запуск, опоздавший больше чем на MissedGrace, считается пропущенным
+	MaxCatchUp   int           // Максимальное количество пропущенных запусков, обрабатываемых за раз
+	BatchSize    int           // Количество расписаний, обрабатываемых за одну транзакцию
+}
+
+// ScheduleTemplateData данные, доступные в шаблоне содержимого сообщения
+type ScheduleTemplateData struct {
+	ScheduleID  uint
+	Name        string
+	ScheduledAt time.Time // Время запуска по расписанию в часовом поясе расписания
+	Missed      bool      // Запуск выполняется после допустимой задержки
+}
+
+// ScheduleService хранит расписания и выполняет их запуски. Запуск создает сообщение через MessageService
+// в одной транзакции с записью истории и переносом времени следующего запуска, поэтому несколько
+// экземпляров сервиса не выполняют один запуск дважды, а время запусков переживает перезапуск.
+type ScheduleService struct {
+	db       *database.Database
+	messages *MessageService
+	cfg      SchedulerConfig
+}
+
+// NewScheduleService создает сервис расписаний
+func NewScheduleService(db *database.Database, messages *MessageService, cfg SchedulerConfig) *ScheduleService {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.MissedGrace <= 0 {
+		cfg.MissedGrace = time.Minute
+	}
+	if cfg.MaxCatchUp <= 0 {
+		cfg.MaxCatchUp = 100
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &ScheduleService{db: db, messages: messages, cfg: cfg}
+}
+
+// Create создает расписание. Первый запуск вычисляется от текущего времени.
+func (s *ScheduleService) Create(ctx context.Context, request models.ScheduleRequest) (models.Schedule, error) {
+	schedule := models.Schedule{Enabled: true}
+	if err := applyScheduleRequest(&schedule, request, time.Now()); err != nil {
+		return models.Schedule{}, err
+	}
+	err := s.db.WithContext(ctx).Create(&schedule).Error
+	return schedule, err
+}
+
+// List возвращает расписания в порядке создания
+func (s *ScheduleService) List(ctx context.Context, offset, limit int) ([]models.Schedule, error) {
+	schedules := []models.Schedule{}
+	err := s.db.WithContext(ctx).Order("id").Offset(offset).Limit(limit).Find(&schedules).Error
+	return schedules, err
+}
+
+// Get возвращает расписание по ID
+func (s *ScheduleService) Get(ctx context.Context, id uint) (models.Schedule, error) {
+	var schedule models.Schedule
+	err := s.db.WithContext(ctx).First(&schedule, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return schedule, ErrScheduleNotFound
+	}
+	return schedule, err
+}
+
+// Update заменяет параметры расписания. Следующий запуск вычисляется заново от текущего времени,
+// поэтому запуски, пропущенные до изменения, не выполняются.
+func (s *ScheduleService) Update(ctx context.Context, id uint, request models.ScheduleRequest) (models.Schedule, error) {
+	var schedule models.Schedule
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrScheduleNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := applyScheduleRequest(&schedule, request, time.Now()); err != nil {
+			return err
+		}
+		return tx.Save(&schedule).Error
+	})
+	return schedule, err
+}
+
+// Delete удаляет расписание и историю его запусков. Созданные сообщения не удаляются.
+func (s *ScheduleService) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Schedule{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrScheduleNotFound
+		}
+		return tx.Where("schedule_id = ?", id).Delete(&models.ScheduleRun{}).Error
+	})
+}
+
+// Runs возвращает историю запусков расписания начиная с последних
+func (s *ScheduleService) Runs(ctx context.Context, id uint, offset, limit int) ([]models.ScheduleRun, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	runs := []models.ScheduleRun{}
+	err := s.db.WithContext(ctx).Where("schedule_id = ?", id).Order("id DESC").Offset(offset).Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// Run запускает цикл планировщика и блокируется до отмены контекста
+func (s *ScheduleService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	log.Println("Планировщик расписаний запущен")
+	for {
+		// Пока расписания выбираются полными пачками, продолжаем без ожидания следующего тика
+		for {
+			n, err := s.runDue(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Ошибка выполнения расписаний: %v", err)
+			}
+			if err != nil || n < s.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Завершение работы планировщика расписаний по запросу контекста")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue блокирует включенные расписания с наступившим запуском и выполняет их.
+// SKIP LOCKED позволяет нескольким экземплярам сервиса работать с одной таблицей.
+func (s *ScheduleService) runDue(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var schedules []models.Schedule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("enabled AND next_run_at <= ?", now).
+			Order("next_run_at").
+			Limit(s.cfg.BatchSize).
+			Find(&schedules).Error
+		if err != nil {
+			return err
+		}
+		count = len(schedules)
+		for i := range schedules {
+			if err := s.fire(ctx, tx, &schedules[i], now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// fire выполняет наступившие запуски расписания согласно политике пропущенных запусков
+// и переносит следующий запуск на первое время после now
+func (s *ScheduleService) fire(ctx context.Context, tx *gorm.DB, schedule *models.Schedule, now time.Time) error {
+	cronSchedule, loc, err := parseSchedule(schedule.Cron, schedule.Timezone)
+	if err != nil {
+		// Расписание было корректным при сохранении; например, часовой пояс исчез из tzdata
+		log.Printf("Расписание %d выключено: %v", schedule.ID, err)
+		return tx.Model(schedule).Updates(map[string]interface{}{"enabled": false, "next_run_at": nil}).Error
+	}
+
+	var due []time.Time
+	for t := *schedule.NextRunAt; !t.After(now) && len(due) < s.cfg.MaxCatchUp; t = cronSchedule.Next(t.In(loc)) {
+		due = append(due, t)
+	}
+
+	for i, scheduledAt := range due {
+		run := models.ScheduleRun{
+			ScheduleID:  schedule.ID,
+			ScheduledAt: scheduledAt,
+			Missed:      now.Sub(scheduledAt) > s.cfg.MissedGrace,
+		}
+		execute := true
+		switch schedule.MissedRunPolicy {
+		case models.MissedRunRunAll:
+		case models.MissedRunRunOnce:
+			execute = i == len(due)-1
+		default:
+			execute = !run.Missed
+		}
+
+		if execute {
+			msg, err := s.execute(ctx, tx, schedule, scheduledAt.In(loc), run.Missed)
+			if err != nil {
+				log.Printf("Запуск расписания %d на %s не выполнен: %v", schedule.ID, scheduledAt.Format(time.RFC3339), err)
+				run.Status = models.ScheduleRunFailed
+				run.Error = err.Error()
+			} else {
+				run.Status = models.ScheduleRunSucceeded
+				run.MessageID = &msg.ID
+			}
+			lastRunAt := scheduledAt
+			schedule.LastRunAt = &lastRunAt
+		} else {
+			run.Status = models.ScheduleRunSkipped
+		}
+		if err := tx.Create(&run).Error; err != nil {
+			return err
+		}
+	}
+	// Запуски сверх MaxCatchUp не выполняются и не записываются в историю, поэтому их количество логируется
+	if len(due) == s.cfg.MaxCatchUp {
+		if dropped := countRuns(cronSchedule, loc, due[len(due)-1], now); dropped > 0 {
+			log.Printf("Расписание %d: пропущено запусков сверх %d: %d, они не выполнены и не записаны в историю",
+				schedule.ID, s.cfg.MaxCatchUp, dropped)
+		}
+	}
+
+	next := cronSchedule.Next(now.In(loc))
+	schedule.NextRunAt = &next
+	return tx.Model(schedule).Updates(map[string]interface{}{
+		"next_run_at": schedule.NextRunAt,
+		"last_run_at": schedule.LastRunAt,
+	}).Error
+}
+
+// execute создает сообщение запуска. Ошибка создания откатывается до точки сохранения,
+// чтобы запуск был записан в историю как неудачный, а остальные запуски транзакции сохранились.
+func (s *ScheduleService) execute(ctx context.Context, tx *gorm.DB, schedule *models.Schedule, scheduledAt time.Time, missed bool) (models.Message, error) {
+	var content strings.Builder
+	tmpl, err := template.New("schedule").Option("missingkey=error").Parse(schedule.Template)
+	if err != nil {
+		return models.Message{}, err
+	}
+	data := ScheduleTemplateData{ScheduleID: schedule.ID, Name: schedule.Name, ScheduledAt: scheduledAt, Missed: missed}
+	if err := tmpl.Execute(&content, data); err != nil {
+		return models.Message{}, err
+	}
+
+	request := models.CreateMessageRequest{
+		Content:      content.String(),
+		PartitionKey: schedule.PartitionKey,
+		TenantID:     schedule.TenantID,
+	}
+	if err := ValidateRequest(request); err != nil {
+		return models.Message{}, err
+	}
+
+	// ID корреляции связывает сообщение с запуском расписания
+	ctx = WithCorrelationID(ctx, fmt.Sprintf("schedule-%d-%d", schedule.ID, scheduledAt.Unix()))
+	var msg models.Message
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		msg, err = s.messages.create(ctx, tx, request)
+		return err
+	})
+	return msg, err
+}
+
+// countRuns возвращает количество запусков расписания после after и не позже now. Для @every количество
+// вычисляется без перебора, так как интервал может быть меньше минуты.
+func countRuns(cronSchedule cron.Schedule, loc *time.Location, after, now time.Time) int {
+	next := cronSchedule.Next(after.In(loc))
+	if next.After(now) {
+		return 0
+	}
+	if every, ok := cronSchedule.(cron.ConstantDelaySchedule); ok {
+		return 1 + int(now.Sub(next)/every.Delay)
+	}
+	count := 0
+	for ; !next.After(now); next = cronSchedule.Next(next) {
+		count++
+	}
+	return count
+}
+
+// applyScheduleRequest проверяет запрос и переносит его в расписание. Для включенного расписания
+// следующий запуск вычисляется от now, для выключенного сбрасывается.
+func applyScheduleRequest(schedule *models.Schedule, request models.ScheduleRequest, now time.Time) error {
+	if strings.TrimSpace(request.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidScheduleSpec)
+	}
+	if strings.TrimSpace(request.Template) == "" {
+		return fmt.Errorf("%w: template is required", ErrInvalidScheduleSpec)
+	}
+	if _, err := template.New("schedule").Parse(request.Template); err != nil {
+		return fmt.Errorf("%w: template: %v", ErrInvalidScheduleSpec, err)
+	}
+	if request.Timezone == "" {
+		request.Timezone = "UTC"
+	}
+	cronSchedule, loc, err := parseSchedule(request.Cron, request.Timezone)
+	if err != nil {
+		return err
+	}
+	switch request.MissedRunPolicy {
+	case "":
+		request.MissedRunPolicy = models.MissedRunSkip
+	case models.MissedRunSkip, models.MissedRunRunOnce, models.MissedRunRunAll:
+	default:
+		return fmt.Errorf("%w: unknown missed_run_policy %q", ErrInvalidScheduleSpec, request.MissedRunPolicy)
+	}
+
+	schedule.Name = request.Name
+	schedule.Cron = request.Cron
+	schedule.Timezone = request.Timezone
+	schedule.Template = request.Template
+	schedule.PartitionKey = request.PartitionKey
+	schedule.TenantID = request.TenantID
+	schedule.MissedRunPolicy = request.MissedRunPolicy
+	if request.Enabled != nil {
+		schedule.Enabled = *request.Enabled
+	}
+
+	schedule.NextRunAt = nil
+	if schedule.Enabled {
+		next := cronSchedule.Next(now.In(loc))
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
+
+// parseSchedule разбирает cron выражение и часовой пояс расписания
+func parseSchedule(expr, timezone string) (cron.Schedule, *time.Location, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: timezone: %v", ErrInvalidScheduleSpec, err)
+	}
+	cronSchedule, err := cronParser.Parse(expr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: cron: %v", ErrInvalidScheduleSpec, err)
+	}
+	return cronSchedule, loc, nil
+}
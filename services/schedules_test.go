@@ -0,0 +1,239 @@
+// Package services schedules_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestApplyScheduleRequest(t *testing.T) {
+	// Суббота, 12:00 UTC
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		request models.ScheduleRequest
+		wantErr bool
+		next    time.Time
+	}{
+		{name: "будни в 9:00 по Москве", request: models.ScheduleRequest{Name: "report", Cron: "0 9 * * 1-5", Timezone: "Europe/Moscow", Template: "report"},
+			next: time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC)},
+		{name: "часовой пояс по умолчанию UTC", request: models.ScheduleRequest{Name: "report", Cron: "30 * * * *", Template: "report"},
+			next: time.Date(2026, 10, 17, 12, 30, 0, 0, time.UTC)},
+		{name: "дескриптор", request: models.ScheduleRequest{Name: "report", Cron: "@daily", Template: "report"},
+			next: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{name: "интервал", request: models.ScheduleRequest{Name: "report", Cron: "@every 15m", Template: "report"},
+			next: now.Add(15 * time.Minute)},
+		{name: "шесть полей", request: models.ScheduleRequest{Name: "report", Cron: "0 0 9 * * *", Template: "report"}, wantErr: true},
+		{name: "четыре поля", request: models.ScheduleRequest{Name: "report", Cron: "0 9 * *", Template: "report"}, wantErr: true},
+		{name: "минута вне диапазона", request: models.ScheduleRequest{Name: "report", Cron: "61 * * * *", Template: "report"}, wantErr: true},
+		{name: "неизвестный часовой пояс", request: models.ScheduleRequest{Name: "report", Cron: "@daily", Timezone: "Mars/Olympus", Template: "report"}, wantErr: true},
+		{name: "неизвестная политика", request: models.ScheduleRequest{Name: "report", Cron: "@daily", Template: "report", MissedRunPolicy: "later"}, wantErr: true},
+		{name: "некорректный шаблон", request: models.ScheduleRequest{Name: "report", Cron: "@daily", Template: "{{.Name"}, wantErr: true},
+		{name: "без имени", request: models.ScheduleRequest{Cron: "@daily", Template: "report"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := models.Schedule{Enabled: true}
+			err := applyScheduleRequest(&schedule, tt.request, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidScheduleSpec) {
+					t.Fatalf("applyScheduleRequest: %v, ожидалась ErrInvalidScheduleSpec", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyScheduleRequest: %v", err)
+			}
+			if schedule.NextRunAt == nil || !schedule.NextRunAt.Equal(tt.next) {
+				t.Errorf("следующий запуск %v, ожидался %s", schedule.NextRunAt, tt.next)
+			}
+			if schedule.MissedRunPolicy != models.MissedRunSkip {
+				t.Errorf("политика по умолчанию %q, ожидалась skip", schedule.MissedRunPolicy)
+			}
+		})
+	}
+
+	disabled := models.Schedule{}
+	enabled := false
+	request := models.ScheduleRequest{Name: "report", Cron: "@daily", Template: "report", Enabled: &enabled}
+	if err := applyScheduleRequest(&disabled, request, now); err != nil || disabled.NextRunAt != nil {
+		t.Errorf("выключенное расписание: next_run_at=%v, err=%v", disabled.NextRunAt, err)
+	}
+}
+
+func TestCountRuns(t *testing.T) {
+	after := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		cron string
+		now  time.Time
+		want int
+	}{
+		{"@every 1s", after.Add(time.Hour), 3600},
+		{"@every 1m", after.Add(30 * time.Second), 0},
+		{"*/5 * * * *", after.Add(time.Hour), 12},
+		{"0 9 * * *", after.Add(48 * time.Hour), 2},
+	} {
+		cronSchedule, loc, err := parseSchedule(tt.cron, "UTC")
+		if err != nil {
+			t.Fatalf("parseSchedule %q: %v", tt.cron, err)
+		}
+		if got := countRuns(cronSchedule, loc, after, tt.now); got != tt.want {
+			t.Errorf("%s до %s: %d запусков, ожидалось %d", tt.cron, tt.now, got, tt.want)
+		}
+	}
+}
+
+// createDueSchedule создает расписание @every 1m, первый запуск которого был ago назад
+func createDueSchedule(t *testing.T, db *database.Database, svc *ScheduleService, policy string, ago time.Duration) models.Schedule {
+	t.Helper()
+	schedule, err := svc.Create(context.Background(), models.ScheduleRequest{
+		Name:            "report",
+		Cron:            "@every 1m",
+		Template:        "Отчет {{.ScheduleID}}{{if .Missed}} (с опозданием){{end}}",
+		MissedRunPolicy: policy,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	nextRunAt := time.Now().Add(-ago)
+	if err := db.Model(&schedule).Update("next_run_at", nextRunAt).Error; err != nil {
+		t.Fatalf("перенос запуска: %v", err)
+	}
+	return schedule
+}
+
+// scheduleRuns возвращает историю запусков расписания в порядке выполнения
+func scheduleRuns(t *testing.T, db *database.Database, id uint) []models.ScheduleRun {
+	t.Helper()
+	var runs []models.ScheduleRun
+	if err := db.Where("schedule_id = ?", id).Order("id").Find(&runs).Error; err != nil {
+		t.Fatalf("история запусков: %v", err)
+	}
+	return runs
+}
+
+func TestScheduleMissedRunPolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []string // Результаты трех пропущенных запусков
+	}{
+		{models.MissedRunSkip, []string{models.ScheduleRunSkipped, models.ScheduleRunSkipped, models.ScheduleRunSkipped}},
+		{models.MissedRunRunOnce, []string{models.ScheduleRunSkipped, models.ScheduleRunSkipped, models.ScheduleRunSucceeded}},
+		{models.MissedRunRunAll, []string{models.ScheduleRunSucceeded, models.ScheduleRunSucceeded, models.ScheduleRunSucceeded}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			db := testDatabase(t)
+			svc := NewScheduleService(db, NewMessageService(db, MessageServiceConfig{Topic: "orders"}), SchedulerConfig{MissedGrace: 10 * time.Second})
+			// Запуски 150, 90 и 30 секунд назад опоздали больше чем на MissedGrace
+			schedule := createDueSchedule(t, db, svc, tt.policy, 150*time.Second)
+
+			if n, err := svc.runDue(context.Background()); err != nil || n != 1 {
+				t.Fatalf("runDue: %d расписаний, err=%v", n, err)
+			}
+
+			runs := scheduleRuns(t, db, schedule.ID)
+			if len(runs) != len(tt.want) {
+				t.Fatalf("запусков в истории: %d, ожидалось %d", len(runs), len(tt.want))
+			}
+			executed := 0
+			for i, run := range runs {
+				if run.Status != tt.want[i] || !run.Missed {
+					t.Errorf("запуск %d: статус %s, missed=%t, ожидался %s", i, run.Status, run.Missed, tt.want[i])
+				}
+				if run.Status == models.ScheduleRunSucceeded {
+					executed++
+					var msg models.Message
+					if err := db.First(&msg, run.MessageID).Error; err != nil || msg.Content != "Отчет 1 (с опозданием)" {
+						t.Errorf("сообщение запуска %d: %q, err=%v", i, msg.Content, err)
+					}
+				}
+			}
+
+			var messages int64
+			db.Model(&models.Message{}).Count(&messages)
+			if int(messages) != executed {
+				t.Errorf("создано сообщений: %d, ожидалось %d", messages, executed)
+			}
+			var stored models.Schedule
+			db.First(&stored, schedule.ID)
+			if stored.NextRunAt == nil || !stored.NextRunAt.After(time.Now()) {
+				t.Errorf("следующий запуск %v не перенесен в будущее", stored.NextRunAt)
+			}
+			if (executed > 0) != (stored.LastRunAt != nil) {
+				t.Errorf("last_run_at=%v при %d выполненных запусках", stored.LastRunAt, executed)
+			}
+		})
+	}
+}
+
+func TestScheduleCatchUpIsLimited(t *testing.T) {
+	db := testDatabase(t)
+	svc := NewScheduleService(db, NewMessageService(db, MessageServiceConfig{Topic: "orders"}), SchedulerConfig{MaxCatchUp: 2})
+	schedule := createDueSchedule(t, db, svc, models.MissedRunRunAll, 5*time.Minute+30*time.Second)
+
+	if _, err := svc.runDue(context.Background()); err != nil {
+		t.Fatalf("runDue: %v", err)
+	}
+
+	// Из шести наступивших запусков выполняются два, остальные только логируются
+	if runs := scheduleRuns(t, db, schedule.ID); len(runs) != 2 {
+		t.Errorf("запусков в истории: %d, ожидалось 2", len(runs))
+	}
+	var stored models.Schedule
+	db.First(&stored, schedule.ID)
+	if stored.NextRunAt == nil || !stored.NextRunAt.After(time.Now()) {
+		t.Errorf("следующий запуск %v не перенесен в будущее", stored.NextRunAt)
+	}
+}
+
+func TestScheduleFailedRunRollsBackToSavepoint(t *testing.T) {
+	db := testDatabase(t)
+	// Первая запись outbox не сохраняется: сообщение первого запуска уже создано в транзакции
+	outboxInserts := 0
+	err := db.Callback().Create().Before("gorm:create").Register("test:fail_first_outbox", func(tx *gorm.DB) {
+		if tx.Statement.Table != "outbox" {
+			return
+		}
+		if outboxInserts++; outboxInserts == 1 {
+			_ = tx.AddError(errors.New("outbox недоступен"))
+		}
+	})
+	if err != nil {
+		t.Fatalf("регистрация callback: %v", err)
+	}
+
+	svc := NewScheduleService(db, NewMessageService(db, MessageServiceConfig{Topic: "orders"}), SchedulerConfig{MissedGrace: time.Hour})
+	schedule := createDueSchedule(t, db, svc, models.MissedRunRunAll, 90*time.Second)
+	if _, err := svc.runDue(context.Background()); err != nil {
+		t.Fatalf("runDue: %v", err)
+	}
+
+	runs := scheduleRuns(t, db, schedule.ID)
+	if len(runs) != 2 {
+		t.Fatalf("запусков в истории: %d, ожидалось 2", len(runs))
+	}
+	if runs[0].Status != models.ScheduleRunFailed || runs[0].Error != "outbox недоступен" || runs[0].MessageID != nil {
+		t.Errorf("неудачный запуск: %+v", runs[0])
+	}
+	if runs[1].Status != models.ScheduleRunSucceeded || runs[1].MessageID == nil {
+		t.Errorf("успешный запуск: %+v", runs[1])
+	}
+
+	// Сообщение неудачного запуска откатывается до точки сохранения, остальная транзакция сохраняется
+	var messages []models.Message
+	db.Find(&messages)
+	if len(messages) != 1 || runs[1].MessageID == nil || messages[0].ID != *runs[1].MessageID {
+		t.Errorf("сообщения: %+v, ожидалось только сообщение второго запуска", messages)
+	}
+	var records int64
+	db.Model(&models.OutboxRecord{}).Count(&records)
+	if records != 1 {
+		t.Errorf("записей outbox: %d, ожидалась 1", records)
+	}
+}
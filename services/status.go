@@ -0,0 +1,152 @@
+// Package services status.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/models"
+	"gorm.io/gorm"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTransition возвращается при недопустимом переходе статуса сообщения
+var ErrInvalidTransition = errors.New("недопустимый переход статуса сообщения")
+
+// ErrMessageNotFound возвращается, если сообщение не найдено
+var ErrMessageNotFound = errors.New("message not found")
+
+// ErrInvalidStatus возвращается для неизвестного статуса сообщения
+var ErrInvalidStatus = errors.New("invalid message status")
+
+// messageTransitions задает для каждого статуса статусы, из которых в него можно перейти.
+// Повторная доставка записи может застать сообщение в processing, поэтому processing -> processing допустим.
+// Сообщения, обработанные пользовательским обработчиком, переходят в processed и failed минуя processing.
+// Consumer может прочитать запись раньше, чем relay зафиксирует публикацию, поэтому обработка
+// допустима и из статусов pending и scheduled.
+var messageTransitions = map[string][]string{
+	models.MessageStatusPublished: {models.MessageStatusPending, models.MessageStatusScheduled, models.MessageStatusDeadLettered},
+	models.MessageStatusCancelled: {models.MessageStatusScheduled},
+	models.MessageStatusProcessing: {
+		models.MessageStatusPending, models.MessageStatusScheduled, models.MessageStatusPublished, models.MessageStatusProcessing, models.MessageStatusFailed,
+	},
+	models.MessageStatusProcessed: {
+		models.MessageStatusPending, models.MessageStatusScheduled, models.MessageStatusPublished, models.MessageStatusProcessing, models.MessageStatusFailed,
+	},
+	models.MessageStatusFailed: {
+		models.MessageStatusPending, models.MessageStatusScheduled, models.MessageStatusPublished, models.MessageStatusProcessing, models.MessageStatusFailed,
+	},
+	models.MessageStatusDeadLettered: {
+		models.MessageStatusPending, models.MessageStatusScheduled, models.MessageStatusPublished, models.MessageStatusProcessing, models.MessageStatusFailed,
+	},
+	// Сообщение в processing уже передано обработчику, поэтому его срок действия не проверяется
+	models.MessageStatusExpired: {
+		models.MessageStatusPending, models.MessageStatusScheduled, models.MessageStatusPublished, models.MessageStatusFailed,
+	},
+}
+
+// TransitionMessage переводит сообщение в статус to, если переход допустим из текущего статуса.
+// Проверка и изменение выполняются одним UPDATE, поэтому конкурирующие переходы не нарушают порядок.
+// cause сохраняется как последняя ошибка. Возвращает ErrMessageNotFound или ErrInvalidTransition.
+func TransitionMessage(ctx context.Context, db *gorm.DB, id uint, to string, cause error) error {
+	from, ok := messageTransitions[to]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidStatus, to)
+	}
+	result := db.WithContext(ctx).Unscoped().Model(&models.Message{}).
+		Where("id = ? AND status IN ?", id, from).
+		Updates(statusUpdates(to, cause, time.Now()))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	return transitionError(ctx, db, id, to)
+}
+
+// transitionError возвращает причину, по которой сообщение не перешло в статус to:
+// ErrMessageNotFound или ErrInvalidTransition с текущим статусом
+func transitionError(ctx context.Context, db *gorm.DB, id uint, to string) error {
+	var current models.Message
+	err := db.WithContext(ctx).Unscoped().Select("id", "status").First(&current, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, current.Status, to)
+}
+
+// transitionMessages переводит в статус to сообщения из ids, для которых переход допустим.
+// Остальные сообщения не изменяются. Возвращает количество измененных сообщений.
+func transitionMessages(db *gorm.DB, ids []uint, to string, now time.Time) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := db.Unscoped().Model(&models.Message{}).
+		Where("id IN ? AND status IN ?", ids, messageTransitions[to]).
+		Updates(statusUpdates(to, nil, now))
+	return result.RowsAffected, result.Error
+}
+
+// statusUpdates возвращает изменяемые колонки для перехода в статус to
+func statusUpdates(to string, cause error, now time.Time) map[string]interface{} {
+	updates := map[string]interface{}{"status": to}
+	switch to {
+	case models.MessageStatusPublished:
+		updates["published_at"] = now
+	case models.MessageStatusProcessing:
+		updates["attempts"] = gorm.Expr("attempts + 1")
+	case models.MessageStatusProcessed:
+		updates["processed_at"] = now
+		updates["last_error"] = ""
+	case models.MessageStatusFailed, models.MessageStatusDeadLettered:
+		updates["failed_at"] = now
+	case models.MessageStatusCancelled:
+		updates["cancelled_at"] = now
+	}
+	if cause != nil {
+		updates["last_error"] = cause.Error()
+	}
+	return updates
+}
+
+// recordMessageStatus переводит сообщение, опубликованное записью m, в статус to. Статус учитывается
+// только для записей сообщений (тип MessageTypeDefault с заголовком message-id); ошибка перехода
+// только логируется, так как запись уже перенаправлена и статус не влияет на доставку.
+func recordMessageStatus(ctx context.Context, db *gorm.DB, m kafka.Message, to string, cause error) {
+	headers := HeadersFromKafka(m.Headers)
+	if messageType := headers[HeaderMessageType]; messageType != "" && messageType != MessageTypeDefault {
+		return
+	}
+	id, err := strconv.ParseUint(headers[HeaderMessageID], 10, 64)
+	if err != nil || id == 0 {
+		return
+	}
+	if err := TransitionMessage(ctx, db, uint(id), to, cause); err != nil {
+		log.Printf("Статус сообщения %d не изменен на %s: %v", id, to, err)
+	}
+}
+
+// ParseMessageStatuses разбирает список статусов через запятую. Пустая строка означает все статусы.
+func ParseMessageStatuses(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var statuses []string
+	for _, status := range strings.Split(value, ",") {
+		status = strings.TrimSpace(status)
+		if !slices.Contains(models.MessageStatuses, status) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
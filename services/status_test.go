@@ -0,0 +1,138 @@
+// Package services status_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"go_microsvc/database"
+	"go_microsvc/models"
+	"testing"
+	"time"
+)
+
+// createMessageWithStatus сохраняет сообщение в статусе status
+func createMessageWithStatus(t *testing.T, db *database.Database, status string) models.Message {
+	t.Helper()
+	msg := models.Message{Content: "order", Status: status}
+	if err := db.Create(&msg).Error; err != nil {
+		t.Fatalf("создание сообщения в статусе %s: %v", status, err)
+	}
+	return msg
+}
+
+func TestTransitionMessage(t *testing.T) {
+	db := testDatabase(t)
+	cause := errors.New("обработка не удалась")
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		cause   error
+		wantErr error
+		check   func(t *testing.T, msg models.Message)
+	}{
+		{name: "pending -> published", from: models.MessageStatusPending, to: models.MessageStatusPublished,
+			check: func(t *testing.T, msg models.Message) {
+				if msg.PublishedAt == nil {
+					t.Error("published_at не заполнен")
+				}
+			}},
+		{name: "scheduled -> cancelled", from: models.MessageStatusScheduled, to: models.MessageStatusCancelled,
+			check: func(t *testing.T, msg models.Message) {
+				if msg.CancelledAt == nil {
+					t.Error("cancelled_at не заполнен")
+				}
+			}},
+		{name: "dead_lettered -> published при возврате из DLQ", from: models.MessageStatusDeadLettered, to: models.MessageStatusPublished},
+		{name: "published -> processing", from: models.MessageStatusPublished, to: models.MessageStatusProcessing,
+			check: func(t *testing.T, msg models.Message) {
+				if msg.Attempts != 1 {
+					t.Errorf("attempts=%d, ожидалась 1", msg.Attempts)
+				}
+			}},
+		{name: "pending -> processing до фиксации публикации", from: models.MessageStatusPending, to: models.MessageStatusProcessing},
+		{name: "processing -> processing при повторной доставке", from: models.MessageStatusProcessing, to: models.MessageStatusProcessing},
+		{name: "processing -> failed", from: models.MessageStatusProcessing, to: models.MessageStatusFailed, cause: cause,
+			check: func(t *testing.T, msg models.Message) {
+				if msg.FailedAt == nil || msg.LastError != cause.Error() {
+					t.Errorf("failed_at=%v, last_error=%q", msg.FailedAt, msg.LastError)
+				}
+			}},
+		{name: "failed -> processed", from: models.MessageStatusFailed, to: models.MessageStatusProcessed,
+			check: func(t *testing.T, msg models.Message) {
+				if msg.ProcessedAt == nil || msg.LastError != "" {
+					t.Errorf("processed_at=%v, last_error=%q", msg.ProcessedAt, msg.LastError)
+				}
+			}},
+		{name: "published -> processed пользовательским обработчиком", from: models.MessageStatusPublished, to: models.MessageStatusProcessed},
+		{name: "failed -> dead_lettered", from: models.MessageStatusFailed, to: models.MessageStatusDeadLettered, cause: cause},
+		{name: "published -> expired", from: models.MessageStatusPublished, to: models.MessageStatusExpired},
+
+		{name: "processed -> processing", from: models.MessageStatusProcessed, to: models.MessageStatusProcessing, wantErr: ErrInvalidTransition},
+		{name: "processed -> failed", from: models.MessageStatusProcessed, to: models.MessageStatusFailed, wantErr: ErrInvalidTransition},
+		{name: "processing -> published", from: models.MessageStatusProcessing, to: models.MessageStatusPublished, wantErr: ErrInvalidTransition},
+		{name: "pending -> cancelled", from: models.MessageStatusPending, to: models.MessageStatusCancelled, wantErr: ErrInvalidTransition},
+		{name: "cancelled -> published", from: models.MessageStatusCancelled, to: models.MessageStatusPublished, wantErr: ErrInvalidTransition},
+		{name: "expired -> processing", from: models.MessageStatusExpired, to: models.MessageStatusProcessing, wantErr: ErrInvalidTransition},
+		{name: "processing -> expired", from: models.MessageStatusProcessing, to: models.MessageStatusExpired, wantErr: ErrInvalidTransition},
+		{name: "dead_lettered -> processed", from: models.MessageStatusDeadLettered, to: models.MessageStatusProcessed, wantErr: ErrInvalidTransition},
+		{name: "переход в pending", from: models.MessageStatusPublished, to: models.MessageStatusPending, wantErr: ErrInvalidStatus},
+		{name: "переход в неизвестный статус", from: models.MessageStatusPending, to: "unknown", wantErr: ErrInvalidStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := createMessageWithStatus(t, db, tt.from)
+			err := TransitionMessage(context.Background(), db.DB, msg.ID, tt.to, tt.cause)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TransitionMessage: %v, ожидалась ошибка %v", err, tt.wantErr)
+			}
+
+			var stored models.Message
+			if err := db.First(&stored, msg.ID).Error; err != nil {
+				t.Fatalf("чтение сообщения: %v", err)
+			}
+			want := tt.to
+			if tt.wantErr != nil {
+				want = tt.from
+			}
+			if stored.Status != want {
+				t.Errorf("статус %s, ожидался %s", stored.Status, want)
+			}
+			if tt.check != nil {
+				tt.check(t, stored)
+			}
+		})
+	}
+}
+
+func TestTransitionMessageNotFound(t *testing.T) {
+	db := testDatabase(t)
+	if err := TransitionMessage(context.Background(), db.DB, 1000, models.MessageStatusProcessed, nil); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("TransitionMessage: %v, ожидалась ErrMessageNotFound", err)
+	}
+}
+
+func TestTransitionMessagesSkipsIllegalTransitions(t *testing.T) {
+	db := testDatabase(t)
+	pending := createMessageWithStatus(t, db, models.MessageStatusPending)
+	processed := createMessageWithStatus(t, db, models.MessageStatusProcessed)
+	cancelled := createMessageWithStatus(t, db, models.MessageStatusCancelled)
+
+	// Публикация не возвращает обработанные и отмененные сообщения в статус published
+	n, err := transitionMessages(db.DB, []uint{pending.ID, processed.ID, cancelled.ID}, models.MessageStatusPublished, time.Now())
+	if err != nil || n != 1 {
+		t.Fatalf("transitionMessages: изменено %d, err=%v, ожидалось 1", n, err)
+	}
+	for id, want := range map[uint]string{
+		pending.ID:   models.MessageStatusPublished,
+		processed.ID: models.MessageStatusProcessed,
+		cancelled.ID: models.MessageStatusCancelled,
+	} {
+		var stored models.Message
+		db.First(&stored, id)
+		if stored.Status != want {
+			t.Errorf("сообщение %d: статус %s, ожидался %s", id, stored.Status, want)
+		}
+	}
+}
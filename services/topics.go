@@ -0,0 +1,466 @@
+// Package services topics.go
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/segmentio/kafka-go"
+	"go_microsvc/config"
+	"go_microsvc/models"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Параметры топика, которые сверяются с конфигурацией
+const (
+	topicConfigRetention   = "retention.ms"
+	topicConfigCleanup     = "cleanup.policy"
+	topicConfigCompression = "compression.type"
+)
+
+// Результаты сверки топика
+const (
+	TopicCreated         = "created"          // Топик создан
+	TopicPartitionsAdded = "partitions_added" // Добавлены партиции
+	TopicUnchanged       = "unchanged"        // Топик соответствует объявлению или расхождения только зафиксированы
+	TopicFailed          = "failed"           // Топик не удалось создать или изменить
+)
+
+// topicConfigEntries возвращает параметры топика, заданные в объявлении
+func topicConfigEntries(s config.TopicSpec) []kafka.ConfigEntry {
+	var entries []kafka.ConfigEntry
+	if s.Retention > 0 {
+		entries = append(entries, kafka.ConfigEntry{ConfigName: topicConfigRetention, ConfigValue: strconv.FormatInt(s.Retention.Milliseconds(), 10)})
+	}
+	if s.CleanupPolicy != "" {
+		entries = append(entries, kafka.ConfigEntry{ConfigName: topicConfigCleanup, ConfigValue: s.CleanupPolicy})
+	}
+	if s.Compression != "" {
+		entries = append(entries, kafka.ConfigEntry{ConfigName: topicConfigCompression, ConfigValue: s.Compression})
+	}
+	return entries
+}
+
+// TopicReconcileResult результат сверки одного топика
+type TopicReconcileResult struct {
+	Topic  string
+	Action string   // created, partitions_added, unchanged, failed
+	Drift  []string // Расхождения с объявлением, которые не исправляются автоматически
+	Error  error
+}
+
+// TopicSpecsFromConfig возвращает объявленные топики вместе с топиками приоритетов, retry топиками и DLQ основного топика.
+// Производные топики создаются с параметрами основного топика, если они не объявлены явно.
+func TopicSpecsFromConfig(cfg config.Config, retries *RetryRouter) []config.TopicSpec {
+	specs := make([]config.TopicSpec, 0, len(cfg.KafkaTopics))
+	declared := make(map[string]bool)
+	var main config.TopicSpec
+	for _, spec := range cfg.KafkaTopics {
+		specs = append(specs, spec)
+		declared[spec.Name] = true
+		if spec.Name == cfg.KafkaTopic {
+			main = spec
+		}
+	}
+	if main.Name == "" {
+		return specs
+	}
+
+	derived := []string{cfg.KafkaDLQTopic}
+	for _, priority := range models.MessagePriorities {
+		derived = append(derived, PriorityTopic(main.Name, priority))
+	}
+	for _, priority := range models.MessagePriorities {
+		for _, tier := range retries.Policy(main.Name).Tiers {
+			derived = append(derived, RetryTopicName(PriorityTopic(main.Name, priority), tier))
+		}
+	}
+	for _, name := range derived {
+		if name == "" || declared[name] {
+			continue
+		}
+		spec := main
+		spec.Name = name
+		specs = append(specs, spec)
+		declared[name] = true
+	}
+	return specs
+}
+
+// Ошибки администрирования топиков
+var (
+	ErrTopicNotFound       = errors.New("топик не найден")
+	ErrTopicExists         = errors.New("топик уже существует")
+	ErrInvalidTopicRequest = errors.New("некорректные параметры топика")
+)
+
+// TopicManager создает и проверяет топики. Создание выполняется через соединение с контроллером
+// кластера, остальные операции — через admin клиент Kafka.
+type TopicManager struct {
+	brokers []string
+	client  *kafka.Client
+}
+
+// NewTopicManager создает менеджер топиков
+func NewTopicManager(brokers string) *TopicManager {
+	return &TopicManager{brokers: strings.Split(brokers, ","), client: NewKafkaClient(brokers)}
+}
+
+// TopicSummary краткое описание топика
+// swagger:model TopicSummary
+type TopicSummary struct {
+	Name              string `json:"name"`
+	Partitions        int    `json:"partitions"`
+	ReplicationFactor int    `json:"replication_factor"`
+	Internal          bool   `json:"internal"` // Служебный топик Kafka (__consumer_offsets и т.п.)
+}
+
+// PartitionDescription состояние партиции топика
+// swagger:model PartitionDescription
+type PartitionDescription struct {
+	ID              int    `json:"id"`
+	Leader          int    `json:"leader"`
+	LeaderHost      string `json:"leader_host"`
+	Replicas        []int  `json:"replicas"`
+	ISR             []int  `json:"isr"`
+	OfflineReplicas []int  `json:"offline_replicas,omitempty"`
+	FirstOffset     int64  `json:"first_offset"`
+	LastOffset      int64  `json:"last_offset"`
+	Error           string `json:"error,omitempty"`
+}
+
+// TopicDescription подробное описание топика
+// swagger:model TopicDescription
+type TopicDescription struct {
+	Name       string                 `json:"name"`
+	Partitions []PartitionDescription `json:"partitions"`
+	Configs    map[string]string      `json:"configs"`
+}
+
+// dialController открывает соединение с контроллером кластера, через который создаются топики
+func (m *TopicManager) dialController(ctx context.Context) (*kafka.Conn, error) {
+	var dialer kafka.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.brokers[0])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения к Kafka: %w", err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("Ошибка закрытия соединения с брокером: %v", err)
+		}
+	}()
+
+	controller, err := conn.Controller()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения контроллера Kafka: %w", err)
+	}
+
+	controllerConn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения к контроллеру Kafka: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = controllerConn.SetDeadline(deadline)
+	} else {
+		_ = controllerConn.SetDeadline(time.Now().Add(m.client.Timeout))
+	}
+	return controllerConn, nil
+}
+
+// ListTopics возвращает топики кластера, отсортированные по имени
+func (m *TopicManager) ListTopics(ctx context.Context) ([]TopicSummary, error) {
+	meta, err := m.client.Metadata(ctx, &kafka.MetadataRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения метаданных топиков: %w", err)
+	}
+	topics := make([]TopicSummary, 0, len(meta.Topics))
+	for _, t := range meta.Topics {
+		summary := TopicSummary{Name: t.Name, Partitions: len(t.Partitions), Internal: t.Internal}
+		if len(t.Partitions) > 0 {
+			summary.ReplicationFactor = len(t.Partitions[0].Replicas)
+		}
+		topics = append(topics, summary)
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].Name < topics[j].Name })
+	return topics, nil
+}
+
+// DescribeTopic возвращает партиции топика с лидерами, репликами и смещениями, а также параметры топика
+func (m *TopicManager) DescribeTopic(ctx context.Context, name string) (TopicDescription, error) {
+	description := TopicDescription{Name: name}
+
+	meta, err := m.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{name}})
+	if err != nil {
+		return description, fmt.Errorf("ошибка получения метаданных топика %s: %w", name, err)
+	}
+	var topic *kafka.Topic
+	for i := range meta.Topics {
+		if meta.Topics[i].Name == name && meta.Topics[i].Error == nil {
+			topic = &meta.Topics[i]
+		}
+	}
+	if topic == nil || len(topic.Partitions) == 0 {
+		return description, fmt.Errorf("%w: %s", ErrTopicNotFound, name)
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, 2*len(topic.Partitions))
+	for _, p := range topic.Partitions {
+		requests = append(requests, kafka.FirstOffsetOf(p.ID), kafka.LastOffsetOf(p.ID))
+	}
+	offsets := make(map[int]kafka.PartitionOffsets)
+	resp, err := m.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{name: requests}})
+	if err != nil {
+		return description, fmt.Errorf("ошибка получения смещений топика %s: %w", name, err)
+	}
+	for _, p := range resp.Topics[name] {
+		offsets[p.Partition] = p
+	}
+
+	for _, p := range topic.Partitions {
+		partition := PartitionDescription{
+			ID:              p.ID,
+			Leader:          p.Leader.ID,
+			LeaderHost:      net.JoinHostPort(p.Leader.Host, strconv.Itoa(p.Leader.Port)),
+			Replicas:        brokerIDs(p.Replicas),
+			ISR:             brokerIDs(p.Isr),
+			OfflineReplicas: brokerIDs(p.OfflineReplicas),
+			FirstOffset:     offsets[p.ID].FirstOffset,
+			LastOffset:      offsets[p.ID].LastOffset,
+		}
+		if p.Error != nil {
+			partition.Error = p.Error.Error()
+		}
+		description.Partitions = append(description.Partitions, partition)
+	}
+	sort.Slice(description.Partitions, func(i, j int) bool { return description.Partitions[i].ID < description.Partitions[j].ID })
+
+	if description.Configs, err = m.topicConfigs(ctx, name); err != nil {
+		return description, fmt.Errorf("ошибка получения параметров топика %s: %w", name, err)
+	}
+	return description, nil
+}
+
+// CreateTopic создает топик через контроллер кластера. configs дополняет параметры из spec.
+func (m *TopicManager) CreateTopic(ctx context.Context, spec config.TopicSpec, configs map[string]string) error {
+	if spec.Name == "" || spec.Partitions <= 0 || spec.ReplicationFactor <= 0 {
+		return fmt.Errorf("%w: требуются имя, количество партиций и фактор репликации", ErrInvalidTopicRequest)
+	}
+	entries := topicConfigEntries(spec)
+	for name, value := range configs {
+		entries = append(entries, kafka.ConfigEntry{ConfigName: name, ConfigValue: value})
+	}
+
+	conn, err := m.dialController(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("Ошибка закрытия соединения с контроллером: %v", err)
+		}
+	}()
+
+	err = conn.CreateTopics(kafka.TopicConfig{
+		Topic:             spec.Name,
+		NumPartitions:     spec.Partitions,
+		ReplicationFactor: spec.ReplicationFactor,
+		ConfigEntries:     entries,
+	})
+	switch {
+	case errors.Is(err, kafka.TopicAlreadyExists):
+		return fmt.Errorf("%w: %s", ErrTopicExists, spec.Name)
+	case errors.Is(err, kafka.InvalidPartitionNumber), errors.Is(err, kafka.InvalidReplicationFactor),
+		errors.Is(err, kafka.InvalidTopic), errors.Is(err, kafka.InvalidConfiguration):
+		return fmt.Errorf("%w: %v", ErrInvalidTopicRequest, err)
+	case err != nil:
+		return fmt.Errorf("ошибка создания топика %s: %w", spec.Name, err)
+	}
+	log.Printf("Топик успешно создан: %s", spec.Name)
+	return nil
+}
+
+// AddPartitions увеличивает количество партиций топика до count. Уменьшение не поддерживается Kafka.
+func (m *TopicManager) AddPartitions(ctx context.Context, name string, count int) error {
+	description, err := m.DescribeTopic(ctx, name)
+	if err != nil {
+		return err
+	}
+	if count <= len(description.Partitions) {
+		return fmt.Errorf("%w: у топика %s уже %d партиций, новое количество должно быть больше",
+			ErrInvalidTopicRequest, name, len(description.Partitions))
+	}
+
+	resp, err := m.client.CreatePartitions(ctx, &kafka.CreatePartitionsRequest{
+		Topics: []kafka.TopicPartitionsConfig{{Name: name, Count: int32(count)}},
+	})
+	if err == nil {
+		err = resp.Errors[name]
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка добавления партиций топика %s (%d -> %d): %w", name, len(description.Partitions), count, err)
+	}
+	log.Printf("Количество партиций топика %s увеличено: %d -> %d", name, len(description.Partitions), count)
+	return nil
+}
+
+// brokerIDs возвращает ID брокеров
+func brokerIDs(brokers []kafka.Broker) []int {
+	ids := make([]int, len(brokers))
+	for i, b := range brokers {
+		ids[i] = b.ID
+	}
+	return ids
+}
+
+// Reconcile приводит топики к объявленной конфигурации: создает отсутствующие и добавляет партиции,
+// если их меньше объявленного. Уменьшить количество партиций, изменить фактор репликации
+// и параметры существующего топика нельзя без риска для данных, поэтому такие расхождения только
+// возвращаются в Drift. Повторный вызов с той же конфигурацией ничего не меняет.
+func (m *TopicManager) Reconcile(ctx context.Context, specs []config.TopicSpec) ([]TopicReconcileResult, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.Name
+	}
+
+	meta, err := m.client.Metadata(ctx, &kafka.MetadataRequest{Topics: names})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения метаданных топиков: %w", err)
+	}
+	existing := make(map[string]kafka.Topic, len(meta.Topics))
+	for _, t := range meta.Topics {
+		if t.Error == nil {
+			existing[t.Name] = t
+		}
+	}
+
+	results := make([]TopicReconcileResult, 0, len(specs))
+	for _, spec := range specs {
+		var result TopicReconcileResult
+		if topic, ok := existing[spec.Name]; ok {
+			result = m.reconcileExisting(ctx, spec, topic)
+		} else {
+			result = m.create(ctx, spec)
+		}
+		logReconcileResult(result)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// create создает отсутствующий топик. Если топик успели создать параллельно, это не считается ошибкой.
+func (m *TopicManager) create(ctx context.Context, spec config.TopicSpec) TopicReconcileResult {
+	result := TopicReconcileResult{Topic: spec.Name, Action: TopicCreated}
+	err := m.CreateTopic(ctx, spec, nil)
+	switch {
+	case errors.Is(err, ErrTopicExists):
+		result.Action = TopicUnchanged
+	case err != nil:
+		result.Action = TopicFailed
+		result.Error = err
+	}
+	return result
+}
+
+// reconcileExisting добавляет недостающие партиции существующего топика и собирает расхождения
+func (m *TopicManager) reconcileExisting(ctx context.Context, spec config.TopicSpec, topic kafka.Topic) TopicReconcileResult {
+	result := TopicReconcileResult{Topic: spec.Name, Action: TopicUnchanged}
+
+	partitions := len(topic.Partitions)
+	switch {
+	case partitions < spec.Partitions:
+		resp, err := m.client.CreatePartitions(ctx, &kafka.CreatePartitionsRequest{
+			Topics: []kafka.TopicPartitionsConfig{{Name: spec.Name, Count: int32(spec.Partitions)}},
+		})
+		if err == nil {
+			err = resp.Errors[spec.Name]
+		}
+		if err != nil {
+			result.Action = TopicFailed
+			result.Error = fmt.Errorf("ошибка добавления партиций (%d -> %d): %w", partitions, spec.Partitions, err)
+		} else {
+			result.Action = TopicPartitionsAdded
+		}
+	case partitions > spec.Partitions:
+		result.Drift = append(result.Drift, fmt.Sprintf("partitions: %d, объявлено %d (уменьшение не поддерживается)", partitions, spec.Partitions))
+	}
+
+	if partitions > 0 {
+		if replication := len(topic.Partitions[0].Replicas); replication != spec.ReplicationFactor {
+			result.Drift = append(result.Drift, fmt.Sprintf("replication factor: %d, объявлено %d", replication, spec.ReplicationFactor))
+		}
+	}
+
+	drift, err := m.configDrift(ctx, spec)
+	if err != nil {
+		result.Drift = append(result.Drift, fmt.Sprintf("параметры не проверены: %v", err))
+	}
+	result.Drift = append(result.Drift, drift...)
+	return result
+}
+
+// configDrift сравнивает параметры топика в брокере с объявленными
+func (m *TopicManager) configDrift(ctx context.Context, spec config.TopicSpec) ([]string, error) {
+	want := topicConfigEntries(spec)
+	if len(want) == 0 {
+		return nil, nil
+	}
+	current, err := m.topicConfigs(ctx, spec.Name, topicConfigRetention, topicConfigCleanup, topicConfigCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []string
+	for _, entry := range want {
+		if value := current[entry.ConfigName]; value != entry.ConfigValue {
+			drift = append(drift, fmt.Sprintf("%s: %q, объявлено %q", entry.ConfigName, value, entry.ConfigValue))
+		}
+	}
+	return drift, nil
+}
+
+// topicConfigs возвращает значения параметров топика; без names — все параметры
+func (m *TopicManager) topicConfigs(ctx context.Context, topic string, names ...string) (map[string]string, error) {
+	resp, err := m.client.DescribeConfigs(ctx, &kafka.DescribeConfigsRequest{
+		Resources: []kafka.DescribeConfigRequestResource{{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: topic,
+			ConfigNames:  names,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	configs := make(map[string]string)
+	for _, resource := range resp.Resources {
+		if resource.Error != nil {
+			return nil, resource.Error
+		}
+		for _, entry := range resource.ConfigEntries {
+			configs[entry.ConfigName] = entry.ConfigValue
+		}
+	}
+	return configs, nil
+}
+
+// logReconcileResult логирует результат сверки топика
+func logReconcileResult(result TopicReconcileResult) {
+	switch result.Action {
+	case TopicCreated:
+		log.Printf("Топик %s создан", result.Topic)
+	case TopicPartitionsAdded:
+		log.Printf("В топик %s добавлены партиции", result.Topic)
+	case TopicFailed:
+		log.Printf("Ошибка сверки топика %s: %v", result.Topic, result.Error)
+	}
+	if len(result.Drift) > 0 {
+		log.Printf("Конфигурация топика %s отличается от объявленной: %s", result.Topic, strings.Join(result.Drift, "; "))
+	}
+}
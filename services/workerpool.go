@@ -0,0 +1,221 @@
+// Package services workerpool.go
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+)
+
+// recordHandler обрабатывает одну запись. Ошибка означает, что запись не обработана и ее смещение нельзя фиксировать.
+type recordHandler func(ctx context.Context, m kafka.Message) error
+
+// workerPool распределяет записи между обработчиками по ключу (или партиции, если ключа нет):
+// записи с одинаковым ключом обрабатываются одним обработчиком по порядку, а пропускная способность
+// растет с количеством обработчиков. Смещения фиксируются только до наибольшего непрерывного
+// обработанного смещения в каждой партиции, а записи очередей с подтверждением каждой записи
+// подтверждаются сразу после обработки.
+type workerPool struct {
+	reader  MessageReader
+	handle  recordHandler
+	queues  []chan kafka.Message
+	tracker *offsetTracker
+}
+
+// newWorkerPool создает пул из workers обработчиков с очередью queueSize записей у каждого
+func newWorkerPool(reader MessageReader, handle recordHandler, workers, queueSize int) *workerPool {
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+	queues := make([]chan kafka.Message, workers)
+	for i := range queues {
+		queues[i] = make(chan kafka.Message, queueSize)
+	}
+	return &workerPool{
+		reader:  reader,
+		handle:  handle,
+		queues:  queues,
+		tracker: newOffsetTracker(workers*queueSize, acksRecords(reader)),
+	}
+}
+
+// run читает записи и раздает их обработчикам до завершения контекста или ошибки обработки.
+// После остановки дожидается обработчиков и фиксирует смещения уже обработанных записей.
+func (p *workerPool) run(ctx context.Context) {
+	// Обработчики останавливаются при первой прерванной записи, чтобы не обрабатывать записи после нее
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+
+	var workers sync.WaitGroup
+	for _, queue := range p.queues {
+		workers.Add(1)
+		go func(queue chan kafka.Message) {
+			defer workers.Done()
+			p.work(workerCtx, queue, stopWorkers)
+		}(queue)
+	}
+
+	committed := make(chan struct{})
+	go func() {
+		defer close(committed)
+		p.commitLoop()
+	}()
+
+	p.dispatch(workerCtx)
+
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	workers.Wait()
+	p.tracker.close()
+	<-committed
+}
+
+// dispatch читает записи из Kafka и отправляет их в очередь обработчика по ключу
+func (p *workerPool) dispatch(ctx context.Context) {
+	for {
+		m, err := p.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				log.Printf("Завершение работы пула обработчиков топика %s по запросу контекста", p.reader.Topic())
+				return
+			}
+			metrics.observeFetch(0, err)
+			log.Printf("Не удалось прочитать сообщение: %v", err)
+			if err := sleepContext(ctx, 5*time.Second); err != nil {
+				return
+			}
+			continue
+		}
+
+		metrics.observeFetch(1, nil)
+		p.tracker.add(m)
+		select {
+		case p.queues[p.workerFor(m)] <- m:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// work обрабатывает записи своей очереди по порядку
+func (p *workerPool) work(ctx context.Context, queue chan kafka.Message, stop context.CancelFunc) {
+	for m := range queue {
+		if ctx.Err() != nil {
+			continue // Дочитываем очередь без обработки: смещения этих записей не фиксируются
+		}
+		if err := p.handle(ctx, m); err != nil {
+			log.Printf("Обработка сообщения прервана: %v", err)
+			stop()
+			continue
+		}
+		p.tracker.done(m)
+	}
+}
+
+// commitLoop последовательно фиксирует смещения, чтобы они не уменьшались при конкурентных коммитах
+func (p *workerPool) commitLoop() {
+	for m := range p.tracker.commits {
+		// Контекст не используется: смещения обработанных записей фиксируются и при завершении работы
+		if err := commitMessages(context.Background(), p.reader, m); err != nil {
+			log.Printf("Ошибка при коммите смещения: %v", err)
+		}
+	}
+}
+
+// workerFor выбирает обработчик по ключу записи, а для записей без ключа — по партиции
+func (p *workerPool) workerFor(m kafka.Message) int {
+	h := fnv.New32a()
+	if len(m.Key) > 0 {
+		_, _ = h.Write(m.Key)
+	} else {
+		_, _ = h.Write([]byte{byte(m.Partition >> 24), byte(m.Partition >> 16), byte(m.Partition >> 8), byte(m.Partition)})
+	}
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// topicPartition идентифицирует партицию топика
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// partitionOffsets хранит прочитанные, но еще не зафиксированные смещения партиции в порядке чтения
+type partitionOffsets struct {
+	inflight []int64
+	done     map[int64]bool
+}
+
+// offsetTracker вычисляет наибольшее непрерывное обработанное смещение каждой партиции.
+// Для читателей, подтверждающих каждую запись (очереди, см. recordAcker), смещения не отслеживаются:
+// коммит ставится в очередь для каждой обработанной записи, так как подтверждение смещения
+// не распространяется на предыдущие записи, а повторно доставленные записи приходят с меньшими смещениями.
+type offsetTracker struct {
+	mu         sync.Mutex
+	perRecord  bool
+	partitions map[topicPartition]*partitionOffsets
+	commits    chan kafka.Message
+}
+
+// newOffsetTracker создает трекер с буфером коммитов указанного размера
+func newOffsetTracker(buffer int, perRecord bool) *offsetTracker {
+	return &offsetTracker{
+		perRecord:  perRecord,
+		partitions: make(map[topicPartition]*partitionOffsets),
+		commits:    make(chan kafka.Message, buffer),
+	}
+}
+
+// add регистрирует прочитанную запись
+func (t *offsetTracker) add(m kafka.Message) {
+	if t.perRecord {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := topicPartition{m.Topic, m.Partition}
+	state, ok := t.partitions[key]
+	// После перебалансировки партиция может читаться заново с зафиксированного смещения
+	if !ok || (len(state.inflight) > 0 && m.Offset <= state.inflight[len(state.inflight)-1]) {
+		state = &partitionOffsets{done: make(map[int64]bool)}
+		t.partitions[key] = state
+	}
+	state.inflight = append(state.inflight, m.Offset)
+}
+
+// done отмечает запись обработанной и ставит в очередь коммит наибольшего непрерывного смещения.
+// Коммит ставится в очередь под блокировкой, поэтому смещения в очереди не убывают.
+func (t *offsetTracker) done(m kafka.Message) {
+	if t.perRecord {
+		t.commits <- m
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.partitions[topicPartition{m.Topic, m.Partition}]
+	if !ok {
+		return
+	}
+	state.done[m.Offset] = true
+
+	last := int64(-1)
+	for len(state.inflight) > 0 && state.done[state.inflight[0]] {
+		last = state.inflight[0]
+		delete(state.done, last)
+		state.inflight = state.inflight[1:]
+	}
+	if last >= 0 {
+		t.commits <- kafka.Message{Topic: m.Topic, Partition: m.Partition, Offset: last}
+	}
+}
+
+// close закрывает очередь коммитов после остановки всех обработчиков
+func (t *offsetTracker) close() {
+	close(t.commits)
+}
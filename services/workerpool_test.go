@@ -0,0 +1,57 @@
+// Package services workerpool_test.go
+package services
+
+import (
+	"github.com/segmentio/kafka-go"
+	"testing"
+)
+
+// drainCommits возвращает смещения коммитов, поставленных в очередь трекером
+func drainCommits(t *offsetTracker) []int64 {
+	var offsets []int64
+	for {
+		select {
+		case m := <-t.commits:
+			offsets = append(offsets, m.Offset)
+		default:
+			return offsets
+		}
+	}
+}
+
+func TestOffsetTrackerCommitsContiguousOffset(t *testing.T) {
+	tracker := newOffsetTracker(10, false)
+	msgs := make([]kafka.Message, 4)
+	for i := range msgs {
+		msgs[i] = kafka.Message{Topic: "orders", Offset: int64(i)}
+		tracker.add(msgs[i])
+	}
+
+	tracker.done(msgs[2])
+	tracker.done(msgs[1])
+	if got := drainCommits(tracker); len(got) != 0 {
+		t.Fatalf("коммиты до обработки смещения 0: %v", got)
+	}
+	tracker.done(msgs[0])
+	if got := drainCommits(tracker); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("коммиты после обработки 0-2: %v, ожидался [2]", got)
+	}
+	tracker.done(msgs[3])
+	if got := drainCommits(tracker); len(got) != 1 || got[0] != 3 {
+		t.Fatalf("коммиты после обработки 3: %v, ожидался [3]", got)
+	}
+}
+
+func TestOffsetTrackerPerRecordCommitsEveryRecord(t *testing.T) {
+	tracker := newOffsetTracker(10, true)
+	// Повторно доставленная запись приходит с меньшим номером и не сбрасывает остальные
+	for _, offset := range []int64{5, 6, 3} {
+		tracker.add(kafka.Message{Topic: "orders", Offset: offset})
+	}
+	for _, offset := range []int64{6, 3, 5} {
+		tracker.done(kafka.Message{Topic: "orders", Offset: offset})
+	}
+	if got := drainCommits(tracker); len(got) != 3 || got[0] != 6 || got[1] != 3 || got[2] != 5 {
+		t.Fatalf("коммиты: %v, ожидались [6 3 5]", got)
+	}
+}